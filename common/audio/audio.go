@@ -0,0 +1,359 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// the highest byte rates expected of each format, 96 kHz stereo 16-bit pcm for wav, 320 kbps for mp3
+// and 512 kbps for m4a and webm
+const (
+	maxWavByteRate        = 384000
+	maxMP3ByteRate        = 40000
+	maxCompressedByteRate = 64000
+)
+
+// GetAudioDuration reads the duration in seconds from the container headers of mp3, wav, m4a and webm files.
+// The headers are written by the client, so the duration is at least what the size of the file lasts at the
+// highest byte rate expected of its format.
+func GetAudioDuration(data []byte) (float64, error) {
+	var duration float64
+	var err error
+	var maxByteRate float64
+	switch {
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		duration, err = getWavDuration(data)
+		maxByteRate = maxWavByteRate
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		duration, err = getMP4Duration(data)
+		maxByteRate = maxCompressedByteRate
+	case len(data) >= 4 && bytes.Equal(data[0:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		duration, err = getWebmDuration(data)
+		maxByteRate = maxCompressedByteRate
+	case len(data) >= 3 && string(data[0:3]) == "ID3",
+		len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		duration, err = getMP3Duration(data)
+		maxByteRate = maxMP3ByteRate
+	default:
+		return 0, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(duration) || math.IsInf(duration, 0) || duration < 0 {
+		duration = 0
+	}
+	return max(duration, float64(len(data))/maxByteRate), nil
+}
+
+func getWavDuration(data []byte) (float64, error) {
+	var byteRate uint32
+	offset := 12
+	for offset+8 <= len(data) {
+		chunkId := string(data[offset : offset+4])
+		chunkSize := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		body := offset + 8
+		switch chunkId {
+		case "fmt ":
+			if body+12 > len(data) {
+				return 0, errors.New("invalid wav fmt chunk")
+			}
+			byteRate = binary.LittleEndian.Uint32(data[body+8 : body+12])
+		case "data":
+			if byteRate == 0 {
+				return 0, errors.New("wav data chunk before fmt chunk")
+			}
+			// streamed wav files may carry a placeholder size
+			if chunkSize <= 0 || body+chunkSize > len(data) {
+				chunkSize = len(data) - body
+			}
+			return float64(chunkSize) / float64(byteRate), nil
+		}
+		offset = body + chunkSize + chunkSize%2
+	}
+	return 0, errors.New("wav data chunk not found")
+}
+
+func getMP4Duration(data []byte) (float64, error) {
+	moov, ok := findMP4Box(data, "moov")
+	if !ok {
+		return 0, errors.New("mp4 moov box not found")
+	}
+	mvhd, ok := findMP4Box(moov, "mvhd")
+	if !ok || len(mvhd) < 4 {
+		return 0, errors.New("mp4 mvhd box not found")
+	}
+	var timescale uint32
+	var duration uint64
+	if mvhd[0] == 1 {
+		if len(mvhd) < 32 {
+			return 0, errors.New("invalid mp4 mvhd box")
+		}
+		timescale = binary.BigEndian.Uint32(mvhd[20:24])
+		duration = binary.BigEndian.Uint64(mvhd[24:32])
+	} else {
+		if len(mvhd) < 20 {
+			return 0, errors.New("invalid mp4 mvhd box")
+		}
+		timescale = binary.BigEndian.Uint32(mvhd[12:16])
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+	}
+	if timescale == 0 {
+		return 0, errors.New("invalid mp4 timescale")
+	}
+	return float64(duration) / float64(timescale), nil
+}
+
+// findMP4Box returns the payload of the first box with the given type at this level
+func findMP4Box(data []byte, boxType string) ([]byte, bool) {
+	offset := 0
+	for offset+8 <= len(data) {
+		size := uint64(binary.BigEndian.Uint32(data[offset : offset+4]))
+		headerSize := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data) - offset)
+		case 1:
+			if offset+16 > len(data) {
+				return nil, false
+			}
+			size = binary.BigEndian.Uint64(data[offset+8 : offset+16])
+			headerSize = 16
+		}
+		// compared before adding, so that a size near 2^64 does not wrap around
+		if size < headerSize || size > uint64(len(data)-offset) {
+			return nil, false
+		}
+		if string(data[offset+4:offset+8]) == boxType {
+			return data[offset+int(headerSize) : offset+int(size)], true
+		}
+		offset += int(size)
+	}
+	return nil, false
+}
+
+const (
+	ebmlIdSegment       = 0x18538067
+	ebmlIdInfo          = 0x1549A966
+	ebmlIdTimecodeScale = 0x2AD7B1
+	ebmlIdDuration      = 0x4489
+)
+
+func getWebmDuration(data []byte) (float64, error) {
+	segment, ok := findEBMLElement(data, ebmlIdSegment)
+	if !ok {
+		return 0, errors.New("webm segment not found")
+	}
+	info, ok := findEBMLElement(segment, ebmlIdInfo)
+	if !ok {
+		return 0, errors.New("webm info not found")
+	}
+	timecodeScale := uint64(1000000)
+	if scale, ok := findEBMLElement(info, ebmlIdTimecodeScale); ok && len(scale) > 0 && len(scale) <= 8 {
+		timecodeScale = 0
+		for _, b := range scale {
+			timecodeScale = timecodeScale<<8 | uint64(b)
+		}
+	}
+	duration, ok := findEBMLElement(info, ebmlIdDuration)
+	if !ok {
+		// MediaRecorder writes webm without duration
+		return 0, errors.New("webm duration not found")
+	}
+	var value float64
+	switch len(duration) {
+	case 4:
+		value = float64(math.Float32frombits(binary.BigEndian.Uint32(duration)))
+	case 8:
+		value = math.Float64frombits(binary.BigEndian.Uint64(duration))
+	default:
+		return 0, errors.New("invalid webm duration")
+	}
+	return value * float64(timecodeScale) / 1e9, nil
+}
+
+// readEBMLVint reads a variable length integer, the marker bit is kept for ids and removed for sizes
+func readEBMLVint(data []byte, keepMarker bool) (value uint64, length int, unknown bool) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0, false
+	}
+	length = 1
+	for mask := byte(0x80); data[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > len(data) {
+		return 0, 0, false
+	}
+	first := data[0]
+	if !keepMarker {
+		first &= 0xFF >> length
+	}
+	value = uint64(first)
+	unknown = first == 0xFF>>length
+	for i := 1; i < length; i++ {
+		value = value<<8 | uint64(data[i])
+		unknown = unknown && data[i] == 0xFF
+	}
+	return value, length, unknown
+}
+
+// findEBMLElement returns the payload of the first element with the given id at this level
+func findEBMLElement(data []byte, id uint64) ([]byte, bool) {
+	offset := 0
+	for offset < len(data) {
+		elementId, idLength, _ := readEBMLVint(data[offset:], true)
+		if idLength == 0 {
+			return nil, false
+		}
+		size, sizeLength, unknown := readEBMLVint(data[offset+idLength:], false)
+		if sizeLength == 0 {
+			return nil, false
+		}
+		body := offset + idLength + sizeLength
+		end := body + int(size)
+		if unknown || size > uint64(len(data)) || end > len(data) {
+			end = len(data)
+		}
+		if elementId == id {
+			return data[body:end], true
+		}
+		offset = end
+	}
+	return nil, false
+}
+
+var mp3BitRates = map[[2]int][16]int{
+	{1, 1}: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+	{1, 2}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+	{1, 3}: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	{2, 1}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+	{2, 2}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	{2, 3}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+}
+
+var mp3SampleRates = map[int][3]int{
+	1: {44100, 48000, 32000},
+	2: {22050, 24000, 16000},
+	3: {11025, 12000, 8000}, // MPEG 2.5
+}
+
+type mp3Frame struct {
+	version         int // 1: MPEG 1, 2: MPEG 2, 3: MPEG 2.5
+	layer           int
+	sampleRate      int
+	samplesPerFrame int
+	length          int
+	mono            bool
+}
+
+func parseMP3Frame(header []byte) (*mp3Frame, bool) {
+	if len(header) < 4 || header[0] != 0xFF || header[1]&0xE0 != 0xE0 {
+		return nil, false
+	}
+	frame := &mp3Frame{}
+	switch (header[1] >> 3) & 0x03 {
+	case 3:
+		frame.version = 1
+	case 2:
+		frame.version = 2
+	case 0:
+		frame.version = 3
+	default:
+		return nil, false
+	}
+	frame.layer = 4 - int((header[1]>>1)&0x03)
+	if frame.layer == 4 {
+		return nil, false
+	}
+	bitRateIndex := int(header[2] >> 4)
+	sampleRateIndex := int((header[2] >> 2) & 0x03)
+	if sampleRateIndex == 3 {
+		return nil, false
+	}
+	tableVersion := frame.version
+	if tableVersion == 3 {
+		tableVersion = 2
+	}
+	bitRate := mp3BitRates[[2]int{tableVersion, frame.layer}][bitRateIndex] * 1000
+	if bitRate == 0 {
+		return nil, false
+	}
+	frame.sampleRate = mp3SampleRates[frame.version][sampleRateIndex]
+	padding := int((header[2] >> 1) & 0x01)
+	frame.mono = header[3]>>6 == 3
+	switch {
+	case frame.layer == 1:
+		frame.samplesPerFrame = 384
+		frame.length = (12*bitRate/frame.sampleRate + padding) * 4
+	case frame.layer == 3 && frame.version != 1:
+		frame.samplesPerFrame = 576
+		frame.length = 72*bitRate/frame.sampleRate + padding
+	default:
+		frame.samplesPerFrame = 1152
+		frame.length = 144*bitRate/frame.sampleRate + padding
+	}
+	return frame, true
+}
+
+func getMP3Duration(data []byte) (float64, error) {
+	offset := 0
+	// skip ID3v2 tag
+	if len(data) >= 10 && string(data[0:3]) == "ID3" {
+		size := int(data[6]&0x7F)<<21 | int(data[7]&0x7F)<<14 | int(data[8]&0x7F)<<7 | int(data[9]&0x7F)
+		offset = 10 + size
+		if data[5]&0x10 != 0 {
+			offset += 10
+		}
+	}
+	// find the first frame
+	var first *mp3Frame
+	for ; offset+4 <= len(data); offset++ {
+		if frame, ok := parseMP3Frame(data[offset:]); ok {
+			first = frame
+			break
+		}
+	}
+	if first == nil {
+		return 0, errors.New("mp3 frame not found")
+	}
+	// VBR files store the total frame count in a Xing/Info or VBRI header
+	sideInfoSize := 32
+	switch {
+	case first.version == 1 && first.mono, first.version != 1 && !first.mono:
+		sideInfoSize = 17
+	case first.version != 1:
+		sideInfoSize = 9
+	}
+	xing := offset + 4 + sideInfoSize
+	if xing+12 <= len(data) {
+		tag := string(data[xing : xing+4])
+		if (tag == "Xing" || tag == "Info") && binary.BigEndian.Uint32(data[xing+4:xing+8])&0x01 != 0 {
+			frames := binary.BigEndian.Uint32(data[xing+8 : xing+12])
+			return float64(frames) * float64(first.samplesPerFrame) / float64(first.sampleRate), nil
+		}
+	}
+	vbri := offset + 4 + 32
+	if vbri+18 <= len(data) && string(data[vbri:vbri+4]) == "VBRI" {
+		frames := binary.BigEndian.Uint32(data[vbri+14 : vbri+18])
+		return float64(frames) * float64(first.samplesPerFrame) / float64(first.sampleRate), nil
+	}
+	// otherwise walk through all frames
+	var duration float64
+	for offset+4 <= len(data) {
+		if string(data[offset:offset+3]) == "TAG" {
+			break
+		}
+		frame, ok := parseMP3Frame(data[offset:])
+		if !ok {
+			offset++
+			continue
+		}
+		duration += float64(frame.samplesPerFrame) / float64(frame.sampleRate)
+		offset += frame.length
+	}
+	return duration, nil
+}
@@ -0,0 +1,129 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func buildWav(seconds int) []byte {
+	const sampleRate, channels, bitsPerSample = 16000, 1, 16
+	byteRate := sampleRate * channels * bitsPerSample / 8
+	dataSize := byteRate * seconds
+	buf := &bytes.Buffer{}
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(1))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(channels))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(byteRate))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(channels*bitsPerSample/8))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(bitsPerSample))
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(dataSize))
+	buf.Write(make([]byte, dataSize))
+	return buf.Bytes()
+}
+
+func mp4Box(boxType string, payload []byte) []byte {
+	box := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(box, uint32(8+len(payload)))
+	copy(box[4:], boxType)
+	return append(box, payload...)
+}
+
+func buildM4a(timescale, duration uint32) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:16], timescale)
+	binary.BigEndian.PutUint32(mvhd[16:20], duration)
+	data := mp4Box("ftyp", []byte("M4A \x00\x00\x00\x00"))
+	data = append(data, mp4Box("free", nil)...)
+	return append(data, mp4Box("moov", mp4Box("mvhd", mvhd))...)
+}
+
+func buildWebm(durationMs float64) []byte {
+	duration := make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(durationMs))
+	info := append([]byte{0x2A, 0xD7, 0xB1, 0x83, 0x0F, 0x42, 0x40}, 0x44, 0x89, 0x88)
+	info = append(info, duration...)
+	segment := append([]byte{0x15, 0x49, 0xA9, 0x66, 0x80 | byte(len(info))}, info...)
+	data := []byte{0x1A, 0x45, 0xDF, 0xA3, 0x80}
+	// segment with unknown size, as written by live encoders
+	data = append(data, 0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	return append(data, segment...)
+}
+
+func buildMP3(frames int) []byte {
+	// MPEG 1 Layer III, 128 kbps, 44100 Hz, stereo: 417 bytes per frame
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	data := []byte("ID3\x04\x00\x00\x00\x00\x00\x0a")
+	data = append(data, make([]byte, 10)...)
+	for i := 0; i < frames; i++ {
+		data = append(data, frame...)
+	}
+	return data
+}
+
+func TestGetAudioDuration(t *testing.T) {
+	Convey("GetAudioDuration", t, func() {
+		Convey("wav", func() {
+			duration, err := GetAudioDuration(buildWav(3))
+			So(err, ShouldBeNil)
+			So(duration, ShouldAlmostEqual, 3)
+		})
+		Convey("m4a", func() {
+			duration, err := GetAudioDuration(buildM4a(44100, 44100*12+22050))
+			So(err, ShouldBeNil)
+			So(duration, ShouldAlmostEqual, 12.5)
+		})
+		Convey("webm", func() {
+			duration, err := GetAudioDuration(buildWebm(7250))
+			So(err, ShouldBeNil)
+			So(duration, ShouldAlmostEqual, 7.25)
+		})
+		Convey("mp3", func() {
+			duration, err := GetAudioDuration(buildMP3(100))
+			So(err, ShouldBeNil)
+			So(duration, ShouldAlmostEqual, 100*1152/44100.0, 0.0001)
+		})
+		Convey("wav with a forged byte rate", func() {
+			data := buildWav(30)
+			binary.LittleEndian.PutUint32(data[28:32], 1<<30)
+			duration, err := GetAudioDuration(data)
+			So(err, ShouldBeNil)
+			So(duration, ShouldAlmostEqual, float64(len(data))/maxWavByteRate)
+		})
+		Convey("mp3 with a forged frame count", func() {
+			data := buildMP3(1000)
+			// Xing header claiming a single frame
+			xing := 20 + 4 + 32
+			copy(data[xing:], "Xing")
+			binary.BigEndian.PutUint32(data[xing+4:], 1)
+			binary.BigEndian.PutUint32(data[xing+8:], 1)
+			duration, err := GetAudioDuration(data)
+			So(err, ShouldBeNil)
+			So(duration, ShouldAlmostEqual, float64(len(data))/maxMP3ByteRate)
+		})
+		Convey("mp4 box with a huge largesize", func() {
+			data := mp4Box("ftyp", []byte("M4A \x00\x00\x00\x00"))
+			box := make([]byte, 16)
+			binary.BigEndian.PutUint32(box, 1)
+			copy(box[4:], "moov")
+			binary.BigEndian.PutUint64(box[8:], math.MaxUint64-7)
+			data = append(data, box...)
+			So(func() { _, _ = GetAudioDuration(data) }, ShouldNotPanic)
+			_, err := GetAudioDuration(data)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("unsupported", func() {
+			_, err := GetAudioDuration([]byte("OggS\x00\x02"))
+			So(err, ShouldEqual, ErrUnsupportedFormat)
+		})
+	})
+}
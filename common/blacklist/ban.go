@@ -0,0 +1,219 @@
+package blacklist
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	BanTypeIp    = "ip"
+	BanTypeToken = "token"
+)
+
+// Ban is a temporary ban of an ip or a token, it is shared across nodes through Redis
+type Ban struct {
+	Type        string `json:"type"`
+	Value       string `json:"value"`
+	Reason      string `json:"reason"`
+	CreatedTime int64  `json:"created_time"`
+	ExpiredTime int64  `json:"expired_time"`
+}
+
+// bans are kept in one Redis hash so that they can be listed without scanning keys
+const bansRedisKey = "bans"
+
+var localBans sync.Map
+
+// failures counts the failures of each ip and token when Redis is disabled,
+// counters whose window has passed are swept at most once per window
+var failures = struct {
+	sync.Mutex
+	counters  map[string]*failureCounter
+	lastSweep time.Time
+}{counters: make(map[string]*failureCounter)}
+
+type failureCounter struct {
+	count int
+	start time.Time
+}
+
+func banKey(banType string, value string) string {
+	return banType + ":" + value
+}
+
+// AddBan bans the ip or token for the duration
+func AddBan(banType string, value string, reason string, duration time.Duration) {
+	now := time.Now()
+	ban := &Ban{
+		Type:        banType,
+		Value:       value,
+		Reason:      reason,
+		CreatedTime: now.Unix(),
+		ExpiredTime: now.Add(duration).Unix(),
+	}
+	logger.SysLogf("banned %s %s for %s: %s", banType, value, duration, reason)
+	if !common.RedisEnabled {
+		localBans.Store(banKey(banType, value), ban)
+		return
+	}
+	data, _ := json.Marshal(ban)
+	if err := common.RDB.HSet(context.Background(), bansRedisKey, banKey(banType, value), string(data)).Err(); err != nil {
+		logger.SysError("Redis set ban error: " + err.Error())
+	}
+}
+
+func getBan(banType string, value string) *Ban {
+	key := banKey(banType, value)
+	if !common.RedisEnabled {
+		if ban, ok := localBans.Load(key); ok {
+			return ban.(*Ban)
+		}
+		return nil
+	}
+	data, err := common.RDB.HGet(context.Background(), bansRedisKey, key).Result()
+	if err != nil {
+		return nil
+	}
+	ban := &Ban{}
+	if json.Unmarshal([]byte(data), ban) != nil {
+		return nil
+	}
+	return ban
+}
+
+func IsBanned(banType string, value string) bool {
+	ban := getBan(banType, value)
+	if ban == nil {
+		return false
+	}
+	if ban.ExpiredTime <= time.Now().Unix() {
+		_ = Lift(banType, value)
+		return false
+	}
+	return true
+}
+
+// GetBans lists the bans that have not expired yet
+func GetBans() ([]*Ban, error) {
+	var bans []*Ban
+	now := time.Now().Unix()
+	if !common.RedisEnabled {
+		localBans.Range(func(key, value any) bool {
+			if ban := value.(*Ban); ban.ExpiredTime > now {
+				bans = append(bans, ban)
+			} else {
+				localBans.Delete(key)
+			}
+			return true
+		})
+	} else {
+		all, err := common.RDB.HGetAll(context.Background(), bansRedisKey).Result()
+		if err != nil {
+			return nil, err
+		}
+		for key, data := range all {
+			ban := &Ban{}
+			if json.Unmarshal([]byte(data), ban) != nil || ban.ExpiredTime <= now {
+				common.RDB.HDel(context.Background(), bansRedisKey, key)
+				continue
+			}
+			bans = append(bans, ban)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].CreatedTime > bans[j].CreatedTime
+	})
+	return bans, nil
+}
+
+// Lift removes the ban of the ip or token and resets its failures
+func Lift(banType string, value string) error {
+	key := banKey(banType, value)
+	failures.Lock()
+	delete(failures.counters, key)
+	failures.Unlock()
+	if !common.RedisEnabled {
+		localBans.Delete(key)
+		return nil
+	}
+	ctx := context.Background()
+	common.RDB.Del(ctx, failureRedisKey(key))
+	return common.RDB.HDel(ctx, bansRedisKey, key).Err()
+}
+
+func failureRedisKey(key string) string {
+	return "ban_failures:" + key
+}
+
+// RecordFailure counts an authentication failure of the ip or token,
+// it is banned once its failures within the window reach the threshold
+func RecordFailure(banType string, value string, reason string) {
+	if config.AutoBanDuration <= 0 || config.AutoBanFailureThreshold <= 0 || value == "" {
+		return
+	}
+	key := banKey(banType, value)
+	window := time.Duration(config.AutoBanFailureWindow) * time.Second
+	var count int
+	if common.RedisEnabled {
+		ctx := context.Background()
+		n, err := common.RDB.Incr(ctx, failureRedisKey(key)).Result()
+		if err != nil {
+			logger.SysError("Redis incr ban failures error: " + err.Error())
+			return
+		}
+		if n == 1 {
+			common.RDB.Expire(ctx, failureRedisKey(key), window)
+		}
+		count = int(n)
+	} else {
+		now := time.Now()
+		failures.Lock()
+		if now.Sub(failures.lastSweep) > window {
+			sweepFailures(now, window)
+		}
+		counter, ok := failures.counters[key]
+		if !ok || now.Sub(counter.start) > window {
+			counter = &failureCounter{start: now}
+			failures.counters[key] = counter
+		}
+		counter.count++
+		count = counter.count
+		failures.Unlock()
+	}
+	if count >= config.AutoBanFailureThreshold {
+		AutoBan(banType, value, reason)
+	}
+}
+
+// sweepFailures deletes the counters whose window has passed, the caller must hold the lock
+func sweepFailures(now time.Time, window time.Duration) {
+	for key, counter := range failures.counters {
+		if now.Sub(counter.start) > window {
+			delete(failures.counters, key)
+		}
+	}
+	failures.lastSweep = now
+}
+
+// AutoBan bans the ip or token for the configured duration, if automatic bans are enabled
+func AutoBan(banType string, value string, reason string) {
+	if config.AutoBanDuration <= 0 || value == "" {
+		return
+	}
+	AddBan(banType, value, reason, time.Duration(config.AutoBanDuration)*time.Second)
+	key := banKey(banType, value)
+	if common.RedisEnabled {
+		common.RDB.Del(context.Background(), failureRedisKey(key))
+		return
+	}
+	failures.Lock()
+	delete(failures.counters, key)
+	failures.Unlock()
+}
@@ -0,0 +1,55 @@
+package blacklist
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+func TestRecordFailure(t *testing.T) {
+	Convey("RecordFailure without Redis", t, func() {
+		redisEnabled := common.RedisEnabled
+		threshold, window := config.AutoBanFailureThreshold, config.AutoBanFailureWindow
+		common.RedisEnabled = false
+		config.AutoBanFailureThreshold, config.AutoBanFailureWindow = 3, 60
+		Reset(func() {
+			config.AutoBanFailureThreshold, config.AutoBanFailureWindow = threshold, window
+			_ = Lift(BanTypeIp, "10.0.0.1")
+			common.RedisEnabled = redisEnabled
+			failures.Lock()
+			failures.counters = make(map[string]*failureCounter)
+			failures.lastSweep = time.Time{}
+			failures.Unlock()
+		})
+
+		Convey("bans once the threshold is reached", func() {
+			for i := 0; i < 3; i++ {
+				So(IsBanned(BanTypeIp, "10.0.0.1"), ShouldBeFalse)
+				RecordFailure(BanTypeIp, "10.0.0.1", "test")
+			}
+			So(IsBanned(BanTypeIp, "10.0.0.1"), ShouldBeTrue)
+			So(failures.counters, ShouldNotContainKey, banKey(BanTypeIp, "10.0.0.1"))
+		})
+
+		Convey("evicts the counters whose window has passed", func() {
+			RecordFailure(BanTypeIp, "10.0.0.2", "test")
+			RecordFailure(BanTypeToken, "1", "test")
+			So(failures.counters, ShouldHaveLength, 2)
+
+			failures.Lock()
+			expired := time.Now().Add(-2 * time.Minute)
+			failures.counters[banKey(BanTypeIp, "10.0.0.2")].start = expired
+			failures.lastSweep = expired
+			failures.Unlock()
+
+			RecordFailure(BanTypeToken, "1", "test")
+			So(failures.counters, ShouldHaveLength, 1)
+			So(failures.counters, ShouldNotContainKey, banKey(BanTypeIp, "10.0.0.2"))
+			So(failures.counters[banKey(BanTypeToken, "1")].count, ShouldEqual, 2)
+		})
+	})
+}
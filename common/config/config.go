@@ -26,6 +26,9 @@ var DisplayTokenStatEnabled = true
 
 var SessionSecret = uuid.New().String()
 
+// TokenKeySecret keys the hash of stored token keys, changing it invalidates all existing tokens
+var TokenKeySecret = os.Getenv("TOKEN_KEY_SECRET")
+
 var OptionMap map[string]string
 var OptionMapRWMutex sync.RWMutex
 
@@ -41,13 +44,24 @@ var WeChatAuthEnabled = false
 var TurnstileCheckEnabled = false
 var RegisterEnabled = true
 
+// AdminTwoFactorEnabled requires admin users to enable two-factor authentication before they can log in
+var AdminTwoFactorEnabled = false
+
 // 敏感词过滤配置
 var SensitiveFilterEnabled = true
-var SensitiveWords = []string{}
 var SensitiveFilterResponse = "您的请求包含敏感内容，已被系统拦截。"
+
+// SensitiveWordsFile is read once to import the words of older versions as filter rules,
+// unless SensitiveWordsFileEnabled, then its words are block rules besides those of the database
+// and the file is watched for changes
 var SensitiveWordsFile = env.String("SENSITIVE_WORDS_FILE", "/data/config/sensitive_words.txt")
+var SensitiveWordsFileEnabled = env.Bool("SENSITIVE_WORDS_FILE_ENABLED", false)
 var SensitiveResponseFile = env.String("SENSITIVE_RESPONSE_FILE", "/data/config/sensitive_response.txt")
 
+// PiiRedactionRules are the redaction rules applied to prompts by default, empty means no redaction
+var PiiRedactionRules = ""
+var PiiRedactionRestoreEnabled = false
+
 var EmailDomainRestrictionEnabled = false
 var EmailDomainWhitelist = []string{
 	"gmail.com",
@@ -151,6 +165,34 @@ var (
 
 var RateLimitKeyExpirationDuration = 20 * time.Minute
 
+// ips and tokens with AutoBanFailureThreshold authentication failures within AutoBanFailureWindow seconds,
+// and ips tripping the critical rate limit, are banned for AutoBanDuration seconds, 0 disables automatic bans
+var (
+	AutoBanFailureThreshold = env.Int("AUTO_BAN_FAILURE_THRESHOLD", 10)
+	AutoBanFailureWindow    = env.Int("AUTO_BAN_FAILURE_WINDOW", 300)
+	AutoBanDuration         = env.Int("AUTO_BAN_DURATION", 3600)
+)
+
+// IpAllowList and IpDenyList restrict the ips that may use the api and the dashboard, comma separated cidr
+var IpAllowList = ""
+var IpDenyList = ""
+
+// a token used from more than TokenLeakIpThreshold distinct ips, or with more than TokenLeakUserAgentThreshold
+// distinct user agents, within TokenLeakWindow seconds is handled as leaked, 0 disables the check
+var TokenLeakDetectionEnabled = false
+var TokenLeakWindow = 600
+var TokenLeakIpThreshold = 20
+var TokenLeakUserAgentThreshold = 10
+
+// a token spending more than TokenSpendAnomalyRatio times its hourly average of the last TokenSpendBaselineHours hours
+// within the current hour, and at least TokenSpendAnomalyMinQuota, is handled as leaked, 0 disables the check
+var TokenSpendAnomalyRatio = 10.0
+var TokenSpendAnomalyMinQuota int64 = 5000000
+var TokenSpendBaselineHours = 24
+
+// TokenLeakAction is what is done to a leaked token: notify, ban or disable, the owner is notified in all cases
+var TokenLeakAction = "notify"
+
 var EnableMetric = env.Bool("ENABLE_METRIC", false)
 var MetricQueueSize = env.Int("METRIC_QUEUE_SIZE", 10)
 var MetricSuccessRateThreshold = env.Float64("METRIC_SUCCESS_RATE_THRESHOLD", 0.8)
@@ -163,7 +205,22 @@ var InitialRootAccessToken = os.Getenv("INITIAL_ROOT_ACCESS_TOKEN")
 
 var GeminiVersion = env.String("GEMINI_VERSION", "v1")
 
+// the log file is rotated when it grows beyond LogMaxSize megabytes and, unless OnlyOneLogFile, when the day changes,
+// rotated files older than LogMaxAge days are deleted, 0 disables either
 var OnlyOneLogFile = env.Bool("ONLY_ONE_LOG_FILE", false)
+var LogMaxSize = env.Int("LOG_MAX_SIZE", 100)
+var LogMaxAge = env.Int("LOG_MAX_AGE", 0)
+
+// LogFormat is text or json, json logs have a line per entry for log collectors such as loki
+var LogFormat = env.String("LOG_FORMAT", "text")
+
+// LogLevel is the lowest level logged, optionally followed by the levels of packages, e.g. "info,relay/adaptor=debug"
+var LogLevel = env.String("LOG_LEVEL", "info")
+
+// the first LogSampleInitial info and debug entries of a line of code each second are logged,
+// then one in LogSampleThereafter, 0 disables sampling
+var LogSampleInitial = env.Int("LOG_SAMPLE_INITIAL", 0)
+var LogSampleThereafter = env.Int("LOG_SAMPLE_THEREAFTER", 100)
 
 var RelayProxy = env.String("RELAY_PROXY", "")
 var UserContentRequestProxy = env.String("USER_CONTENT_REQUEST_PROXY", "")
@@ -171,3 +228,25 @@ var UserContentRequestTimeout = env.Int("USER_CONTENT_REQUEST_TIMEOUT", 30)
 
 var EnforceIncludeUsage = env.Bool("ENFORCE_INCLUDE_USAGE", false)
 var TestPrompt = env.String("TEST_PROMPT", "Output only your specific model name with no additional text.")
+
+// AuditLogRetentionDays is how long audit logs are kept, 0 means forever
+var AuditLogRetentionDays = env.Int("AUDIT_LOG_RETENTION_DAYS", 0)
+
+// the request and response bodies of the users and tokens in CaptureUserIds and CaptureTokenIds (comma separated),
+// and of a CaptureSampleRate share of the other requests, are captured for debugging, each body is cut at
+// CaptureMaxBytes, and captures are kept for CaptureRetentionDays days, 0 means forever
+var CaptureUserIds = ""
+var CaptureTokenIds = ""
+var CaptureSampleRate = 0.0
+var CaptureMaxBytes = 32 * 1024
+var CaptureRetentionDays = env.Int("CAPTURE_RETENTION_DAYS", 7)
+
+// LogRetentionDays is how many days the logs of each type are kept, e.g. {"consume": 90, "system": 365},
+// the logs of the other types are kept forever
+var LogRetentionDays = ""
+
+// the logs are deleted LogCleanBatchSize rows at a time, and with LogArchiveEnabled they are first archived
+// to gzipped jsonl files in LogArchiveDir, which defaults to the archive directory in the log directory
+var LogArchiveEnabled = false
+var LogArchiveDir = env.String("LOG_ARCHIVE_DIR", "")
+var LogCleanBatchSize = env.Int("LOG_CLEAN_BATCH_SIZE", 1000)
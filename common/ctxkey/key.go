@@ -21,4 +21,9 @@ const (
 	AvailableModels   = "available_models"
 	KeyRequestBody    = "key_request_body"
 	SystemPrompt      = "system_prompt"
+	AccessToken       = "access_token"
+	TokenPiiRules     = "token_pii_rules"
+	TokenFilterPolicy = "token_filter_policy"
+	FilterPolicy      = "filter_policy"
+	ParsedRequestBody = "parsed_request_body"
 )
@@ -34,7 +34,7 @@ func UnmarshalBodyReusable(c *gin.Context, v any) error {
 		err = json.Unmarshal(requestBody, &v)
 	} else {
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
-		err = c.ShouldBind(&v)
+		err = c.ShouldBind(v)
 	}
 	if err != nil {
 		return err
@@ -44,6 +44,22 @@ func UnmarshalBodyReusable(c *gin.Context, v any) error {
 	return nil
 }
 
+// UnmarshalBodyOnce unmarshals the body into a new T once per request, later calls with the same type
+// return the same value, so that the changes made by a middleware are seen by the relay
+func UnmarshalBodyOnce[T any](c *gin.Context) (*T, error) {
+	if parsed, ok := c.Get(ctxkey.ParsedRequestBody); ok {
+		if v, ok := parsed.(*T); ok {
+			return v, nil
+		}
+	}
+	v := new(T)
+	if err := UnmarshalBodyReusable(c, v); err != nil {
+		return nil, err
+	}
+	c.Set(ctxkey.ParsedRequestBody, v)
+	return v, nil
+}
+
 func SetEventStreamHeaders(c *gin.Context) {
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
@@ -0,0 +1,49 @@
+package logger
+
+import (
+	"context"
+	"sync"
+)
+
+type fieldsKey struct{}
+
+// requestFields are the fields of the json logs of a request, they are filled in as the request
+// is authenticated and distributed to a channel
+type requestFields struct {
+	mu        sync.RWMutex
+	userId    int
+	channelId int
+	model     string
+}
+
+// WithFields returns a context to which the user, channel and model of the request can be added
+func WithFields(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fieldsKey{}, &requestFields{})
+}
+
+func getFields(ctx context.Context) *requestFields {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).(*requestFields)
+	return fields
+}
+
+// SetUserId adds the user to the logs of the request of ctx
+func SetUserId(ctx context.Context, userId int) {
+	if fields := getFields(ctx); fields != nil {
+		fields.mu.Lock()
+		fields.userId = userId
+		fields.mu.Unlock()
+	}
+}
+
+// SetChannel adds the channel and the model to the logs of the request of ctx
+func SetChannel(ctx context.Context, channelId int, modelName string) {
+	if fields := getFields(ctx); fields != nil {
+		fields.mu.Lock()
+		fields.channelId = channelId
+		fields.model = modelName
+		fields.mu.Unlock()
+	}
+}
@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+var levelRanks = map[loggerLevel]int{
+	loggerDEBUG: 0,
+	loggerINFO:  1,
+	loggerWarn:  2,
+	loggerError: 3,
+	loggerFatal: 4,
+}
+
+func (level loggerLevel) rank() int {
+	return levelRanks[level]
+}
+
+func parseLevel(name string) (loggerLevel, error) {
+	level := loggerLevel(strings.ToUpper(strings.TrimSpace(name)))
+	if level == "WARNING" {
+		level = loggerWarn
+	}
+	if _, ok := levelRanks[level]; !ok {
+		return "", fmt.Errorf("未知的日志级别：%s", name)
+	}
+	return level, nil
+}
+
+type packageLevel struct {
+	prefix string
+	level  loggerLevel
+}
+
+// levelConfig is the default level and the levels of packages, the longest matching prefix wins
+type levelConfig struct {
+	base     loggerLevel
+	packages []packageLevel
+	minRank  int // the lowest rank of all levels, entries below it are dropped before finding their package
+}
+
+func (c *levelConfig) levelOf(pkg string) loggerLevel {
+	for _, p := range c.packages {
+		if pkg == p.prefix || strings.HasPrefix(pkg, p.prefix+"/") {
+			return p.level
+		}
+	}
+	return c.base
+}
+
+// parseLevels parses levels such as "info,relay/adaptor=debug,model=warn",
+// the packages are given by their path in the module
+func parseLevels(spec string) (*levelConfig, error) {
+	c := &levelConfig{base: loggerINFO}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		pkg, name, ok := strings.Cut(item, "=")
+		if !ok {
+			level, err := parseLevel(item)
+			if err != nil {
+				return nil, err
+			}
+			c.base = level
+			continue
+		}
+		level, err := parseLevel(name)
+		if err != nil {
+			return nil, err
+		}
+		c.packages = append(c.packages, packageLevel{prefix: strings.Trim(strings.TrimSpace(pkg), "/"), level: level})
+	}
+	if config.DebugEnabled {
+		c.base = loggerDEBUG
+	}
+	sort.SliceStable(c.packages, func(i, j int) bool {
+		return len(c.packages[i].prefix) > len(c.packages[j].prefix)
+	})
+	c.minRank = c.base.rank()
+	for _, p := range c.packages {
+		c.minRank = min(c.minRank, p.level.rank())
+	}
+	return c, nil
+}
+
+var levels atomic.Pointer[levelConfig]
+
+func init() {
+	c, err := parseLevels(config.LogLevel)
+	if err != nil {
+		c, _ = parseLevels("")
+	}
+	levels.Store(c)
+}
+
+// ValidateLevels checks levels such as "info,relay/adaptor=debug"
+func ValidateLevels(spec string) error {
+	_, err := parseLevels(spec)
+	return err
+}
+
+// SetLevels changes the levels at runtime, see parseLevels
+func SetLevels(spec string) error {
+	c, err := parseLevels(spec)
+	if err != nil {
+		return err
+	}
+	levels.Store(c)
+	return nil
+}
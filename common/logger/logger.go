@@ -2,6 +2,7 @@ package logger
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -28,18 +29,14 @@ const (
 	loggerFatal loggerLevel = "FATAL"
 )
 
+const modulePath = "github.com/songquanpeng/one-api"
+
 var setupLogOnce sync.Once
 
 func SetupLogger() {
 	setupLogOnce.Do(func() {
 		if LogDir != "" {
-			var logPath string
-			if config.OnlyOneLogFile {
-				logPath = filepath.Join(LogDir, "oneapi.log")
-			} else {
-				logPath = filepath.Join(LogDir, fmt.Sprintf("oneapi-%s.log", time.Now().Format("20060102")))
-			}
-			fd, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			fd, err := openRotatingFile(LogDir)
 			if err != nil {
 				log.Fatal("failed to open log file")
 			}
@@ -74,7 +71,7 @@ func SysErrorf(format string, a ...any) {
 }
 
 func Debug(ctx context.Context, msg string) {
-	if !config.DebugEnabled {
+	if !debugEnabled() {
 		return
 	}
 	logHelper(ctx, loggerDEBUG, msg)
@@ -93,7 +90,7 @@ func Error(ctx context.Context, msg string) {
 }
 
 func Debugf(ctx context.Context, format string, a ...any) {
-	if !config.DebugEnabled {
+	if !debugEnabled() {
 		return
 	}
 	logHelper(ctx, loggerDEBUG, fmt.Sprintf(format, a...))
@@ -119,36 +116,107 @@ func FatalLogf(format string, a ...any) {
 	logHelper(nil, loggerFatal, fmt.Sprintf(format, a...))
 }
 
+// debugEnabled reports whether any package logs debug entries, so that the messages of the others
+// are not formatted
+func debugEnabled() bool {
+	return levels.Load().minRank <= loggerDEBUG.rank()
+}
+
 func logHelper(ctx context.Context, level loggerLevel, msg string) {
+	levelConfig := levels.Load()
+	if level.rank() < levelConfig.minRank {
+		return
+	}
+	pc, file, line, ok := runtime.Caller(2)
+	pkg, funcName := splitFuncName(pc, ok)
+	if level != loggerFatal && level.rank() < levelConfig.levelOf(pkg).rank() {
+		return
+	}
+	now := time.Now()
+	if level.rank() <= loggerINFO.rank() && !infoSampler.allow(pc, now) {
+		return
+	}
 	writer := gin.DefaultErrorWriter
 	if level == loggerINFO {
 		writer = gin.DefaultWriter
 	}
-	var requestId string
-	if ctx != nil {
-		rawRequestId := helper.GetRequestID(ctx)
-		if rawRequestId != "" {
-			requestId = fmt.Sprintf(" | %s", rawRequestId)
+	if config.LogFormat == "json" {
+		writeJSON(writer, ctx, level, now, pkg, file, line, funcName, msg)
+	} else {
+		var requestId string
+		if ctx != nil {
+			rawRequestId := helper.GetRequestID(ctx)
+			if rawRequestId != "" {
+				requestId = fmt.Sprintf(" | %s", rawRequestId)
+			}
 		}
+		lineInfo := getLineInfo(file, line, ok)
+		_, _ = fmt.Fprintf(writer, "[%s] %v%s%s [%s] %s \n", level, now.Format("2006/01/02 - 15:04:05"), requestId, lineInfo, funcName, msg)
 	}
-	lineInfo, funcName := getLineInfo()
-	now := time.Now()
-	_, _ = fmt.Fprintf(writer, "[%s] %v%s%s %s%s \n", level, now.Format("2006/01/02 - 15:04:05"), requestId, lineInfo, funcName, msg)
 	SetupLogger()
 	if level == loggerFatal {
 		os.Exit(1)
 	}
 }
 
-func getLineInfo() (string, string) {
-	funcName := "[unknown] "
-	pc, file, line, ok := runtime.Caller(3)
-	if ok {
-		if fn := runtime.FuncForPC(pc); fn != nil {
-			parts := strings.Split(fn.Name(), ".")
-			funcName = "[" + parts[len(parts)-1] + "] "
+// jsonEntry is a line of the json logs
+type jsonEntry struct {
+	Level     string `json:"level"`
+	Time      string `json:"time"`
+	RequestId string `json:"request_id,omitempty"`
+	UserId    int    `json:"user_id,omitempty"`
+	ChannelId int    `json:"channel_id,omitempty"`
+	Model     string `json:"model,omitempty"`
+	Caller    string `json:"caller"`
+	Func      string `json:"func"`
+	Msg       string `json:"msg"`
+}
+
+func writeJSON(writer io.Writer, ctx context.Context, level loggerLevel, now time.Time, pkg string, file string, line int, funcName string, msg string) {
+	entry := jsonEntry{
+		Level:  strings.ToLower(string(level)),
+		Time:   now.Format(time.RFC3339Nano),
+		Caller: fmt.Sprintf("%s/%s:%d", pkg, filepath.Base(file), line),
+		Func:   funcName,
+		Msg:    msg,
+	}
+	if ctx != nil {
+		entry.RequestId = helper.GetRequestID(ctx)
+		if fields := getFields(ctx); fields != nil {
+			fields.mu.RLock()
+			entry.UserId, entry.ChannelId, entry.Model = fields.userId, fields.channelId, fields.model
+			fields.mu.RUnlock()
 		}
-	} else {
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	_, _ = writer.Write(append(data, '\n'))
+}
+
+// splitFuncName returns the package path in the module and the name of the function of pc,
+// e.g. relay/adaptor/openai and Handler
+func splitFuncName(pc uintptr, ok bool) (string, string) {
+	if !ok {
+		return "unknown", "unknown"
+	}
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return "unknown", "unknown"
+	}
+	name := strings.TrimPrefix(fn.Name(), modulePath+"/")
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return name, "unknown"
+	}
+	parts := strings.Split(name, ".")
+	return name[:slash+1+dot], parts[len(parts)-1]
+}
+
+func getLineInfo(file string, line int, ok bool) string {
+	if !ok {
 		file = "unknown"
 		line = 0
 	}
@@ -156,5 +224,5 @@ func getLineInfo() (string, string) {
 	if len(parts) > 1 {
 		file = parts[1]
 	}
-	return fmt.Sprintf(" | %s:%d", file, line), funcName
+	return fmt.Sprintf(" | %s:%d", file, line)
 }
@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+func TestLevels(t *testing.T) {
+	Convey("levels", t, func() {
+		c, err := parseLevels("warn, relay=info, relay/adaptor=debug")
+		So(err, ShouldBeNil)
+		So(c.levelOf("model"), ShouldEqual, loggerWarn)
+		So(c.levelOf("relay/controller"), ShouldEqual, loggerINFO)
+		So(c.levelOf("relay/adaptor/openai"), ShouldEqual, loggerDEBUG)
+		So(c.levelOf("relayx"), ShouldEqual, loggerWarn)
+		So(c.minRank, ShouldEqual, loggerDEBUG.rank())
+
+		_, err = parseLevels("info,model=verbose")
+		So(err, ShouldNotBeNil)
+		So(ValidateLevels("error"), ShouldBeNil)
+	})
+}
+
+func TestJSON(t *testing.T) {
+	Convey("json entry", t, func() {
+		ctx := WithFields(helper.SetRequestID(context.Background(), "req-1"))
+		SetUserId(ctx, 7)
+		SetChannel(ctx, 3, "gpt-4o")
+		pc, file, line, ok := runtime.Caller(0)
+		pkg, funcName := splitFuncName(pc, ok)
+		So(pkg, ShouldEqual, "common/logger")
+
+		var buffer bytes.Buffer
+		writeJSON(&buffer, ctx, loggerWarn, time.Now(), pkg, file, line, funcName, "hello")
+		var entry jsonEntry
+		So(json.Unmarshal(buffer.Bytes(), &entry), ShouldBeNil)
+		So(entry.Level, ShouldEqual, "warn")
+		So(entry.RequestId, ShouldEqual, "req-1")
+		So(entry.UserId, ShouldEqual, 7)
+		So(entry.ChannelId, ShouldEqual, 3)
+		So(entry.Model, ShouldEqual, "gpt-4o")
+		So(entry.Caller, ShouldStartWith, "common/logger/logger_test.go:")
+		So(entry.Msg, ShouldEqual, "hello")
+	})
+}
+
+func TestSampler(t *testing.T) {
+	Convey("sampler", t, func() {
+		initial, thereafter := config.LogSampleInitial, config.LogSampleThereafter
+		Reset(func() {
+			config.LogSampleInitial, config.LogSampleThereafter = initial, thereafter
+		})
+		config.LogSampleInitial, config.LogSampleThereafter = 2, 3
+		s := &sampler{counts: make(map[uintptr]int)}
+		now := time.Unix(1000, 0)
+		var allowed []bool
+		for i := 0; i < 8; i++ {
+			allowed = append(allowed, s.allow(1, now))
+		}
+		So(allowed, ShouldResemble, []bool{true, true, false, false, true, false, false, true})
+		So(s.allow(2, now), ShouldBeTrue)
+		So(s.allow(1, now.Add(time.Second)), ShouldBeTrue)
+	})
+}
+
+func TestRotatingFile(t *testing.T) {
+	Convey("rotating file", t, func() {
+		maxSize, maxAge := config.LogMaxSize, config.LogMaxAge
+		Reset(func() {
+			config.LogMaxSize, config.LogMaxAge = maxSize, maxAge
+		})
+		config.LogMaxSize, config.LogMaxAge = 1, 1
+		dir := t.TempDir()
+		// the daily files of earlier versions are kept, old rotated files are deleted
+		legacy := filepath.Join(dir, "oneapi-20000101.log")
+		old := filepath.Join(dir, "oneapi-20000101-000000.log")
+		for _, path := range []string{legacy, old} {
+			So(os.WriteFile(path, []byte("old"), 0644), ShouldBeNil)
+			So(os.Chtimes(path, time.Now().AddDate(0, 0, -2), time.Now().AddDate(0, 0, -2)), ShouldBeNil)
+		}
+
+		f, err := openRotatingFile(dir)
+		So(err, ShouldBeNil)
+		line := []byte(strings.Repeat("x", 1023) + "\n")
+		for i := 0; i < 1025; i++ {
+			_, err := f.Write(line)
+			So(err, ShouldBeNil)
+		}
+		time.Sleep(100 * time.Millisecond) // pruning runs in the background
+
+		rotated, _ := filepath.Glob(filepath.Join(dir, rotatedFilePattern))
+		So(rotated, ShouldHaveLength, 1)
+		So(rotated[0], ShouldNotEqual, old)
+		So(fileExists(legacy), ShouldBeTrue)
+		info, err := os.Stat(rotated[0])
+		So(err, ShouldBeNil)
+		So(info.Size(), ShouldEqual, 1024*1024)
+		info, err = os.Stat(filepath.Join(dir, logFileName))
+		So(err, ShouldBeNil)
+		So(info.Size(), ShouldEqual, 1024)
+	})
+}
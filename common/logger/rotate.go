@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+const logFileName = "oneapi.log"
+
+// rotatedFilePattern matches the files renamed by rotate, but not the daily oneapi-YYYYMMDD.log files
+// of earlier versions, which are never deleted
+const rotatedFilePattern = "oneapi-????????-??????*.log"
+
+// rotatingFile writes to oneapi.log in the log directory, which is renamed after the time it was opened
+// when it grows beyond LogMaxSize megabytes or the day changes, rotated files older than LogMaxAge days are deleted
+type rotatingFile struct {
+	mu       sync.Mutex
+	dir      string
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+func openRotatingFile(dir string) (*rotatingFile, error) {
+	f := &rotatingFile{dir: dir}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	go f.prune()
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	path := filepath.Join(f.dir, logFileName)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = time.Now()
+	if f.size > 0 {
+		// the file of an earlier run is rotated on the day it was last written to
+		f.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.shouldRotate(len(p)) {
+		if err := f.rotate(); err != nil {
+			// keep writing to the current file
+			_, _ = fmt.Fprintf(os.Stderr, "failed to rotate log file: %v\n", err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) shouldRotate(size int) bool {
+	if f.size == 0 {
+		return false
+	}
+	if config.LogMaxSize > 0 && f.size+int64(size) > int64(config.LogMaxSize)*1024*1024 {
+		return true
+	}
+	return !config.OnlyOneLogFile && time.Now().Format("20060102") != f.openedAt.Format("20060102")
+}
+
+func (f *rotatingFile) rotate() error {
+	path := filepath.Join(f.dir, logFileName)
+	name := "oneapi-" + f.openedAt.Format("20060102-150405")
+	rotatedPath := filepath.Join(f.dir, name+".log")
+	for i := 1; fileExists(rotatedPath); i++ {
+		rotatedPath = filepath.Join(f.dir, fmt.Sprintf("%s-%d.log", name, i))
+	}
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path, rotatedPath); err != nil {
+		// reopen the current file so that logs are not lost
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	go f.prune()
+	return nil
+}
+
+// prune deletes the rotated files older than LogMaxAge days
+func (f *rotatingFile) prune() {
+	if config.LogMaxAge <= 0 {
+		return
+	}
+	paths, err := filepath.Glob(filepath.Join(f.dir, rotatedFilePattern))
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -config.LogMaxAge)
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+			_ = os.Remove(path)
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
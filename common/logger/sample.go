@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+// sampler counts the entries of each line of code within the current second
+type sampler struct {
+	mu     sync.Mutex
+	second int64
+	counts map[uintptr]int
+}
+
+var infoSampler = &sampler{counts: make(map[uintptr]int)}
+
+// allow reports whether the entry of the line of code pc is logged, the first LogSampleInitial entries
+// of each second are, then one in LogSampleThereafter
+func (s *sampler) allow(pc uintptr, now time.Time) bool {
+	initial := config.LogSampleInitial
+	if initial <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if second := now.Unix(); second != s.second {
+		s.second = second
+		clear(s.counts)
+	}
+	s.counts[pc]++
+	count := s.counts[pc]
+	if count <= initial {
+		return true
+	}
+	thereafter := config.LogSampleThereafter
+	return thereafter > 0 && (count-initial)%thereafter == 0
+}
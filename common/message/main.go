@@ -20,3 +20,11 @@ func Notify(by string, title string, description string, content string) error {
 	}
 	return fmt.Errorf("unknown notify method: %s", by)
 }
+
+// NotifyUser emails a notice built from the template to a user, users without an email are skipped
+func NotifyUser(email string, title string, content string) error {
+	if email == "" {
+		return nil
+	}
+	return SendEmail(title, email, EmailTemplate(title, content))
+}
@@ -0,0 +1,461 @@
+// Package qrcode is a small QR code encoder for short texts such as otpauth:// uris,
+// it supports byte mode with error correction level M up to version 10 (213 bytes).
+package qrcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+var ErrTooLong = errors.New("text is too long for a QR code")
+
+type versionInfo struct {
+	ecPerBlock int
+	blocks     []int // data codewords of each block
+	alignments []int
+}
+
+// error correction level M
+var versions = []versionInfo{
+	1:  {10, []int{16}, nil},
+	2:  {16, []int{28}, []int{6, 18}},
+	3:  {26, []int{44}, []int{6, 22}},
+	4:  {18, []int{32, 32}, []int{6, 26}},
+	5:  {24, []int{43, 43}, []int{6, 30}},
+	6:  {16, []int{27, 27, 27, 27}, []int{6, 34}},
+	7:  {18, []int{31, 31, 31, 31}, []int{6, 22, 38}},
+	8:  {22, []int{38, 38, 39, 39}, []int{6, 24, 42}},
+	9:  {22, []int{36, 36, 36, 37, 37}, []int{6, 26, 46}},
+	10: {26, []int{43, 43, 43, 43, 44}, []int{6, 28, 50}},
+}
+
+const ecLevelM = 0
+
+func (v versionInfo) dataCodewords() int {
+	total := 0
+	for _, block := range v.blocks {
+		total += block
+	}
+	return total
+}
+
+// Code is a QR code matrix, true means a dark module
+type Code struct {
+	Size    int
+	modules [][]bool
+	// function marks modules that are not part of the data area
+	function [][]bool
+}
+
+func (q *Code) Get(x, y int) bool {
+	return q.modules[y][x]
+}
+
+func (q *Code) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	version := 0
+	for v := 1; v < len(versions); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= versions[v].dataCodewords()*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+	codewords := addErrorCorrection(encodeData(data, version), versions[version])
+	size := version*4 + 17
+	q := &Code{Size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.function[i] = make([]bool, size)
+	}
+	q.drawFunctionPatterns(version)
+	q.drawCodewords(codewords)
+
+	// pick the mask with the lowest penalty
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		if penalty := q.penalty(); bestPenalty == -1 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(bestMask)
+	q.drawFormatBits(bestMask)
+	return q, nil
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value int, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, (value>>i)&1 == 1)
+	}
+}
+
+func encodeData(data []byte, version int) []byte {
+	capacity := versions[version].dataCodewords() * 8
+	bits := bitBuffer{}
+	bits.append(0b0100, 4) // byte mode
+	if version >= 10 {
+		bits.append(len(data), 16)
+	} else {
+		bits.append(len(data), 8)
+	}
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	result := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			result[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return result
+}
+
+// GF(256) arithmetic with the primitive polynomial x^8 + x^4 + x^3 + x^2 + 1
+var gfExp, gfLog = func() ([512]byte, [256]byte) {
+	var exp [512]byte
+	var log [256]byte
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func reedSolomon(data []byte, ecLength int) []byte {
+	// generator polynomial (x - a^0)(x - a^1)...(x - a^(n-1)), highest degree first without the leading 1
+	generator := make([]byte, ecLength)
+	generator[ecLength-1] = 1
+	root := byte(1)
+	for i := 0; i < ecLength; i++ {
+		for j := 0; j < ecLength; j++ {
+			generator[j] = gfMul(generator[j], root)
+			if j+1 < ecLength {
+				generator[j] ^= generator[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	remainder := make([]byte, ecLength)
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[ecLength-1] = 0
+		for i := range remainder {
+			remainder[i] ^= gfMul(generator[i], factor)
+		}
+	}
+	return remainder
+}
+
+func addErrorCorrection(data []byte, info versionInfo) []byte {
+	var dataBlocks, ecBlocks [][]byte
+	offset, maxBlock := 0, 0
+	for _, length := range info.blocks {
+		block := data[offset : offset+length]
+		offset += length
+		dataBlocks = append(dataBlocks, block)
+		ecBlocks = append(ecBlocks, reedSolomon(block, info.ecPerBlock))
+		if length > maxBlock {
+			maxBlock = length
+		}
+	}
+	var result []byte
+	for i := 0; i < maxBlock; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < info.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+func (q *Code) drawFunctionPatterns(version int) {
+	// timing patterns
+	for i := 0; i < q.Size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	// finder patterns with separators
+	for _, corner := range [][2]int{{3, 3}, {q.Size - 4, 3}, {3, q.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := corner[0]+dx, corner[1]+dy
+				if x < 0 || y < 0 || x >= q.Size || y >= q.Size {
+					continue
+				}
+				distance := max(abs(dx), abs(dy))
+				q.set(x, y, distance != 2 && distance != 4)
+			}
+		}
+	}
+	// alignment patterns
+	alignments := versions[version].alignments
+	for i, cx := range alignments {
+		for j, cy := range alignments {
+			if (i == 0 && j == 0) || (i == 0 && j == len(alignments)-1) || (i == len(alignments)-1 && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	// reserve format areas, they are drawn with the mask
+	q.drawFormatBits(0)
+	// version information
+	if version >= 7 {
+		remainder := version
+		for i := 0; i < 12; i++ {
+			remainder = (remainder << 1) ^ ((remainder >> 11) * 0x1F25)
+		}
+		bits := version<<12 | remainder
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 == 1
+			a, b := q.Size-11+i%3, i/3
+			q.set(a, b, dark)
+			q.set(b, a, dark)
+		}
+	}
+}
+
+func (q *Code) drawFormatBits(mask int) {
+	data := ecLevelM<<3 | mask
+	remainder := data
+	for i := 0; i < 10; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 9) * 0x537)
+	}
+	bits := (data<<10 | remainder) ^ 0x5412
+	bit := func(i int) bool {
+		return (bits>>i)&1 == 1
+	}
+	// around the top left finder
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+	// the copy split between the other two finders
+	for i := 0; i < 8; i++ {
+		q.set(q.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.Size-15+i, bit(i))
+	}
+	q.set(8, q.Size-8, true) // dark module
+}
+
+func (q *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				upward := (right+1)&2 == 0
+				y := vert
+				if upward {
+					y = q.Size - 1 - vert
+				}
+				if q.function[y][x] {
+					continue
+				}
+				if i < len(codewords)*8 {
+					q.modules[y][x] = (codewords[i>>3]>>(7-i&7))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (q *Code) applyMask(mask int) {
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if q.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+func (q *Code) penalty() int {
+	penalty := 0
+	finderLike := [][]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+	for _, horizontal := range []bool{true, false} {
+		at := func(a, b int) bool {
+			if horizontal {
+				return q.modules[a][b]
+			}
+			return q.modules[b][a]
+		}
+		for a := 0; a < q.Size; a++ {
+			run := 1
+			for b := 1; b <= q.Size; b++ {
+				if b < q.Size && at(a, b) == at(a, b-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += run - 2
+				}
+				run = 1
+			}
+			for b := 0; b+11 <= q.Size; b++ {
+				for _, pattern := range finderLike {
+					matched := true
+					for k, dark := range pattern {
+						if at(a, b+k) != dark {
+							matched = false
+							break
+						}
+					}
+					if matched {
+						penalty += 40
+					}
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < q.Size && y+1 < q.Size {
+				c := q.modules[y][x]
+				if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+	total := q.Size * q.Size
+	penalty += abs(dark*20-total*10) / total * 10
+	return penalty
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// PNG renders the code with a 4 module quiet zone
+func (q *Code) PNG(scale int) ([]byte, error) {
+	const quietZone = 4
+	size := (q.Size + quietZone*2) * scale
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			mx, my := x/scale-quietZone, y/scale-quietZone
+			c := color.Gray{Y: 255}
+			if mx >= 0 && my >= 0 && mx < q.Size && my < q.Size && q.modules[my][mx] {
+				c = color.Gray{Y: 0}
+			}
+			img.SetGray(x, y, c)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DataURL returns the code as a base64 png data url that can be used in an img tag
+func DataURL(text string, scale int) (string, error) {
+	q, err := Encode(text)
+	if err != nil {
+		return "", err
+	}
+	data, err := q.PNG(scale)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,57 @@
+package qrcode
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQRCode(t *testing.T) {
+	Convey("QR code", t, func() {
+		Convey("reed solomon", func() {
+			data := []byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+			So(reedSolomon(data, 10), ShouldResemble, []byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55})
+		})
+
+		Convey("format and version information", func() {
+			q := &Code{Size: 45, modules: make([][]bool, 45), function: make([][]bool, 45)}
+			for i := range q.modules {
+				q.modules[i] = make([]bool, 45)
+				q.function[i] = make([]bool, 45)
+			}
+			q.drawFormatBits(0)
+			var format strings.Builder
+			for i := 0; i <= 5; i++ {
+				format.WriteByte(map[bool]byte{true: '1', false: '0'}[q.Get(8, i)])
+			}
+			// bits 0..5 of 101010000010010, least significant first
+			So(format.String(), ShouldEqual, "010010")
+
+			q.drawFunctionPatterns(7)
+			var version strings.Builder
+			for i := 17; i >= 0; i-- {
+				version.WriteByte(map[bool]byte{true: '1', false: '0'}[q.Get(q.Size-11+i%3, i/3)])
+			}
+			So(version.String(), ShouldEqual, "000111110010010100")
+		})
+
+		Convey("encode", func() {
+			q, err := Encode("otpauth://totp/One%20API:root?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=One+API")
+			So(err, ShouldBeNil)
+			So(q.Size, ShouldEqual, 5*4+17)
+			// finder pattern corners and the dark module
+			So(q.Get(0, 0), ShouldBeTrue)
+			So(q.Get(q.Size-1, 0), ShouldBeTrue)
+			So(q.Get(0, q.Size-1), ShouldBeTrue)
+			So(q.Get(8, q.Size-8), ShouldBeTrue)
+
+			url, err := DataURL("hello", 4)
+			So(err, ShouldBeNil)
+			So(url, ShouldStartWith, "data:image/png;base64,")
+
+			_, err = Encode(strings.Repeat("a", 300))
+			So(err, ShouldEqual, ErrTooLong)
+		})
+	})
+}
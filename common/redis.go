@@ -79,3 +79,14 @@ func RedisDecrease(key string, value int64) error {
 	ctx := context.Background()
 	return RDB.DecrBy(ctx, key, value).Err()
 }
+
+func RedisPublish(channel string, message string) error {
+	ctx := context.Background()
+	return RDB.Publish(ctx, channel, message).Err()
+}
+
+// RedisSubscribe subscribes to the channels, the subscription reconnects by itself
+func RedisSubscribe(channels ...string) *redis.PubSub {
+	ctx := context.Background()
+	return RDB.(redis.UniversalClient).Subscribe(ctx, channels...)
+}
@@ -0,0 +1,219 @@
+// Package secret encrypts stored credentials with envelope encryption:
+// every value is encrypted with its own random data key, which is in turn
+// encrypted with the master key, so rotating the master key only re-wraps data keys.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/songquanpeng/one-api/common/env"
+)
+
+const prefix = "enc:v1:"
+
+var (
+	ErrMasterKeyNotSet = errors.New("master key is not set")
+	ErrUnknownKey      = errors.New("encrypted with an unknown master key")
+)
+
+type masterKey struct {
+	id  string
+	key []byte
+}
+
+var (
+	current  *masterKey
+	previous = map[string]*masterKey{}
+)
+
+func newMasterKey(value string) *masterKey {
+	key := sha256.Sum256([]byte(value))
+	id := sha256.Sum256(key[:])
+	return &masterKey{id: hex.EncodeToString(id[:4]), key: key[:]}
+}
+
+// Init loads the master key from CHANNEL_MASTER_KEY or CHANNEL_MASTER_KEY_FILE,
+// CHANNEL_PREVIOUS_MASTER_KEYS lists old keys that can still decrypt during rotation
+func Init() error {
+	value := env.String("CHANNEL_MASTER_KEY", "")
+	if path := env.String("CHANNEL_MASTER_KEY_FILE", ""); value == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Wrap(err, "read master key file")
+		}
+		value = strings.TrimSpace(string(data))
+	}
+	var previousValues []string
+	if values := env.String("CHANNEL_PREVIOUS_MASTER_KEYS", ""); values != "" {
+		previousValues = strings.Split(values, ",")
+	}
+	SetMasterKey(value, previousValues...)
+	return nil
+}
+
+// SetMasterKey replaces the loaded keys, an empty value disables encryption
+func SetMasterKey(value string, previousValues ...string) {
+	current = nil
+	previous = map[string]*masterKey{}
+	if value != "" {
+		current = newMasterKey(value)
+	}
+	for _, previousValue := range previousValues {
+		previousValue = strings.TrimSpace(previousValue)
+		if previousValue == "" {
+			continue
+		}
+		key := newMasterKey(previousValue)
+		previous[key.id] = key
+	}
+}
+
+func Enabled() bool {
+	return current != nil
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func seal(key []byte, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key []byte, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], nil)
+}
+
+func wrap(dataKey []byte, data []byte) (string, error) {
+	wrappedKey, err := seal(current.key, dataKey)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s:%s:%s", prefix, current.id,
+		base64.RawStdEncoding.EncodeToString(wrappedKey),
+		base64.RawStdEncoding.EncodeToString(data)), nil
+}
+
+// unwrap returns the data key and the encrypted data of an encrypted value
+func unwrap(value string) (dataKey []byte, data []byte, keyId string, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return nil, nil, "", errors.New("invalid encrypted value")
+	}
+	keyId = parts[0]
+	key := previous[keyId]
+	if current != nil && current.id == keyId {
+		key = current
+	}
+	if key == nil {
+		return nil, nil, keyId, ErrUnknownKey
+	}
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, keyId, err
+	}
+	data, err = base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, keyId, err
+	}
+	dataKey, err = open(key.key, wrappedKey)
+	if err != nil {
+		return nil, nil, keyId, errors.Wrap(err, "unwrap data key")
+	}
+	return dataKey, data, keyId, nil
+}
+
+// Encrypt returns the value unchanged if encryption is disabled or the value is empty
+func Encrypt(plaintext string) (string, error) {
+	if current == nil || plaintext == "" || IsEncrypted(plaintext) {
+		return plaintext, nil
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	data, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return wrap(dataKey, data)
+}
+
+// Decrypt returns plain values as is, so data written before encryption was enabled keeps working
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if current == nil && len(previous) == 0 {
+		return "", ErrMasterKeyNotSet
+	}
+	dataKey, data, _, err := unwrap(value)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, data)
+	if err != nil {
+		return "", errors.Wrap(err, "decrypt value")
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether the value is plain or not encrypted with the current master key
+func NeedsRotation(value string) bool {
+	if current == nil || value == "" {
+		return false
+	}
+	if !IsEncrypted(value) {
+		return true
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, prefix), ":", 2)
+	return parts[0] != current.id
+}
+
+// Rotate encrypts plain values and re-wraps the data key of values encrypted with a previous master key
+func Rotate(value string) (string, error) {
+	if !NeedsRotation(value) {
+		return value, nil
+	}
+	if !IsEncrypted(value) {
+		return Encrypt(value)
+	}
+	dataKey, data, _, err := unwrap(value)
+	if err != nil {
+		return "", err
+	}
+	return wrap(dataKey, data)
+}
@@ -0,0 +1,59 @@
+package secret
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSecret(t *testing.T) {
+	Convey("secret", t, func() {
+		Reset(func() {
+			SetMasterKey("")
+		})
+
+		Convey("disabled", func() {
+			SetMasterKey("")
+			value, err := Encrypt("sk-plain")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "sk-plain")
+			So(NeedsRotation(value), ShouldBeFalse)
+		})
+
+		Convey("encrypt and decrypt", func() {
+			SetMasterKey("master-1")
+			value, err := Encrypt("sk-secret")
+			So(err, ShouldBeNil)
+			So(IsEncrypted(value), ShouldBeTrue)
+			So(value, ShouldNotContainSubstring, "sk-secret")
+			plaintext, err := Decrypt(value)
+			So(err, ShouldBeNil)
+			So(plaintext, ShouldEqual, "sk-secret")
+
+			plaintext, err = Decrypt("sk-legacy")
+			So(err, ShouldBeNil)
+			So(plaintext, ShouldEqual, "sk-legacy")
+			So(NeedsRotation("sk-legacy"), ShouldBeTrue)
+		})
+
+		Convey("rotate", func() {
+			SetMasterKey("master-1")
+			value, _ := Encrypt("sk-secret")
+
+			SetMasterKey("master-2")
+			_, err := Decrypt(value)
+			So(err, ShouldEqual, ErrUnknownKey)
+
+			SetMasterKey("master-2", "master-1")
+			So(NeedsRotation(value), ShouldBeTrue)
+			rotated, err := Rotate(value)
+			So(err, ShouldBeNil)
+			So(NeedsRotation(rotated), ShouldBeFalse)
+
+			SetMasterKey("master-2")
+			plaintext, err := Decrypt(rotated)
+			So(err, ShouldBeNil)
+			So(plaintext, ShouldEqual, "sk-secret")
+		})
+	})
+}
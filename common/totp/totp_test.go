@@ -0,0 +1,50 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// base32 of the RFC 6238 test secret "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTP(t *testing.T) {
+	Convey("TOTP", t, func() {
+		Convey("RFC 6238 test vectors", func() {
+			for unix, code := range map[int64]string{
+				59:         "287082",
+				1111111109: "081804",
+				1234567890: "005924",
+				2000000000: "279037",
+			} {
+				generated, err := GenerateCode(rfcSecret, Step(time.Unix(unix, 0)))
+				So(err, ShouldBeNil)
+				So(generated, ShouldEqual, code)
+			}
+		})
+
+		Convey("validate with skew", func() {
+			now := time.Unix(1111111109, 0)
+			step, ok := Validate(rfcSecret, "081804", now.Add(Period*time.Second))
+			So(ok, ShouldBeTrue)
+			So(step, ShouldEqual, Step(now))
+			_, ok = Validate(rfcSecret, "081804", now.Add(3*Period*time.Second))
+			So(ok, ShouldBeFalse)
+			_, ok = Validate(rfcSecret, "12345", now)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("generated secret and uri", func() {
+			secret, err := GenerateSecret()
+			So(err, ShouldBeNil)
+			So(len(secret), ShouldEqual, 32)
+			uri := URI("One API", "root", secret)
+			So(uri, ShouldStartWith, "otpauth://totp/One%20API:root?")
+			So(uri, ShouldContainSubstring, "secret="+secret)
+			So(strings.Count(uri, "issuer="), ShouldEqual, 1)
+		})
+	})
+}
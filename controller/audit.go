@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// recordAudit records an admin action, before and after are the target before and after the change,
+// nil for created or deleted targets
+func recordAudit(c *gin.Context, action string, targetType string, targetId any, before any, after any) {
+	model.RecordAuditLog(c.Request.Context(), &model.AuditLog{
+		ActorId:    c.GetInt(ctxkey.Id),
+		ActorName:  c.GetString(ctxkey.Username),
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   fmt.Sprint(targetId),
+		Diff:       model.AuditDiff(before, after),
+		Ip:         c.ClientIP(),
+	})
+}
+
+func GetAuditLogs(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	filter := model.AuditLogFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetId:   c.Query("target_id"),
+	}
+	filter.ActorId, _ = strconv.Atoi(c.Query("actor_id"))
+	filter.StartTimestamp, _ = strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	filter.EndTimestamp, _ = strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	logs, err := model.GetAuditLogs(filter, p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    logs,
+	})
+}
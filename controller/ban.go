@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/blacklist"
+	"github.com/songquanpeng/one-api/model"
+)
+
+func GetBans(c *gin.Context) {
+	bans, err := blacklist.GetBans()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    bans,
+	})
+}
+
+// LiftBan removes the ban given by the type and value query parameters
+func LiftBan(c *gin.Context) {
+	banType := c.Query("type")
+	value := c.Query("value")
+	if (banType != blacklist.BanTypeIp && banType != blacklist.BanTypeToken) || value == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if err := blacklist.Lift(banType, value); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	recordAudit(c, model.AuditActionBanLift, model.AuditTargetBan, banType+":"+value, nil, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
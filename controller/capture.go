@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/model"
+)
+
+// GetRequestCapture returns the captured bodies of a request by the request id of its consume log
+func GetRequestCapture(c *gin.Context) {
+	requestId := c.Param("request_id")
+	capture, err := model.GetRequestCapture(requestId)
+	if err != nil {
+		message := err.Error()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			message = "该请求未被记录或记录已过期"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	recordAudit(c, model.AuditActionCaptureView, model.AuditTargetCapture, requestId, nil, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    capture,
+	})
+}
@@ -309,6 +309,11 @@ func updateChannelOpenRouterBalance(channel *model.Channel) (float64, error) {
 }
 
 func updateChannelBalance(channel *model.Channel) (float64, error) {
+	key, err := channel.GetDecryptedKey()
+	if err != nil {
+		return 0, err
+	}
+	channel.Key = key
 	baseURL := channeltype.ChannelBaseURLs[channel.Type]
 	if channel.GetBaseURL() == "" {
 		channel.BaseURL = &baseURL
@@ -1,8 +1,10 @@
 package controller
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/model"
 	"net/http"
@@ -23,6 +25,9 @@ func GetAllChannels(c *gin.Context) {
 		})
 		return
 	}
+	for _, channel := range channels {
+		channel.RedactSecrets()
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -41,6 +46,9 @@ func SearchChannels(c *gin.Context) {
 		})
 		return
 	}
+	for _, channel := range channels {
+		channel.RedactSecrets()
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -66,6 +74,7 @@ func GetChannel(c *gin.Context) {
 		})
 		return
 	}
+	channel.RedactSecrets()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -103,6 +112,9 @@ func AddChannel(c *gin.Context) {
 		})
 		return
 	}
+	for i := range channels {
+		recordAudit(c, model.AuditActionChannelCreate, model.AuditTargetChannel, channels[i].Id, nil, &channels[i])
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -112,6 +124,7 @@ func AddChannel(c *gin.Context) {
 
 func DeleteChannel(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	origin, _ := model.GetChannelById(id, true)
 	channel := model.Channel{Id: id}
 	err := channel.Delete()
 	if err != nil {
@@ -121,6 +134,7 @@ func DeleteChannel(c *gin.Context) {
 		})
 		return
 	}
+	recordAudit(c, model.AuditActionChannelDelete, model.AuditTargetChannel, id, origin, nil)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -137,6 +151,7 @@ func DeleteDisabledChannel(c *gin.Context) {
 		})
 		return
 	}
+	recordAudit(c, model.AuditActionChannelDeleteDisabled, model.AuditTargetChannel, "", nil, gin.H{"deleted": rows})
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -155,6 +170,7 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	origin, _ := model.GetChannelById(channel.Id, true)
 	err = channel.Update()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -163,6 +179,10 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	if updated, err := model.GetChannelById(channel.Id, true); err == nil {
+		recordAudit(c, model.AuditActionChannelUpdate, model.AuditTargetChannel, channel.Id, origin, updated)
+	}
+	channel.RedactSecrets()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -170,3 +190,70 @@ func UpdateChannel(c *gin.Context) {
 	})
 	return
 }
+
+// RevealChannelKey returns the decrypted secrets of a channel, every call is recorded
+func RevealChannelKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	channel, err := model.GetChannelById(id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	key, err := channel.GetDecryptedKey()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	cfg, err := channel.LoadDecryptedConfig()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	model.RecordLog(c.Request.Context(), c.GetInt(ctxkey.Id), model.LogTypeManage, fmt.Sprintf("查看了渠道 #%d（%s）的密钥，IP：%s", channel.Id, channel.Name, c.ClientIP()))
+	recordAudit(c, model.AuditActionChannelRevealKey, model.AuditTargetChannel, channel.Id, nil, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"key":    key,
+			"config": cfg,
+		},
+	})
+	return
+}
+
+// RotateChannelKeys re-encrypts all channel secrets with the current master key
+func RotateChannelKeys(c *gin.Context) {
+	count, err := model.RotateChannelSecrets()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	model.RecordLog(c.Request.Context(), c.GetInt(ctxkey.Id), model.LogTypeManage, fmt.Sprintf("重新加密了 %d 个渠道的密钥", count))
+	recordAudit(c, model.AuditActionChannelRotateKeys, model.AuditTargetChannel, "", nil, gin.H{"rotated": count})
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    count,
+	})
+	return
+}
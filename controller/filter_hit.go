@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/model"
+)
+
+func getFilterHitFilter(c *gin.Context) model.FilterHitFilter {
+	filter := model.FilterHitFilter{
+		Category:  c.Query("category"),
+		Direction: c.Query("direction"),
+	}
+	filter.UserId, _ = strconv.Atoi(c.Query("user_id"))
+	filter.TokenId, _ = strconv.Atoi(c.Query("token_id"))
+	filter.RuleId, _ = strconv.Atoi(c.Query("rule_id"))
+	filter.StartTimestamp, _ = strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	filter.EndTimestamp, _ = strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	return filter
+}
+
+func GetFilterHits(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	hits, err := model.GetFilterHits(getFilterHitFilter(c), p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    hits,
+	})
+}
+
+// GetFilterHitStats counts the hits by rule, user or day, e.g. /api/filter/hit/stat?by=user
+func GetFilterHitStats(c *gin.Context) {
+	by := c.DefaultQuery("by", model.FilterHitStatByRule)
+	stats, err := model.GetFilterHitStats(getFilterHitFilter(c), by)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    stats,
+	})
+}
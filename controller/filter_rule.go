@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/model"
+)
+
+func GetFilterRules(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	rules, err := model.GetFilterRules(p*config.ItemsPerPage, config.ItemsPerPage, c.Query("category"), c.Query("keyword"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    rules,
+	})
+}
+
+func GetFilterRule(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	rule, err := model.GetFilterRuleById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    rule,
+	})
+}
+
+func AddFilterRule(c *gin.Context) {
+	rule := model.FilterRule{}
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	rule.Id = 0
+	if err := rule.Insert(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	recordAudit(c, model.AuditActionFilterRuleCreate, model.AuditTargetFilterRule, rule.Id, nil, &rule)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    rule,
+	})
+}
+
+func UpdateFilterRule(c *gin.Context) {
+	rule := model.FilterRule{}
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	origin, err := model.GetFilterRuleById(rule.Id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := rule.Update(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	rule.CreatedTime = origin.CreatedTime
+	recordAudit(c, model.AuditActionFilterRuleUpdate, model.AuditTargetFilterRule, rule.Id, origin, &rule)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    rule,
+	})
+}
+
+func DeleteFilterRule(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	origin, err := model.GetFilterRuleById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := model.DeleteFilterRuleById(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	recordAudit(c, model.AuditActionFilterRuleDelete, model.AuditTargetFilterRule, id, origin, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
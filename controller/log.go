@@ -167,3 +167,33 @@ func DeleteHistoryLogs(c *gin.Context) {
 	})
 	return
 }
+
+func GetLogArchives(c *gin.Context) {
+	archives, err := model.GetLogArchives()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    archives,
+	})
+}
+
+// DownloadLogArchive sends an archive of deleted logs, a gzipped file of a json line per log
+func DownloadLogArchive(c *gin.Context) {
+	name := c.Param("name")
+	path, err := model.GetLogArchivePath(name)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.FileAttachment(path, name)
+}
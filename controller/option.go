@@ -2,13 +2,17 @@ package controller
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/i18n"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/network"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/pii"
 
 	"github.com/gin-gonic/gin"
 )
@@ -85,7 +89,58 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "IpAllowList", "IpDenyList":
+		if err := validateIpListOption(c, option.Key, option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "PiiRedactionRules":
+		if err := pii.ValidateRules(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "CaptureUserIds", "CaptureTokenIds", "CaptureSampleRate", "CaptureMaxBytes":
+		if err := model.ValidateCaptureOption(option.Key, option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "LogRetentionDays":
+		if _, err := model.ParseLogRetention(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "LogLevel":
+		if err := logger.ValidateLevels(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "TokenLeakAction":
+		if !model.IsValidTokenLeakAction(option.Value) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无效的令牌泄露处理方式",
+			})
+			return
+		}
 	}
+	config.OptionMapRWMutex.RLock()
+	before := config.OptionMap[option.Key]
+	config.OptionMapRWMutex.RUnlock()
 	err = model.UpdateOption(option.Key, option.Value)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -94,9 +149,26 @@ func UpdateOption(c *gin.Context) {
 		})
 		return
 	}
+	recordAudit(c, model.AuditActionOptionUpdate, model.AuditTargetOption, option.Key,
+		gin.H{option.Key: before}, gin.H{option.Key: option.Value})
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
 	})
 	return
 }
+
+// validateIpListOption checks the cidr list and refuses lists that would lock out the admin saving them
+func validateIpListOption(c *gin.Context, key string, value string) error {
+	if value == "" {
+		return nil
+	}
+	if err := network.IsValidSubnets(value); err != nil {
+		return fmt.Errorf("无效的网段：%s", err.Error())
+	}
+	inList := network.IsIpInSubnets(c.Request.Context(), c.ClientIP(), value)
+	if (key == "IpAllowList" && !inList) || (key == "IpDenyList" && inList) {
+		return fmt.Errorf("保存后当前 IP（%s）将无法访问，请检查网段设置", c.ClientIP())
+	}
+	return nil
+}
@@ -126,6 +126,7 @@ func AddRedemption(c *gin.Context) {
 			return
 		}
 		keys = append(keys, key)
+		recordAudit(c, model.AuditActionRedemptionCreate, model.AuditTargetRedemption, cleanRedemption.Id, nil, &cleanRedemption)
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -28,12 +28,19 @@ func relayHelper(c *gin.Context, relayMode int) *model.ErrorWithStatusCode {
 	switch relayMode {
 	case relaymode.ImagesGenerations:
 		err = controller.RelayImageHelper(c, relayMode)
+	case relaymode.ImagesEdits,
+		relaymode.ImagesVariations:
+		err = controller.RelayImageEditHelper(c, relayMode)
 	case relaymode.AudioSpeech:
 		fallthrough
 	case relaymode.AudioTranslation:
 		fallthrough
 	case relaymode.AudioTranscription:
 		err = controller.RelayAudioHelper(c, relayMode)
+	case relaymode.Rerank:
+		err = controller.RelayRerankHelper(c)
+	case relaymode.Realtime:
+		err = controller.RelayRealtimeHelper(c)
 	case relaymode.Proxy:
 		err = controller.RelayProxyHelper(c, relayMode)
 	default:
@@ -80,6 +87,8 @@ func Relay(c *gin.Context) {
 		middleware.SetupContextForSelectedChannel(c, channel, originalModel)
 		requestBody, err := common.GetRequestBody(c)
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		// the parsed request has been changed by the failed attempt, parse it again
+		c.Set(ctxkey.ParsedRequestBody, nil)
 		bizErr = relayHelper(c, relayMode)
 		if bizErr == nil {
 			return
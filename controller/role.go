@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/model"
+)
+
+func GetAllRoles(c *gin.Context) {
+	roles, err := model.GetAllRoles()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    roles,
+	})
+}
+
+func GetAllPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.AllPermissions,
+	})
+}
+
+func AddRole(c *gin.Context) {
+	role := model.Role{}
+	if err := c.ShouldBindJSON(&role); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	role.Id = 0
+	if err := role.Insert(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	recordAudit(c, model.AuditActionRoleCreate, model.AuditTargetRole, role.Id, nil, &role)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    role,
+	})
+}
+
+func UpdateRole(c *gin.Context) {
+	role := model.Role{}
+	if err := c.ShouldBindJSON(&role); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	origin, err := model.GetRoleById(role.Id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := role.Update(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	role.CreatedTime = origin.CreatedTime
+	recordAudit(c, model.AuditActionRoleUpdate, model.AuditTargetRole, role.Id, origin, &role)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    role,
+	})
+}
+
+func DeleteRole(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	origin, err := model.GetRoleById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := model.DeleteRoleById(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	recordAudit(c, model.AuditActionRoleDelete, model.AuditTargetRole, id, origin, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
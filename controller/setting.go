@@ -2,11 +2,14 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/middleware"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/filter"
 )
 
 type Setting struct {
@@ -24,7 +27,10 @@ func UpdateSensitiveFilterSetting(c *gin.Context) {
 		})
 		return
 	}
-	
+
+	config.OptionMapRWMutex.RLock()
+	before := config.OptionMap[setting.Key]
+	config.OptionMapRWMutex.RUnlock()
 	if setting.Key == "SensitiveFilterEnabled" {
 		err := model.UpdateOption("SensitiveFilterEnabled", setting.Value)
 		if err != nil {
@@ -35,10 +41,74 @@ func UpdateSensitiveFilterSetting(c *gin.Context) {
 			return
 		}
 	} else if setting.Key == "SensitiveWords" {
-		// 更新敏感词列表
-		middleware.UpdateSensitiveWords(setting.Value)
-		// 更新数据库中的配置（可选，因为已经保存到文件中）
-		err := model.UpdateOption("SensitiveWords", setting.Value)
+		// 敏感词已改为过滤规则，通过 /api/filter/rule/ 管理
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "敏感词已改为过滤规则管理，请使用 /api/filter/rule/ 接口",
+		})
+		return
+	} else if setting.Key == "SensitiveFilterCategoryResponses" {
+		var responses map[string]string
+		if err := json.Unmarshal([]byte(setting.Value), &responses); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "分类响应必须是分类到响应文本的 JSON 对象",
+			})
+			return
+		}
+		err := model.UpdateOption("SensitiveFilterCategoryResponses", setting.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	} else if setting.Key == "SensitiveFilterPolicies" {
+		err := model.UpdateOption("SensitiveFilterPolicies", setting.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	} else if setting.Key == "SensitiveFilterGroupPolicies" {
+		var groupPolicies map[string]string
+		if err := json.Unmarshal([]byte(setting.Value), &groupPolicies); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "分组策略必须是分组到策略名称的 JSON 对象",
+			})
+			return
+		}
+		for _, name := range groupPolicies {
+			if err := filter.ValidatePolicyName(name); err != nil {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": err.Error(),
+				})
+				return
+			}
+		}
+		err := model.UpdateOption("SensitiveFilterGroupPolicies", setting.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	} else if setting.Key == "SensitiveFilterModeration" {
+		moderation, err := filter.ParseModeration(setting.Value)
+		if err == nil && moderation != nil {
+			if _, userErr := model.GetUserById(moderation.UserId, false); userErr != nil {
+				err = errors.New("计费的系统账户不存在")
+			}
+		}
+		if err == nil {
+			err = model.UpdateOption("SensitiveFilterModeration", setting.Value)
+		}
 		if err != nil {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
@@ -65,7 +135,9 @@ func UpdateSensitiveFilterSetting(c *gin.Context) {
 		})
 		return
 	}
-	
+	recordAudit(c, model.AuditActionSensitiveFilterUpdate, model.AuditTargetOption, setting.Key,
+		gin.H{setting.Key: before}, gin.H{setting.Key: setting.Value})
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
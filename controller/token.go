@@ -9,6 +9,8 @@ import (
 	"github.com/songquanpeng/one-api/common/network"
 	"github.com/songquanpeng/one-api/common/random"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/filter"
+	"github.com/songquanpeng/one-api/relay/pii"
 	"net/http"
 	"strconv"
 )
@@ -30,6 +32,9 @@ func GetAllTokens(c *gin.Context) {
 		})
 		return
 	}
+	for _, token := range tokens {
+		token.MaskKey()
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -49,6 +54,9 @@ func SearchTokens(c *gin.Context) {
 		})
 		return
 	}
+	for _, token := range tokens {
+		token.MaskKey()
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -75,6 +83,7 @@ func GetToken(c *gin.Context) {
 		})
 		return
 	}
+	token.MaskKey()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -117,6 +126,16 @@ func validateToken(c *gin.Context, token model.Token) error {
 			return fmt.Errorf("无效的网段：%s", err.Error())
 		}
 	}
+	if token.PiiRules != nil {
+		if err := pii.ValidateRules(*token.PiiRules); err != nil {
+			return err
+		}
+	}
+	if token.FilterPolicy != nil {
+		if err := filter.ValidatePolicyName(*token.FilterPolicy); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -150,6 +169,8 @@ func AddToken(c *gin.Context) {
 		UnlimitedQuota: token.UnlimitedQuota,
 		Models:         token.Models,
 		Subnet:         token.Subnet,
+		PiiRules:       token.PiiRules,
+		FilterPolicy:   token.FilterPolicy,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -159,6 +180,7 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	// the full key is only returned once, it is stored hashed
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -239,6 +261,8 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.UnlimitedQuota = token.UnlimitedQuota
 		cleanToken.Models = token.Models
 		cleanToken.Subnet = token.Subnet
+		cleanToken.PiiRules = token.PiiRules
+		cleanToken.FilterPolicy = token.FilterPolicy
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -248,6 +272,10 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if statusOnly != "" && cleanToken.Status == model.TokenStatusEnabled {
+		model.ResetTokenLeakDetection(cleanToken.Id)
+	}
+	cleanToken.MaskKey()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/blacklist"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/i18n"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/qrcode"
+	"github.com/songquanpeng/one-api/common/totp"
+	"github.com/songquanpeng/one-api/model"
+)
+
+const (
+	twoFactorPendingId       = "2fa_pending_id"
+	twoFactorPendingTime     = "2fa_pending_time"
+	twoFactorPendingAttempts = "2fa_pending_attempts"
+
+	twoFactorPendingTimeout     = 5 * 60 // seconds
+	twoFactorPendingMaxAttempts = 5
+)
+
+type TwoFactorRequest struct {
+	Code string `json:"code"`
+}
+
+// getPendingTwoFactorUser returns the user who passed the first login step, nil if there is none or it expired
+func getPendingTwoFactorUser(c *gin.Context) *model.User {
+	session := sessions.Default(c)
+	id, ok := session.Get(twoFactorPendingId).(int)
+	if !ok {
+		return nil
+	}
+	createdAt, _ := session.Get(twoFactorPendingTime).(int64)
+	attempts, _ := session.Get(twoFactorPendingAttempts).(int)
+	if time.Now().Unix()-createdAt > twoFactorPendingTimeout || attempts >= twoFactorPendingMaxAttempts {
+		session.Clear()
+		_ = session.Save()
+		return nil
+	}
+	user, err := model.GetUserById(id, true)
+	if err != nil || user.Status != model.UserStatusEnabled {
+		return nil
+	}
+	return user
+}
+
+func countPendingTwoFactorAttempt(c *gin.Context) {
+	session := sessions.Default(c)
+	attempts, _ := session.Get(twoFactorPendingAttempts).(int)
+	session.Set(twoFactorPendingAttempts, attempts+1)
+	_ = session.Save()
+}
+
+// getTwoFactorUser returns the logged-in user or the pending user, 2FA setup is allowed for both
+// so that users who are required to use 2FA can enroll during login
+func getTwoFactorUser(c *gin.Context) (user *model.User, pending bool) {
+	session := sessions.Default(c)
+	if id, ok := session.Get("id").(int); ok {
+		user, err := model.GetUserById(id, true)
+		if err != nil || user.Status != model.UserStatusEnabled {
+			return nil, false
+		}
+		return user, false
+	}
+	return getPendingTwoFactorUser(c), true
+}
+
+func decodeTwoFactorRequest(c *gin.Context) (string, bool) {
+	var req TwoFactorRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return "", false
+	}
+	return req.Code, true
+}
+
+// LoginTwoFactor is the second login step, it accepts a TOTP code or a recovery code
+func LoginTwoFactor(c *gin.Context) {
+	code, ok := decodeTwoFactorRequest(c)
+	if !ok {
+		return
+	}
+	user := getPendingTwoFactorUser(c)
+	if user == nil || !user.TotpEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "登录已过期，请重新登录",
+		})
+		return
+	}
+	if !user.ValidateTwoFactor(code) {
+		countPendingTwoFactorAttempt(c)
+		blacklist.RecordFailure(blacklist.BanTypeIp, c.ClientIP(), "多次两步验证失败")
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "验证码错误或已被使用",
+		})
+		return
+	}
+	cleanUser, err := setupSession(user, c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法保存会话信息，请重试",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanUser,
+	})
+}
+
+// SetupTwoFactor generates a new secret, it is not enabled until confirmed by EnableTwoFactor
+func SetupTwoFactor(c *gin.Context) {
+	user, _ := getTwoFactorUser(c)
+	if user == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "登录已过期，请重新登录",
+		})
+		return
+	}
+	if user.TotpEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "已启用两步验证",
+		})
+		return
+	}
+	totpSecret, err := totp.GenerateSecret()
+	if err == nil {
+		err = model.SetUserTotpSecret(user.Id, totpSecret)
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	uri := totp.URI(config.SystemName, user.Username, totpSecret)
+	qrCode, err := qrcode.DataURL(uri, 4)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("generate 2fa qr code failed: %s", err.Error()))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"secret":  totpSecret,
+			"uri":     uri,
+			"qr_code": qrCode,
+		},
+	})
+}
+
+// EnableTwoFactor confirms the secret from SetupTwoFactor and returns the recovery codes,
+// a pending login is completed at the same time
+func EnableTwoFactor(c *gin.Context) {
+	code, ok := decodeTwoFactorRequest(c)
+	if !ok {
+		return
+	}
+	user, pending := getTwoFactorUser(c)
+	if user == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "登录已过期，请重新登录",
+		})
+		return
+	}
+	if user.TotpEnabled || user.TotpSecret == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "请先生成两步验证密钥",
+		})
+		return
+	}
+	totpSecret, err := user.GetTotpSecret()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	step, ok := totp.Validate(totpSecret, code, time.Now())
+	if !ok {
+		if pending {
+			countPendingTwoFactorAttempt(c)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "验证码错误",
+		})
+		return
+	}
+	recoveryCodes, err := model.EnableUserTotp(user.Id, step)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	data := gin.H{"recovery_codes": recoveryCodes}
+	if pending {
+		cleanUser, err := setupSession(user, c)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法保存会话信息，请重试",
+			})
+			return
+		}
+		data["user"] = cleanUser
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
+
+func DisableTwoFactor(c *gin.Context) {
+	code, ok := decodeTwoFactorRequest(c)
+	if !ok {
+		return
+	}
+	user, err := model.GetUserById(c.GetInt(ctxkey.Id), true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if user.RequireTwoFactor() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员必须启用两步验证",
+		})
+		return
+	}
+	if !user.ValidateTwoFactor(code) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "验证码错误或已被使用",
+		})
+		return
+	}
+	if err := model.DisableUserTotp(user.Id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
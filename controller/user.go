@@ -11,9 +11,11 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/blacklist"
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/i18n"
+	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/common/random"
 	"github.com/songquanpeng/one-api/model"
 )
@@ -55,6 +57,7 @@ func Login(c *gin.Context) {
 	}
 	err = user.ValidateAndFill()
 	if err != nil {
+		blacklist.RecordFailure(blacklist.BanTypeIp, c.ClientIP(), "多次登录失败")
 		c.JSON(http.StatusOK, gin.H{
 			"message": err.Error(),
 			"success": false,
@@ -64,14 +67,33 @@ func Login(c *gin.Context) {
 	SetupLogin(&user, c)
 }
 
-// setup session & cookies and then return user info
+// setup session & cookies and then return user info,
+// users with 2FA get a pending session that is completed by LoginTwoFactor or EnableTwoFactor
 func SetupLogin(user *model.User, c *gin.Context) {
-	session := sessions.Default(c)
-	session.Set("id", user.Id)
-	session.Set("username", user.Username)
-	session.Set("role", user.Role)
-	session.Set("status", user.Status)
-	err := session.Save()
+	if user.TotpEnabled || user.RequireTwoFactor() {
+		session := sessions.Default(c)
+		session.Clear()
+		session.Set(twoFactorPendingId, user.Id)
+		session.Set(twoFactorPendingTime, time.Now().Unix())
+		if err := session.Save(); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "无法保存会话信息，请重试",
+				"success": false,
+			})
+			return
+		}
+		data := gin.H{"require_2fa": true}
+		if !user.TotpEnabled {
+			data = gin.H{"require_2fa_setup": true}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "",
+			"success": true,
+			"data":    data,
+		})
+		return
+	}
+	cleanUser, err := setupSession(user, c)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "无法保存会话信息，请重试",
@@ -79,13 +101,6 @@ func SetupLogin(user *model.User, c *gin.Context) {
 		})
 		return
 	}
-	cleanUser := model.User{
-		Id:          user.Id,
-		Username:    user.Username,
-		DisplayName: user.DisplayName,
-		Role:        user.Role,
-		Status:      user.Status,
-	}
 	c.JSON(http.StatusOK, gin.H{
 		"message": "",
 		"success": true,
@@ -93,6 +108,27 @@ func SetupLogin(user *model.User, c *gin.Context) {
 	})
 }
 
+func setupSession(user *model.User, c *gin.Context) (*model.User, error) {
+	session := sessions.Default(c)
+	session.Clear()
+	session.Set("id", user.Id)
+	session.Set("username", user.Username)
+	session.Set("role", user.Role)
+	session.Set("status", user.Status)
+	if err := session.Save(); err != nil {
+		return nil, err
+	}
+	return &model.User{
+		Id:          user.Id,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Role:        user.Role,
+		Status:      user.Status,
+		TotpEnabled: user.TotpEnabled,
+		Permissions: user.GetPermissions(),
+	}, nil
+}
+
 func Logout(c *gin.Context) {
 	session := sessions.Default(c)
 	session.Clear()
@@ -243,8 +279,7 @@ func GetUser(c *gin.Context) {
 		})
 		return
 	}
-	myRole := c.GetInt(ctxkey.Role)
-	if myRole <= user.Role && myRole != model.RoleRootUser {
+	if !canManageUser(c, user) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权获取同级或更高等级用户的信息",
@@ -282,42 +317,6 @@ func GetUserDashboard(c *gin.Context) {
 	return
 }
 
-func GenerateAccessToken(c *gin.Context) {
-	id := c.GetInt(ctxkey.Id)
-	user, err := model.GetUserById(id, true)
-	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": err.Error(),
-		})
-		return
-	}
-	user.AccessToken = random.GetUUID()
-
-	if model.DB.Where("access_token = ?", user.AccessToken).First(user).RowsAffected != 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": "请重试，系统生成的 UUID 竟然重复了！",
-		})
-		return
-	}
-
-	if err := user.Update(false); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": err.Error(),
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "",
-		"data":    user.AccessToken,
-	})
-	return
-}
-
 func GetAffCode(c *gin.Context) {
 	id := c.GetInt(ctxkey.Id)
 	user, err := model.GetUserById(id, true)
@@ -356,6 +355,7 @@ func GetSelf(c *gin.Context) {
 		})
 		return
 	}
+	user.Permissions = user.GetPermissions()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -364,10 +364,20 @@ func GetSelf(c *gin.Context) {
 	return
 }
 
+type updateUserRequest struct {
+	model.User
+	// nil if the custom role is not changed
+	RoleId *int `json:"role_id"`
+	// nil if the ip lists are not changed
+	IpAllowList *string `json:"ip_allow_list"`
+	IpDenyList  *string `json:"ip_deny_list"`
+}
+
 func UpdateUser(c *gin.Context) {
 	ctx := c.Request.Context()
-	var updatedUser model.User
-	err := json.NewDecoder(c.Request.Body).Decode(&updatedUser)
+	var req updateUserRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	updatedUser := req.User
 	if err != nil || updatedUser.Id == 0 {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -385,7 +395,7 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
-	originUser, err := model.GetUserById(updatedUser.Id, false)
+	originUser, err := model.GetUserById(updatedUser.Id, true)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -394,14 +404,15 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 	myRole := c.GetInt(ctxkey.Role)
-	if myRole <= originUser.Role && myRole != model.RoleRootUser {
+	if !canManageUser(c, originUser) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权更新同权限等级或更高权限等级的用户信息",
 		})
 		return
 	}
-	if myRole <= updatedUser.Role && myRole != model.RoleRootUser {
+	// the role can only be changed to a level below that of the current user
+	if myRole <= updatedUser.Role && myRole != model.RoleRootUser && updatedUser.Role != originUser.Role {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权将其他用户权限等级提升到大于等于自己的权限等级",
@@ -411,6 +422,49 @@ func UpdateUser(c *gin.Context) {
 	if updatedUser.Password == "$I_LOVE_U" {
 		updatedUser.Password = "" // rollback to what it should be
 	}
+	updatedUser.TotpEnabled = false // 2FA can only be reset through ManageUser
+	if updatedUser.Quota != originUser.Quota && !model.UserHasPermission(c.GetInt(ctxkey.Id), model.PermissionUserQuota) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权修改用户额度",
+		})
+		return
+	}
+	if req.RoleId != nil && *req.RoleId != originUser.RoleId {
+		if !model.UserHasPermission(c.GetInt(ctxkey.Id), model.PermissionRoleWrite) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权分配角色",
+			})
+			return
+		}
+		if err := model.SetUserRoleId(updatedUser.Id, *req.RoleId); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	if req.IpAllowList != nil || req.IpDenyList != nil {
+		allowList, denyList := originUser.IpAllowList, originUser.IpDenyList
+		if req.IpAllowList != nil {
+			allowList = *req.IpAllowList
+		}
+		if req.IpDenyList != nil {
+			denyList = *req.IpDenyList
+		}
+		if err := model.SetUserIpLists(updatedUser.Id, allowList, denyList); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	roleChanged := req.RoleId != nil && *req.RoleId != originUser.RoleId
+	updatedUser.RoleId = 0 // assigned above, zero values are not updated
+	updatedUser.IpAllowList, updatedUser.IpDenyList = "", ""
 	updatePassword := updatedUser.Password != ""
 	if err := updatedUser.Update(updatePassword); err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -419,9 +473,20 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
+	if updatedUser.Role != 0 && updatedUser.Role != originUser.Role {
+		roleChanged = true
+	}
+	if updatePassword || roleChanged || (updatedUser.Status != 0 && updatedUser.Status != originUser.Status) {
+		if err := model.RevokeUserSessions(updatedUser.Id, ""); err != nil {
+			logger.Error(ctx, "failed to revoke sessions: "+err.Error())
+		}
+	}
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(ctx, originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(originUser.Quota), common.LogQuota(updatedUser.Quota)))
 	}
+	if user, err := model.GetUserById(updatedUser.Id, true); err == nil {
+		recordAudit(c, model.AuditActionUserUpdate, model.AuditTargetUser, user.Id, originUser, user)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -468,6 +533,12 @@ func UpdateSelf(c *gin.Context) {
 		})
 		return
 	}
+	if updatePassword {
+		// keep the current session, log out everywhere else
+		if err := model.RevokeUserSessions(cleanUser.Id, sessions.Default(c).ID()); err != nil {
+			logger.Error(c.Request.Context(), "failed to revoke sessions: "+err.Error())
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -493,8 +564,7 @@ func DeleteUser(c *gin.Context) {
 		})
 		return
 	}
-	myRole := c.GetInt("role")
-	if myRole <= originUser.Role {
+	if originUser.Role == model.RoleRootUser || !canManageUser(c, originUser) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权删除同权限等级或更高权限等级的用户",
@@ -588,6 +658,15 @@ func CreateUser(c *gin.Context) {
 	return
 }
 
+// canManageUser reports whether the current user may read or manage the target, see model.CanManageUser
+func canManageUser(c *gin.Context, target *model.User) bool {
+	me, err := model.GetUserById(c.GetInt(ctxkey.Id), false)
+	if err != nil {
+		return false
+	}
+	return model.CanManageUser(me, target)
+}
+
 type ManageRequest struct {
 	Username string `json:"username"`
 	Action   string `json:"action"`
@@ -618,13 +697,14 @@ func ManageUser(c *gin.Context) {
 		return
 	}
 	myRole := c.GetInt("role")
-	if myRole <= user.Role && myRole != model.RoleRootUser {
+	if !canManageUser(c, &user) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权更新同权限等级或更高权限等级的用户信息",
 		})
 		return
 	}
+	originUser := user
 	switch req.Action {
 	case "disable":
 		user.Status = model.UserStatusDisabled
@@ -684,6 +764,27 @@ func ManageUser(c *gin.Context) {
 			return
 		}
 		user.Role = model.RoleCommonUser
+	case "reset_2fa":
+		if !user.TotpEnabled {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "该用户未启用两步验证",
+			})
+			return
+		}
+		if err := model.DisableUserTotp(user.Id); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		// the loaded values must not be written back by Update below
+		user.TotpEnabled = false
+		user.TotpSecret = ""
+		user.TotpRecoveryCodes = ""
+		user.TotpLastStep = 0
+		model.RecordLog(c.Request.Context(), user.Id, model.LogTypeManage, fmt.Sprintf("管理员 %d 重置了用户的两步验证", c.GetInt(ctxkey.Id)))
 	}
 
 	if err := user.Update(false); err != nil {
@@ -693,9 +794,16 @@ func ManageUser(c *gin.Context) {
 		})
 		return
 	}
+	if user.Status != originUser.Status || user.Role != originUser.Role {
+		if err := model.RevokeUserSessions(user.Id, ""); err != nil {
+			logger.Error(c.Request.Context(), "failed to revoke sessions: "+err.Error())
+		}
+	}
+	recordAudit(c, model.AuditActionUserManagePrefix+req.Action, model.AuditTargetUser, user.Id, originUser, user)
 	clearUser := model.User{
-		Role:   user.Role,
-		Status: user.Status,
+		Role:        user.Role,
+		Status:      user.Status,
+		TotpEnabled: user.TotpEnabled,
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -808,6 +916,7 @@ func AdminTopUp(c *gin.Context) {
 		req.Remark = fmt.Sprintf("通过 API 充值 %s", common.LogQuota(int64(req.Quota)))
 	}
 	model.RecordTopupLog(ctx, req.UserId, req.Remark, req.Quota)
+	recordAudit(c, model.AuditActionUserTopUp, model.AuditTargetUser, req.UserId, nil, req)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -6,8 +6,6 @@ import (
 	"os"
 	"strconv"
 
-	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 	_ "github.com/joho/godotenv/autoload"
 
@@ -16,6 +14,7 @@ import (
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/i18n"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/secret"
 	"github.com/songquanpeng/one-api/controller"
 	"github.com/songquanpeng/one-api/middleware"
 	"github.com/songquanpeng/one-api/model"
@@ -38,6 +37,16 @@ func main() {
 		logger.SysLog("running in debug mode")
 	}
 
+	if err := secret.Init(); err != nil {
+		logger.FatalLog("failed to load channel master key: " + err.Error())
+	}
+	if !secret.Enabled() {
+		logger.SysLog("CHANNEL_MASTER_KEY is not set, channel keys will be stored in plain text")
+	}
+	if config.TokenKeySecret == "" {
+		logger.SysWarn("TOKEN_KEY_SECRET is not set, token keys and recovery codes are hashed without a secret, set it to a random string before creating tokens, changing it later invalidates them")
+	}
+
 	// Initialize SQL Database
 	model.InitDB()
 	model.InitLogDB()
@@ -83,6 +92,18 @@ func main() {
 		}
 		go controller.AutomaticallyTestChannels(frequency)
 	}
+	if config.IsMasterNode {
+		go model.CleanSessions()
+	}
+	if config.AuditLogRetentionDays > 0 && config.IsMasterNode {
+		go model.CleanAuditLogs(config.AuditLogRetentionDays)
+	}
+	if config.CaptureRetentionDays > 0 && config.IsMasterNode {
+		go model.CleanRequestCaptures(config.CaptureRetentionDays)
+	}
+	if config.IsMasterNode {
+		go model.CleanLogs()
+	}
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		config.BatchUpdateEnabled = true
 		logger.SysLog("batch update enabled with interval " + strconv.Itoa(config.BatchUpdateInterval) + "s")
@@ -99,13 +120,17 @@ func main() {
 		logger.FatalLog("failed to initialize i18n: " + err.Error())
 	}
 
-	// 加载敏感词列表和响应
-	config.LoadSensitiveWordsFromFile()
+	// 加载敏感词响应和过滤规则
 	config.LoadSensitiveResponseFromFile()
-
-	// 初始化敏感词过滤器（同时会初始化增强的敏感词过滤器）
-	middleware.GetSensitiveFilter()
-	middleware.GetEnhancedSensitiveFilter()
+	if err := model.LoadFilterRules(); err != nil {
+		logger.FatalLog("failed to load filter rules: " + err.Error())
+	}
+	if common.RedisEnabled {
+		go model.SubscribeFilterRules()
+	}
+	if config.SensitiveWordsFileEnabled {
+		go model.WatchSensitiveWordsFile()
+	}
 
 	// Initialize HTTP server
 	server := gin.New()
@@ -116,11 +141,8 @@ func main() {
 	server.Use(middleware.Language())
 	middleware.SetUpLogger(server)
 	// Initialize session store
-	store := cookie.NewStore([]byte(config.SessionSecret))
-	server.Use(sessions.Sessions("session", store))
-
-	// 添加敏感词过滤中间件
-	server.Use(middleware.SensitiveFilter())
+	store := middleware.NewSessionStore([]byte(config.SessionSecret))
+	server.Use(middleware.Session("session", store))
 
 	router.SetRouter(server, buildFS)
 	var port = os.Getenv("PORT")
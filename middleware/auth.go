@@ -1,18 +1,27 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/songquanpeng/one-api/common/blacklist"
 	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/common/network"
 	"github.com/songquanpeng/one-api/model"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
-func authHelper(c *gin.Context, minRole int) {
+// authenticate sets the user of the session or access token in the context,
+// it aborts the request and returns false if there is none
+func authenticate(c *gin.Context) bool {
+	if abortIfIpBanned(c) {
+		return false
+	}
 	session := sessions.Default(c)
 	username := session.Get("username")
 	role := session.Get("role")
@@ -27,23 +36,25 @@ func authHelper(c *gin.Context, minRole int) {
 				"message": "无权进行此操作，未登录且未提供 access token",
 			})
 			c.Abort()
-			return
+			return false
 		}
-		user := model.ValidateAccessToken(accessToken)
-		if user != nil && user.Username != "" {
-			// Token is valid
-			username = user.Username
-			role = user.Role
-			id = user.Id
-			status = user.Status
-		} else {
+		user, token, err := model.ValidateAccessToken(c.Request.Context(), accessToken, c.ClientIP())
+		if err != nil {
+			if errors.Is(err, model.ErrInvalidAccessToken) {
+				blacklist.RecordFailure(blacklist.BanTypeIp, c.ClientIP(), "多次使用无效的 access token")
+			}
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
-				"message": "无权进行此操作，access token 无效",
+				"message": "无权进行此操作，" + err.Error(),
 			})
 			c.Abort()
-			return
+			return false
 		}
+		username = user.Username
+		role = user.Role
+		id = user.Id
+		status = user.Status
+		c.Set(ctxkey.AccessToken, token)
 	}
 	if status.(int) == model.UserStatusDisabled || blacklist.IsUserBanned(id.(int)) {
 		c.JSON(http.StatusOK, gin.H{
@@ -54,19 +65,57 @@ func authHelper(c *gin.Context, minRole int) {
 		session.Clear()
 		_ = session.Save()
 		c.Abort()
-		return
+		return false
 	}
-	if role.(int) < minRole {
+	if err := checkIpAccess(c, id.(int)); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
-			"message": "无权进行此操作，权限不足",
+			"message": err.Error(),
 		})
 		c.Abort()
-		return
+		return false
 	}
 	c.Set("username", username)
 	c.Set("role", role)
 	c.Set("id", id)
+	logger.SetUserId(c.Request.Context(), id.(int))
+	return true
+}
+
+func abortForbidden(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": false,
+		"message": "无权进行此操作，权限不足",
+	})
+	c.Abort()
+}
+
+// accessTokenAllows reports whether the access token of the request covers the scope,
+// requests authenticated by session are not limited
+func accessTokenAllows(c *gin.Context, scope string) bool {
+	token, ok := c.Get(ctxkey.AccessToken)
+	if !ok {
+		return true
+	}
+	return token.(*model.AccessToken).HasScope(scope)
+}
+
+func authHelper(c *gin.Context, minRole int) {
+	if !authenticate(c) {
+		return
+	}
+	if c.GetInt(ctxkey.Role) < minRole {
+		abortForbidden(c)
+		return
+	}
+	scope := model.ScopeSelf
+	if c.Request.Method == http.MethodGet {
+		scope = model.ScopeSelfRead
+	}
+	if !accessTokenAllows(c, scope) {
+		abortForbidden(c)
+		return
+	}
 	c.Next()
 }
 
@@ -88,25 +137,84 @@ func RootAuth() func(c *gin.Context) {
 	}
 }
 
+// SessionAuth allows logged in users only, it is used for account changes that access tokens must not make
+func SessionAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !authenticate(c) {
+			return
+		}
+		if _, ok := c.Get(ctxkey.AccessToken); ok {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "该操作不支持使用 access token，请登录后操作",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// PermissionAuth allows users whose built-in or custom role has the permission
+func PermissionAuth(permission string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !authenticate(c) {
+			return
+		}
+		if !model.UserHasPermission(c.GetInt(ctxkey.Id), permission) || !accessTokenAllows(c, permission) {
+			abortForbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
 func TokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 		key := c.Request.Header.Get("Authorization")
+		if key == "" && websocket.IsWebSocketUpgrade(c.Request) {
+			// browsers can not set headers on websocket, the key is sent as a subprotocol
+			for _, protocol := range websocket.Subprotocols(c.Request) {
+				if strings.HasPrefix(protocol, "openai-insecure-api-key.") {
+					key = strings.TrimPrefix(protocol, "openai-insecure-api-key.")
+					break
+				}
+			}
+		}
 		key = strings.TrimPrefix(key, "Bearer ")
 		key = strings.TrimPrefix(key, "sk-")
 		parts := strings.Split(key, "-")
 		key = parts[0]
+		if isIpBanned(c) {
+			abortWithMessage(c, http.StatusForbidden, "当前 IP 已被临时封禁")
+			return
+		}
 		token, err := model.ValidateUserToken(key)
 		if err != nil {
+			if errors.Is(err, model.ErrInvalidToken) {
+				blacklist.RecordFailure(blacklist.BanTypeIp, c.ClientIP(), "多次使用无效的令牌")
+			}
 			abortWithMessage(c, http.StatusUnauthorized, err.Error())
 			return
 		}
+		tokenBanKey := strconv.Itoa(token.Id)
+		if blacklist.IsBanned(blacklist.BanTypeToken, tokenBanKey) {
+			abortWithMessage(c, http.StatusForbidden, "该令牌已被临时封禁")
+			return
+		}
 		if token.Subnet != nil && *token.Subnet != "" {
 			if !network.IsIpInSubnets(ctx, c.ClientIP(), *token.Subnet) {
+				blacklist.RecordFailure(blacklist.BanTypeToken, tokenBanKey, "多次在允许的网段之外使用")
 				abortWithMessage(c, http.StatusForbidden, fmt.Sprintf("该令牌只能在指定网段使用：%s，当前 ip：%s", *token.Subnet, c.ClientIP()))
 				return
 			}
 		}
+		if err := checkIpAccess(c, token.UserId); err != nil {
+			blacklist.RecordFailure(blacklist.BanTypeToken, tokenBanKey, "多次在禁止的 IP 使用")
+			abortWithMessage(c, http.StatusForbidden, err.Error())
+			return
+		}
 		userEnabled, err := model.CacheIsUserEnabled(token.UserId)
 		if err != nil {
 			abortWithMessage(c, http.StatusInternalServerError, err.Error())
@@ -129,9 +237,17 @@ func TokenAuth() func(c *gin.Context) {
 				return
 			}
 		}
+		go model.RecordTokenAccess(token.Id, c.ClientIP(), c.Request.UserAgent())
 		c.Set(ctxkey.Id, token.UserId)
+		logger.SetUserId(ctx, token.UserId)
 		c.Set(ctxkey.TokenId, token.Id)
 		c.Set(ctxkey.TokenName, token.Name)
+		if token.PiiRules != nil {
+			c.Set(ctxkey.TokenPiiRules, *token.PiiRules)
+		}
+		if token.FilterPolicy != nil {
+			c.Set(ctxkey.TokenFilterPolicy, *token.FilterPolicy)
+		}
 		if len(parts) > 1 {
 			if model.IsAdmin(token.UserId) {
 				c.Set(ctxkey.SpecificChannelId, parts[1])
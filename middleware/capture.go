@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	dbmodel "github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/pii"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// captureWriter passes the response through and keeps its beginning
+type captureWriter struct {
+	gin.ResponseWriter
+	buffer   bytes.Buffer
+	maxBytes int
+	size     int
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.keep(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *captureWriter) keep(data []byte) {
+	w.size += len(data)
+	if room := w.maxBytes - w.buffer.Len(); room > 0 {
+		w.buffer.Write(data[:min(room, len(data))])
+	}
+}
+
+// isTextContent reports whether a body of the content type is kept as text, other bodies such as
+// multipart forms and audio are only described
+func isTextContent(contentType string) bool {
+	return contentType == "" || strings.HasPrefix(contentType, "application/json") ||
+		strings.HasPrefix(contentType, "text/")
+}
+
+func describeBody(contentType string, size int) string {
+	return fmt.Sprintf("[%s, %d bytes]", contentType, size)
+}
+
+// CaptureRequest keeps the request and response bodies of the users and tokens chosen for capture,
+// with pii redacted, for debugging, it is used after TokenAuth, realtime sessions are not captured
+func CaptureRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if relaymode.GetByPath(c.Request.URL.Path) == relaymode.Realtime ||
+			!dbmodel.ShouldCaptureRequest(c.GetInt(ctxkey.Id), c.GetInt(ctxkey.TokenId)) {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		requestBody, err := common.GetRequestBody(c)
+		if err != nil {
+			logger.Errorf(ctx, "读取请求体失败，跳过记录: %v", err)
+			c.Next()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		// a little more than the cap is kept, so that the redacted text can still fill it
+		writer := &captureWriter{ResponseWriter: c.Writer, maxBytes: 2 * config.CaptureMaxBytes}
+		c.Writer = writer
+
+		c.Next()
+
+		c.Writer = writer.ResponseWriter
+		redactor := pii.NewRedactor(pii.AllRules())
+		capture := &dbmodel.RequestCapture{
+			UserId:     c.GetInt(ctxkey.Id),
+			TokenId:    c.GetInt(ctxkey.TokenId),
+			ChannelId:  c.GetInt(ctxkey.ChannelId),
+			ModelName:  c.GetString(ctxkey.RequestModel),
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			StatusCode: writer.Status(),
+			Truncated:  writer.size > writer.buffer.Len(),
+		}
+		if contentType := c.Request.Header.Get("Content-Type"); isTextContent(contentType) {
+			capture.Request = redactor.Redact(string(requestBody))
+		} else {
+			capture.Request = describeBody(contentType, len(requestBody))
+		}
+		if contentType := writer.Header().Get("Content-Type"); isTextContent(contentType) {
+			capture.Response = redactor.Redact(writer.buffer.String())
+		} else {
+			capture.Response = describeBody(contentType, writer.size)
+			capture.Truncated = false
+		}
+		dbmodel.RecordRequestCapture(ctx, capture)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
@@ -41,6 +42,12 @@ func Distribute() func(c *gin.Context) {
 				abortWithMessage(c, http.StatusForbidden, "该渠道已被禁用")
 				return
 			}
+			if c.Param("channelid") != "" && !model.IsAdmin(userId) {
+				if message := checkProxyChannel(channel, userGroup); message != "" {
+					abortWithMessage(c, http.StatusForbidden, message)
+					return
+				}
+			}
 		} else {
 			requestModel = c.GetString(ctxkey.RequestModel)
 			var err error
@@ -61,18 +68,46 @@ func Distribute() func(c *gin.Context) {
 	}
 }
 
+// checkProxyChannel makes sure regular users only proxy through billed channels of their group
+func checkProxyChannel(channel *model.Channel, userGroup string) string {
+	inGroup := false
+	for _, group := range strings.Split(channel.Group, ",") {
+		if strings.TrimSpace(group) == userGroup {
+			inGroup = true
+			break
+		}
+	}
+	if !inGroup {
+		return "无权使用该渠道"
+	}
+	cfg, err := channel.LoadConfig()
+	if err != nil || !cfg.IsProxyBillingEnabled() {
+		return "该渠道未配置计费规则，仅管理员可使用代理"
+	}
+	return ""
+}
+
 func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, modelName string) {
 	c.Set(ctxkey.Channel, channel.Type)
 	c.Set(ctxkey.ChannelId, channel.Id)
+	logger.SetChannel(c.Request.Context(), channel.Id, modelName)
 	c.Set(ctxkey.ChannelName, channel.Name)
 	if channel.SystemPrompt != nil && *channel.SystemPrompt != "" {
 		c.Set(ctxkey.SystemPrompt, *channel.SystemPrompt)
 	}
 	c.Set(ctxkey.ModelMapping, channel.GetModelMapping())
 	c.Set(ctxkey.OriginalModel, modelName) // for retry
-	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+	// the only place where channel secrets are decrypted for relaying
+	key, err := channel.GetDecryptedKey()
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to decrypt key of channel #%d: %s", channel.Id, err.Error()))
+	}
+	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 	c.Set(ctxkey.BaseURL, channel.GetBaseURL())
-	cfg, _ := channel.LoadConfig()
+	cfg, err := channel.LoadDecryptedConfig()
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to load config of channel #%d: %s", channel.Id, err.Error()))
+	}
 	// this is for backward compatibility
 	if channel.Other != nil {
 		switch channel.Type {
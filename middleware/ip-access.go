@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/blacklist"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/network"
+	"github.com/songquanpeng/one-api/model"
+)
+
+func isIpBanned(c *gin.Context) bool {
+	return blacklist.IsBanned(blacklist.BanTypeIp, c.ClientIP())
+}
+
+func checkIpLists(ctx context.Context, ip string, allowList string, denyList string) error {
+	if denyList != "" && network.IsIpInSubnets(ctx, ip, denyList) {
+		return fmt.Errorf("当前 IP 禁止访问：%s", ip)
+	}
+	if allowList != "" && !network.IsIpInSubnets(ctx, ip, allowList) {
+		return fmt.Errorf("当前 IP 不在允许访问的网段内：%s", ip)
+	}
+	return nil
+}
+
+// checkIpAccess enforces the global ip lists and those of the user, deny lists take precedence over allow lists
+func checkIpAccess(c *gin.Context, userId int) error {
+	ctx := c.Request.Context()
+	ip := c.ClientIP()
+	if err := checkIpLists(ctx, ip, config.IpAllowList, config.IpDenyList); err != nil {
+		return err
+	}
+	lists, err := model.CacheGetUserIpLists(userId)
+	if err != nil {
+		logger.Errorf(ctx, "failed to get ip lists of user %d: %s", userId, err.Error())
+		return nil
+	}
+	return checkIpLists(ctx, ip, lists.AllowList, lists.DenyList)
+}
+
+// abortIfIpBanned rejects temporarily banned ips on routes that are open to anyone, e.g. login
+func abortIfIpBanned(c *gin.Context) bool {
+	if !isIpBanned(c) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"success": false,
+		"message": "当前 IP 已被临时封禁",
+	})
+	c.Abort()
+	return true
+}
@@ -1,8 +1,13 @@
 package middleware
 
 import (
+	"encoding/json"
 	"fmt"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/helper"
 )
 
@@ -12,6 +17,9 @@ func SetUpLogger(server *gin.Engine) {
 		if param.Keys != nil {
 			requestID = param.Keys[helper.RequestIdKey].(string)
 		}
+		if config.LogFormat == "json" {
+			return accessLogJSON(param, requestID)
+		}
 		return fmt.Sprintf("[GIN] %s | %s | %3d | %13v | %15s | %7s %s\n",
 			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
 			requestID,
@@ -23,3 +31,30 @@ func SetUpLogger(server *gin.Engine) {
 		)
 	}))
 }
+
+// accessLogJSON formats the access log of a request like the json logs of the logger
+func accessLogJSON(param gin.LogFormatterParams, requestID string) string {
+	entry := gin.H{
+		"level":      "info",
+		"time":       param.TimeStamp.Format(time.RFC3339Nano),
+		"msg":        "request",
+		"status":     param.StatusCode,
+		"latency_ms": param.Latency.Milliseconds(),
+		"ip":         param.ClientIP,
+		"method":     param.Method,
+		"path":       param.Path,
+	}
+	if requestID != "" {
+		entry["request_id"] = requestID
+	}
+	for key, name := range map[string]string{ctxkey.Id: "user_id", ctxkey.ChannelId: "channel_id", ctxkey.RequestModel: "model"} {
+		if value, ok := param.Keys[key]; ok && value != "" && value != 0 {
+			entry[name] = value
+		}
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return ""
+	}
+	return string(data) + "\n"
+}
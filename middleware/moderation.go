@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	dbmodel "github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+	relaycontroller "github.com/songquanpeng/one-api/relay/controller"
+	"github.com/songquanpeng/one-api/relay/filter"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// moderator checks the texts of a request with the moderation model, failures are logged and pass the texts
+func moderator(ctx context.Context, config *filter.ModerationConfig) filter.Moderator {
+	return func(text string) *filter.Result {
+		if strings.TrimSpace(text) == "" {
+			return &filter.Result{}
+		}
+		result, err := moderate(ctx, config, text)
+		if err != nil {
+			logger.Errorf(ctx, "内容审核失败，跳过审核: %v", err)
+			return &filter.Result{}
+		}
+		return result
+	}
+}
+
+// moderate checks the text with the moderation model, through a channel of the group of the system account,
+// which the call is billed to
+func moderate(ctx context.Context, config *filter.ModerationConfig, text string) (*filter.Result, error) {
+	group, err := dbmodel.CacheGetUserGroup(config.UserId)
+	if err != nil {
+		return nil, err
+	}
+	channel, err := dbmodel.CacheGetRandomSatisfiedChannel(group, config.Model, false)
+	if err != nil || channel == nil {
+		return nil, fmt.Errorf("no available channel for model %s under group %s", config.Model, group)
+	}
+	path := "/v1/moderations"
+	request := &model.GeneralOpenAIRequest{Model: config.Model, Input: text}
+	if config.Type == filter.ModerationTypeChat {
+		path = "/v1/chat/completions"
+		request = &model.GeneralOpenAIRequest{
+			Model: config.Model,
+			Messages: []model.Message{
+				{Role: "system", Content: config.Prompt()},
+				{Role: "user", Content: text},
+			},
+		}
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	// the logs of the moderation call have its own channel and model
+	c.Request = (&http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: path},
+		Header: make(http.Header),
+	}).WithContext(logger.WithFields(ctx))
+	c.Request.Header.Set("Content-Type", "application/json")
+	SetupContextForSelectedChannel(c, channel, config.Model)
+	c.Set(ctxkey.Id, config.UserId)
+	c.Set(ctxkey.Group, group)
+	meta := meta.GetByContext(c)
+	adaptor := relay.GetAdaptor(meta.APIType)
+	if adaptor == nil {
+		return nil, fmt.Errorf("invalid api type: %d", meta.APIType)
+	}
+	adaptor.Init(meta)
+	meta.OriginModelName, meta.ActualModelName = config.Model, config.Model
+	if mapped := meta.ModelMapping[config.Model]; mapped != "" {
+		meta.ActualModelName = mapped
+	}
+	request.Model = meta.ActualModelName
+	meta.PromptTokens = openai.CountTokenText(text, config.Model)
+
+	convertedRequest, err := adaptor.ConvertRequest(c, meta.Mode, request)
+	if err != nil {
+		return nil, err
+	}
+	jsonData, err := json.Marshal(convertedRequest)
+	if err != nil {
+		return nil, err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(jsonData))
+	resp, err := adaptor.DoRequest(c, meta, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		relayErr := relaycontroller.RelayErrorHandler(resp)
+		return nil, fmt.Errorf("status code %d: %s", resp.StatusCode, relayErr.Error.Message)
+	}
+	usage, respErr := adaptor.DoResponse(c, resp, meta)
+	if respErr != nil {
+		return nil, fmt.Errorf("status code %d: %s", respErr.StatusCode, respErr.Error.Message)
+	}
+	billModeration(ctx, config, meta, usage)
+
+	body := w.Body.Bytes()
+	if config.Type == filter.ModerationTypeChat {
+		var response openai.TextResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+		if len(response.Choices) == 0 {
+			return nil, fmt.Errorf("empty classification")
+		}
+		body = []byte(response.Choices[0].StringContent())
+	}
+	scores, flagged, err := config.ParseResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	return config.Evaluate(text, scores, flagged), nil
+}
+
+// joinTexts joins the texts of a request or response for the moderation model,
+// each is called with a function that is called with each text
+func joinTexts(each func(func(string) string) bool) string {
+	var texts []string
+	each(func(text string) string {
+		if strings.TrimSpace(text) != "" {
+			texts = append(texts, text)
+		}
+		return text
+	})
+	return strings.Join(texts, "\n")
+}
+
+// billModeration charges the system account for a moderation call
+func billModeration(ctx context.Context, config *filter.ModerationConfig, meta *meta.Meta, usage *model.Usage) {
+	if usage == nil {
+		return
+	}
+	modelRatio := billingratio.GetModelRatio(config.Model, meta.ChannelType)
+	groupRatio := billingratio.GetGroupRatio(meta.Group)
+	completionRatio := billingratio.GetCompletionRatio(config.Model, meta.ChannelType)
+	ratio := modelRatio * groupRatio
+	quota := int64(math.Ceil((float64(usage.PromptTokens) + float64(usage.CompletionTokens)*completionRatio) * ratio))
+	if ratio != 0 && quota <= 0 {
+		quota = 1
+	}
+	if err := dbmodel.DecreaseUserQuota(config.UserId, quota); err != nil {
+		logger.Error(ctx, "error consuming moderation quota: "+err.Error())
+	}
+	if err := dbmodel.CacheUpdateUserQuota(ctx, config.UserId); err != nil {
+		logger.Error(ctx, "error update user quota cache: "+err.Error())
+	}
+	dbmodel.RecordConsumeLog(ctx, &dbmodel.Log{
+		UserId:           config.UserId,
+		ChannelId:        meta.ChannelId,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		ModelName:        config.Model,
+		Quota:            int(quota),
+		Content:          fmt.Sprintf("内容审核，倍率：%.2f × %.2f × %.2f", modelRatio, groupRatio, completionRatio),
+		ElapsedTime:      helper.CalcElapsedTime(meta.StartTime),
+	})
+	dbmodel.UpdateUserUsedQuotaAndRequestCount(config.UserId, quota)
+	dbmodel.UpdateChannelUsedQuota(meta.ChannelId, quota)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/blacklist"
 	"github.com/songquanpeng/one-api/common/config"
 )
 
@@ -98,8 +99,18 @@ func GlobalAPIRateLimit() func(c *gin.Context) {
 	return rateLimitFactory(config.GlobalApiRateLimitNum, config.GlobalApiRateLimitDuration, "GA")
 }
 
+// CriticalRateLimit protects routes such as login and registration, ips tripping it are banned for a while
 func CriticalRateLimit() func(c *gin.Context) {
-	return rateLimitFactory(config.CriticalRateLimitNum, config.CriticalRateLimitDuration, "CT")
+	limit := rateLimitFactory(config.CriticalRateLimitNum, config.CriticalRateLimitDuration, "CT")
+	return func(c *gin.Context) {
+		if abortIfIpBanned(c) {
+			return
+		}
+		limit(c)
+		if c.IsAborted() && c.Writer.Status() == http.StatusTooManyRequests {
+			blacklist.AutoBan(blacklist.BanTypeIp, c.ClientIP(), "触发关键接口限流")
+		}
+	}
 }
 
 func DownloadRateLimit() func(c *gin.Context) {
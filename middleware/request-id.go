@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
 )
 
 func RequestId() func(c *gin.Context) {
@@ -11,6 +12,7 @@ func RequestId() func(c *gin.Context) {
 		id := helper.GenRequestID()
 		c.Set(helper.RequestIdKey, id)
 		ctx := helper.SetRequestID(c.Request.Context(), id)
+		ctx = logger.WithFields(ctx)
 		c.Request = c.Request.WithContext(ctx)
 		c.Header(helper.RequestIdKey, id)
 		c.Next()
@@ -5,76 +5,28 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/king133134/sensfilter"
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/logger"
+	dbmodel "github.com/songquanpeng/one-api/model"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/filter"
 	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
 	"io"
 )
 
-var (
-	sensitiveFilter *sensfilter.Search
-	filterOnce      sync.Once
-)
-
-// 获取敏感词过滤器实例
-func GetSensitiveFilter() *sensfilter.Search {
-	filterOnce.Do(func() {
-		config.LoadSensitiveWordsFromFile()
-		config.LoadSensitiveResponseFromFile()
-		if len(config.SensitiveWords) > 0 {
-			sensitiveFilter = sensfilter.Strings(config.SensitiveWords)
-		} else {
-			sensitiveFilter = sensfilter.NewSearch()
-		}
-	})
-	return sensitiveFilter
-}
-
-// 解析敏感词列表，支持每行一个敏感词或逗号分隔的格式
-func parseSensitiveWords(words string) []string {
-	commaSplit := strings.Split(words, ",")
-	result := make([]string, 0, len(commaSplit))
-	for _, part := range commaSplit {
-		lines := strings.Split(part, "\n")
-		for _, line := range lines {
-			word := strings.TrimSpace(line)
-			if word != "" {
-				result = append(result, word)
-			}
-		}
-	}
-	return result
-}
-
-// 更新敏感词列表
-func UpdateSensitiveWords(words string) {
-	parsedWords := parseSensitiveWords(words)
-	if len(parsedWords) > 0 {
-		sensitiveFilter = sensfilter.Strings(parsedWords)
-		UpdateEnhancedSensitiveWords(parsedWords)
-		config.SaveSensitiveWordsToFile(parsedWords)
-	}
-}
-
 // 更新敏感词响应
 func UpdateSensitiveResponse(response string) {
 	config.SensitiveFilterResponse = response
 	config.SaveSensitiveResponseToFile(response)
 }
 
-// 检测文本是否包含敏感词
-func containsSensitiveWords(text string) bool {
-	return containsEnhancedSensitiveWords(text)
-}
-
 // fullyBufferingResponseWriter captures the response before it's sent.
 type fullyBufferingResponseWriter struct {
 	gin.ResponseWriter
@@ -202,74 +154,290 @@ func createChatCompletionErrorResponse(c *gin.Context, statusCode int, errorCode
 	c.Abort()
 }
 
-// SensitiveFilter 敏感词过滤中间件
-func SensitiveFilter() gin.HandlerFunc {
-	GetSensitiveFilter()
-	GetEnhancedSensitiveFilter()
+// filterRequest is the parsed request of a relay mode
+type filterRequest struct {
+	model     *string
+	stream    bool
+	multipart bool // the texts of a multipart form can not be rewritten
+	// rewrite calls rewrite with each text of the request and puts back what it returns
+	rewrite func(rewrite func(string) string) bool
+	body    any
+}
+
+func rewriteString(text *string, rewrite func(string) string) bool {
+	newText := rewrite(*text)
+	changed := newText != *text
+	*text = newText
+	return changed
+}
+
+// audioTranscriptionRequest is the text of a transcription or translation form
+type audioTranscriptionRequest struct {
+	Model  string `form:"model"`
+	Prompt string `form:"prompt"`
+}
+
+// parseFilterRequest parses the request of the relay mode, the parsed request is shared with the relay
+func parseFilterRequest(c *gin.Context, relayMode int) (*filterRequest, error) {
+	switch relayMode {
+	case relaymode.ImagesGenerations:
+		request, err := common.UnmarshalBodyOnce[model.ImageRequest](c)
+		if err != nil {
+			return nil, err
+		}
+		return &filterRequest{model: &request.Model, body: request, rewrite: func(rewrite func(string) string) bool {
+			return rewriteString(&request.Prompt, rewrite)
+		}}, nil
+	case relaymode.ImagesEdits, relaymode.ImagesVariations:
+		request, err := common.UnmarshalBodyOnce[model.ImageEditRequest](c)
+		if err != nil {
+			return nil, err
+		}
+		return &filterRequest{model: &request.Model, multipart: true, rewrite: func(rewrite func(string) string) bool {
+			return rewriteString(&request.Prompt, rewrite)
+		}}, nil
+	case relaymode.AudioSpeech:
+		request, err := common.UnmarshalBodyOnce[openai.TextToSpeechRequest](c)
+		if err != nil {
+			return nil, err
+		}
+		return &filterRequest{model: &request.Model, body: request, rewrite: func(rewrite func(string) string) bool {
+			return rewriteString(&request.Input, rewrite)
+		}}, nil
+	case relaymode.AudioTranscription, relaymode.AudioTranslation:
+		request, err := common.UnmarshalBodyOnce[audioTranscriptionRequest](c)
+		if err != nil {
+			return nil, err
+		}
+		return &filterRequest{model: &request.Model, multipart: true, rewrite: func(rewrite func(string) string) bool {
+			return rewriteString(&request.Prompt, rewrite)
+		}}, nil
+	case relaymode.Rerank:
+		request, err := common.UnmarshalBodyOnce[model.RerankRequest](c)
+		if err != nil {
+			return nil, err
+		}
+		return &filterRequest{model: &request.Model, body: request, rewrite: func(rewrite func(string) string) bool {
+			return filter.RewriteRerankRequest(request, rewrite)
+		}}, nil
+	}
+	request, err := common.UnmarshalBodyOnce[model.GeneralOpenAIRequest](c)
+	if err != nil {
+		return nil, err
+	}
+	return &filterRequest{model: &request.Model, stream: request.Stream, body: request, rewrite: func(rewrite func(string) string) bool {
+		return filter.RewriteTextRequest(request, rewrite)
+	}}, nil
+}
+
+// hasTextResponse reports whether the response of the relay mode has texts to check
+func hasTextResponse(relayMode int) bool {
+	switch relayMode {
+	case relaymode.ChatCompletions, relaymode.Completions, relaymode.Edits, relaymode.ImagesGenerations,
+		relaymode.AudioTranscription, relaymode.AudioTranslation:
+		return true
+	}
+	return false
+}
+
+// rewriteResponseTexts calls rewrite with each text of a response body, which are the message contents,
+// tool call arguments and texts of the choices, the text of a transcription and the revised prompts of images,
+// and puts back what it returns, it reports whether any text was changed
+func rewriteResponseTexts(body map[string]any, rewrite func(string) string) bool {
+	changed := false
+	rewriteField := func(object map[string]any, key string) {
+		if text, ok := object[key].(string); ok {
+			if newText := rewrite(text); newText != text {
+				object[key] = newText
+				changed = true
+			}
+		}
+	}
+	if choices, ok := body["choices"].([]any); ok {
+		for _, item := range choices {
+			choice, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			message, ok := choice["message"].(map[string]any)
+			if !ok {
+				rewriteField(choice, "text")
+				continue
+			}
+			rewriteField(message, "content")
+			toolCalls, _ := message["tool_calls"].([]any)
+			for _, toolCall := range toolCalls {
+				if toolCall, ok := toolCall.(map[string]any); ok {
+					if function, ok := toolCall["function"].(map[string]any); ok {
+						rewriteField(function, "arguments")
+					}
+				}
+			}
+		}
+	}
+	rewriteField(body, "text")
+	if data, ok := body["data"].([]any); ok {
+		for _, item := range data {
+			if image, ok := item.(map[string]any); ok {
+				rewriteField(image, "revised_prompt")
+			}
+		}
+	}
+	return changed
+}
+
+// checker checks each text with the policy and masks the matches of mask rules, the results are merged into result
+func checker(policy *filter.Policy, result *filter.Result) func(string) string {
+	engine := filter.Current()
+	return func(text string) string {
+		textResult := policy.Check(engine, text)
+		result.Merge(textResult)
+		return textResult.Mask(text)
+	}
+}
 
+// filterPolicy resolves the policy from the authenticated token and its user's group
+func filterPolicy(c *gin.Context) *filter.Policy {
+	group, err := dbmodel.CacheGetUserGroup(c.GetInt(ctxkey.Id))
+	if err != nil {
+		logger.Errorf(c.Request.Context(), "获取用户分组失败: %v", err)
+	}
+	return filter.PolicyFor(group, c.GetString(ctxkey.TokenFilterPolicy))
+}
+
+// recordFilterHits records the hits of the request or response for moderation
+func recordFilterHits(c *gin.Context, direction string, modelName string, result *filter.Result) {
+	dbmodel.RecordFilterResult(c.Request.Context(), dbmodel.FilterHit{
+		UserId:    c.GetInt(ctxkey.Id),
+		TokenId:   c.GetInt(ctxkey.TokenId),
+		TokenName: c.GetString(ctxkey.TokenName),
+		ModelName: modelName,
+		Direction: direction,
+	}, result)
+}
+
+// SensitiveFilter 敏感词过滤中间件，需在 TokenAuth 之后使用，代理接口检查 JSON 内容中的所有文本，
+// 实时接口的内容无法过滤，策略需要检查时拒绝请求
+func SensitiveFilter() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !config.SensitiveFilterEnabled {
+		relayMode := relaymode.GetByPath(c.Request.URL.Path)
+		if relayMode == relaymode.Unknown {
 			c.Next()
 			return
 		}
 
-		path := c.Request.URL.Path
-		if !strings.HasPrefix(path, "/v1/chat/completions") &&
-			!strings.HasPrefix(path, "/v1/completions") {
+		policy := filterPolicy(c)
+		if policy == nil || (!policy.CheckRequest && !policy.CheckResponse) {
 			c.Next()
 			return
 		}
-
-		requestBodyBytes, err := common.GetRequestBody(c)
-		if err != nil {
-			logger.Errorf(c.Request.Context(), "读取请求体失败: %v", err)
-			c.Next()
+		if relayMode == relaymode.Realtime {
+			abortWithMessage(c, http.StatusForbidden, "当前的内容过滤策略不支持 Realtime 接口")
+			return
+		}
+		c.Set(ctxkey.FilterPolicy, policy)
+		var moderate filter.Moderator
+		moderation := filter.GetModeration()
+		if moderation != nil && policy.Moderation {
+			moderate = moderator(c.Request.Context(), moderation)
+		}
+		if relayMode == relaymode.Proxy {
+			filterProxy(c, policy, moderate)
 			return
 		}
 
-		var request model.GeneralOpenAIRequest
-		err = json.Unmarshal(requestBodyBytes, &request)
+		request, err := parseFilterRequest(c, relayMode)
 		if err != nil {
-			logger.Errorf(c.Request.Context(), "解析请求体JSON失败: %v", err)
+			// 无效的请求由转发时报告
+			logger.Warnf(c.Request.Context(), "解析请求失败，跳过敏感词过滤: %v", err)
 			c.Next()
 			return
 		}
-
-		// 请求内容敏感词检查 (对所有请求都执行)
-		for _, message := range request.Messages {
-			content := message.StringContent()
-			if containsSensitiveWords(content) {
-				logger.Warnf(c.Request.Context(), "请求中检测到敏感词，请求被拦截")
+		modelName := *request.model
+
+		// 请求内容检查
+		if policy.CheckRequest {
+			result := &filter.Result{}
+			changed := request.rewrite(checker(policy, result))
+			if moderate != nil && result.Action != filter.ActionBlock {
+				result.Merge(moderate(joinTexts(request.rewrite)))
+			}
+			if result.Hit() {
+				recordFilterHits(c, dbmodel.FilterDirectionRequest, modelName, result)
+			}
+			action := result.Action
+			if request.multipart && (changed || action == filter.ActionRoute) {
+				// 表单中的内容无法改写，按拦截处理
+				action = filter.ActionBlock
+			}
+			if action == filter.ActionRoute {
+				// 令牌的模型限制已在 TokenAuth 中按原模型检查，转发的目标模型同样需要在限制内，否则按拦截处理
+				if availableModels := c.GetString(ctxkey.AvailableModels); availableModels != "" && !isModelInList(result.Rule.RouteModel, availableModels) {
+					logger.Warnf(c.Request.Context(), "令牌无权使用过滤规则 #%d 转发的模型 %s，按拦截处理", result.Rule.Id, result.Rule.RouteModel)
+					action = filter.ActionBlock
+				}
+			}
+			switch action {
+			case filter.ActionBlock:
+				response := policy.ResponseFor(result.Rule.Category)
 				errorPayload := gin.H{
 					"id":      "chatcmpl-req-filter-" + strings.ReplaceAll(uuid.NewString(), "-", "")[:20],
 					"object":  "chat.completion",
 					"created": time.Now().Unix(),
-					"model":   request.Model,
+					"model":   modelName,
 					"choices": []gin.H{
 						{
 							"index": 0,
 							"message": gin.H{
 								"role":    "assistant",
-								"content": config.SensitiveFilterResponse,
+								"content": response,
 							},
 							"finish_reason": "content_filter",
 						},
 					},
 					"usage": gin.H{"prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0},
 					"error": gin.H{
-						"message": config.SensitiveFilterResponse,
+						"message": response,
 						"type":    "invalid_request_error",
 						"code":    "content_filter_request",
 					},
 				}
 				c.AbortWithStatusJSON(http.StatusBadRequest, errorPayload)
 				return
+			case filter.ActionRoute:
+				logger.Infof(c.Request.Context(), "请求被过滤规则 #%d 转发到模型 %s", result.Rule.Id, result.Rule.RouteModel)
+				*request.model = result.Rule.RouteModel
+				c.Set(ctxkey.RequestModel, result.Rule.RouteModel)
+				changed = true
+			}
+			if changed {
+				// 重试时从改写后的请求体重新解析
+				if newBody, err := json.Marshal(request.body); err == nil {
+					c.Set(ctxkey.KeyRequestBody, newBody)
+					c.Request.Body = io.NopCloser(bytes.NewBuffer(newBody))
+				} else {
+					logger.Errorf(c.Request.Context(), "重写请求体失败: %v", err)
+				}
 			}
 		}
 
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBodyBytes)) // Restore request body for c.Next()
-
-		if !request.Stream { // 非流式响应处理
+		if !policy.CheckResponse || !hasTextResponse(relayMode) {
+			c.Next()
+		} else if request.stream { // 流式响应处理
+			streamWriter := filter.NewStreamWriter(c.Writer, filter.Current(), policy)
+			if moderate != nil {
+				streamWriter.SetModerator(moderate, moderation.ChunkSize)
+			}
+			c.Writer = streamWriter
+			c.Next()
+			c.Writer = streamWriter.ResponseWriter
+			if err := streamWriter.Close(); err != nil {
+				logger.Errorf(c.Request.Context(), "写入流式响应失败: %v", err)
+			}
+			if result := streamWriter.Result(); result.Hit() {
+				recordFilterHits(c, dbmodel.FilterDirectionResponse, modelName, result)
+			}
+		} else { // 非流式响应处理
 			originalWriter := c.Writer
 			bufferingWriter := newFullyBufferingResponseWriter(originalWriter)
 			c.Writer = bufferingWriter
@@ -283,47 +451,137 @@ func SensitiveFilter() gin.HandlerFunc {
 					statusFromHandler = http.StatusOK
 				}
 
-				if statusFromHandler == http.StatusOK {
-					responseBodyStr := bufferingWriter.buffer.String()
-					// 对于非流式，isStreamResponse 应该总是 false，但为了保险可以检查
-					// isStreamResponse := strings.Contains(responseBodyStr, "data: ")
-
-					sensitiveFoundInResponse := false
-					// 处理非流式响应
-					var resp openai.TextResponse
-					if err := json.Unmarshal([]byte(responseBodyStr), &resp); err == nil {
-						for _, choice := range resp.Choices {
-							contentText := choice.Message.StringContent()
-							if contentText != "" && containsSensitiveWords(contentText) {
-								sensitiveFoundInResponse = true
-								break
-							}
-						}
-					} else {
-						logger.Warnf(c.Request.Context(), "无法解析非流式响应以检查敏感词: %v; 响应体: %s", err, responseBodyStr)
+				var responseBody map[string]any
+				if statusFromHandler == http.StatusOK && json.Unmarshal(bufferingWriter.buffer.Bytes(), &responseBody) == nil {
+					result := &filter.Result{}
+					changed := rewriteResponseTexts(responseBody, checker(policy, result))
+					if moderate != nil && result.Action != filter.ActionBlock {
+						result.Merge(moderate(joinTexts(func(rewrite func(string) string) bool {
+							return rewriteResponseTexts(responseBody, rewrite)
+						})))
 					}
-
-					if sensitiveFoundInResponse {
-						logger.Warnf(c.Request.Context(), "响应中检测到敏感词，响应被拦截")
+					if result.Hit() {
+						recordFilterHits(c, dbmodel.FilterDirectionResponse, modelName, result)
+					}
+					switch {
+					case result.Action == filter.ActionBlock || result.Action == filter.ActionRoute:
+						// 响应无法转发到其他模型，按拦截处理
 						createChatCompletionErrorResponse(c, http.StatusBadRequest,
 							"content_filter_response",
-							config.SensitiveFilterResponse,
+							policy.ResponseFor(result.Rule.Category),
 							"invalid_request_error",
-							request.Model)
+							modelName)
+					case changed:
+						if newBody, err := json.Marshal(responseBody); err == nil {
+							bufferingWriter.buffer.Reset()
+							bufferingWriter.buffer.Write(newBody)
+							bufferingWriter.headersToSetOnCommit.Del("Content-Length")
+						}
 					}
 				}
 			} // end if !c.IsAborted()
 
 			bufferingWriter.CommitToOriginalWriter()
 			c.Writer = originalWriter // Restore original writer
+		}
+	}
+}
+
+// decodeJSON decodes a json document keeping its numbers as they are, it reports false for other content
+func decodeJSON(body []byte) (any, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var document any
+	if decoder.Decode(&document) != nil || decoder.More() {
+		return nil, false
+	}
+	return document, true
+}
+
+// checkProxyJSON checks all texts of a json body of the proxy relay mode, the masked body is returned if changed
+func checkProxyJSON(c *gin.Context, policy *filter.Policy, moderate filter.Moderator, direction string, body []byte) (*filter.Result, []byte, bool) {
+	document, ok := decodeJSON(body)
+	if !ok {
+		return nil, nil, false
+	}
+	result := &filter.Result{}
+	changed := filter.RewriteJSON(&document, checker(policy, result))
+	if moderate != nil && result.Action != filter.ActionBlock {
+		result.Merge(moderate(joinTexts(func(rewrite func(string) string) bool {
+			return filter.RewriteJSON(&document, rewrite)
+		})))
+	}
+	if result.Hit() {
+		recordFilterHits(c, direction, c.GetString(ctxkey.RequestModel), result)
+	}
+	if !changed {
+		return result, nil, true
+	}
+	newBody, err := json.Marshal(document)
+	if err != nil {
+		logger.Errorf(c.Request.Context(), "重写代理内容失败: %v", err)
+		return result, nil, true
+	}
+	return result, newBody, true
+}
+
+// filterProxy 检查代理接口的请求与响应，接口格式未知，因此检查 JSON 内容中的所有文本，
+// 非 JSON 的内容无法检查，按拦截处理，命中改用模型规则时同样按拦截处理
+func filterProxy(c *gin.Context, policy *filter.Policy, moderate filter.Moderator) {
+	if policy.CheckRequest {
+		body, err := common.GetRequestBody(c)
+		if err != nil {
+			abortWithMessage(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(bytes.TrimSpace(body)) > 0 {
+			result, newBody, ok := checkProxyJSON(c, policy, moderate, dbmodel.FilterDirectionRequest, body)
+			if !ok {
+				abortWithMessage(c, http.StatusForbidden, "代理请求的内容不是 JSON，无法按内容过滤策略检查")
+				return
+			}
+			if result.Action == filter.ActionBlock || result.Action == filter.ActionRoute {
+				abortWithMessage(c, http.StatusBadRequest, policy.ResponseFor(result.Rule.Category))
+				return
+			}
+			if newBody != nil {
+				body = newBody
+				c.Set(ctxkey.KeyRequestBody, body)
+			}
+		}
+		// the body has been read, the proxy relay sends it from c.Request.Body
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+		c.Request.ContentLength = int64(len(body))
+	}
+	if !policy.CheckResponse {
+		c.Next()
+		return
+	}
 
-		} else { // 流式响应处理
-			logger.Infof(c.Request.Context(), "处理流式响应，跳过响应体敏感词过滤和缓冲。")
-			c.Next() // 直接让下游处理，不使用缓冲写入器
-			// 对于流式响应，我们无法安全地在发送后修改或检查内容，也无法可靠获取最终头部
-			// 除非我们实现一个完整的流代理，这超出了当前范围。
-			// 这里的日志是针对请求时的，流的头部由下游直接写给客户端。
-			logger.Infof(c.Request.Context(), "流式响应已由下游处理程序直接发送。")
+	originalWriter := c.Writer
+	bufferingWriter := newFullyBufferingResponseWriter(originalWriter)
+	c.Writer = bufferingWriter
+	c.Next()
+	if !c.IsAborted() && len(bytes.TrimSpace(bufferingWriter.buffer.Bytes())) > 0 {
+		result, newBody, ok := checkProxyJSON(c, policy, moderate, dbmodel.FilterDirectionResponse, bufferingWriter.buffer.Bytes())
+		message := ""
+		switch {
+		case !ok:
+			message = "代理响应的内容不是 JSON，无法按内容过滤策略检查"
+		case result.Action == filter.ActionBlock || result.Action == filter.ActionRoute:
+			message = policy.ResponseFor(result.Rule.Category)
+		case newBody != nil:
+			bufferingWriter.buffer.Reset()
+			bufferingWriter.buffer.Write(newBody)
+			bufferingWriter.headersToSetOnCommit.Del("Content-Length")
+		}
+		if message != "" {
+			bufferingWriter.buffer.Reset()
+			bufferingWriter.headerWritten = false
+			bufferingWriter.headersToSetOnCommit = make(http.Header)
+			abortWithMessage(c, http.StatusForbidden, message)
 		}
 	}
+	bufferingWriter.CommitToOriginalWriter()
+	c.Writer = originalWriter
 }
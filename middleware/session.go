@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+
+	"github.com/songquanpeng/one-api/model"
+)
+
+type sessionClientIpKey struct{}
+
+// SessionStore keeps session values in the database, the cookie only carries the signed session token,
+// so that sessions can be listed and revoked
+type SessionStore struct {
+	codecs  []securecookie.Codec
+	options *gsessions.Options
+}
+
+func NewSessionStore(keyPairs ...[]byte) *SessionStore {
+	store := &SessionStore{
+		codecs: securecookie.CodecsFromPairs(keyPairs...),
+		options: &gsessions.Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+	}
+	store.setMaxAge(store.options.MaxAge)
+	return store
+}
+
+func (s *SessionStore) setMaxAge(age int) {
+	for _, codec := range s.codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+		}
+	}
+}
+
+func (s *SessionStore) Options(options sessions.Options) {
+	s.options = options.ToGorillaOptions()
+	s.setMaxAge(s.options.MaxAge)
+}
+
+func (s *SessionStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session of the cookie, an unknown or revoked token results in an empty session
+func (s *SessionStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	options := *s.options
+	session.Options = &options
+	session.IsNew = true
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var token string
+	if err = securecookie.DecodeMulti(name, cookie.Value, &token, s.codecs...); err != nil {
+		// e.g. a cookie of the former cookie store
+		return session, nil
+	}
+	stored, err := model.GetSessionByToken(token)
+	if err != nil {
+		return session, nil
+	}
+	if err = (securecookie.GobEncoder{}).Deserialize(stored.Data, &session.Values); err != nil {
+		return session, nil
+	}
+	session.ID = token
+	session.IsNew = false
+	model.TouchSession(stored, sessionClientIp(r))
+	return session, nil
+}
+
+func (s *SessionStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.Options.MaxAge < 0 || len(session.Values) == 0 {
+		if session.ID != "" {
+			model.DeleteSessionByToken(session.ID)
+			session.ID = ""
+		}
+		options := *session.Options
+		options.MaxAge = -1
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", &options))
+		return nil
+	}
+	data, err := (securecookie.GobEncoder{}).Serialize(session.Values)
+	if err != nil {
+		return err
+	}
+	userId, _ := session.Values["id"].(int)
+	token, err := model.SaveSession(session.ID, userId, data, sessionClientIp(r), r.UserAgent(), session.Options.MaxAge)
+	if err != nil {
+		return err
+	}
+	session.ID = token
+	encoded, err := securecookie.EncodeMulti(session.Name(), token, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+func sessionClientIp(r *http.Request) string {
+	if ip, ok := r.Context().Value(sessionClientIpKey{}).(string); ok {
+		return ip
+	}
+	return r.RemoteAddr
+}
+
+// Session is sessions.Sessions with the client ip available to the store
+func Session(name string, store sessions.Store) gin.HandlerFunc {
+	handler := sessions.Sessions(name, store)
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), sessionClientIpKey{}, c.ClientIP()))
+		handler(c)
+	}
+}
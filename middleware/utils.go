@@ -36,7 +36,9 @@ func getRequestModel(c *gin.Context) (string, error) {
 			modelRequest.Model = c.Param("model")
 		}
 	}
-	if strings.HasPrefix(c.Request.URL.Path, "/v1/images/generations") {
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/images/generations") ||
+		strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") ||
+		strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
 		if modelRequest.Model == "" {
 			modelRequest.Model = "dall-e-2"
 		}
@@ -46,6 +48,11 @@ func getRequestModel(c *gin.Context) (string, error) {
 			modelRequest.Model = "whisper-1"
 		}
 	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/realtime") {
+		if modelRequest.Model == "" {
+			modelRequest.Model = c.Query("model")
+		}
+	}
 	return modelRequest.Model, nil
 }
 
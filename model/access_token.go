@@ -0,0 +1,175 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/network"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+const (
+	// ScopeSelf allows the routes of the user's own account, e.g. tokens, logs and top up
+	ScopeSelf = "self"
+	// ScopeSelfRead allows the read-only (GET) routes of the user's own account
+	ScopeSelfRead = "self:read"
+)
+
+const maxAccessTokensPerUser = 20
+
+// accessTokenUsedInterval limits how often the last used time is written
+const accessTokenUsedInterval = 60
+
+// AccessToken is a management api token, it acts on behalf of its user limited to its scopes
+type AccessToken struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Name         string `json:"name" gorm:"type:varchar(64)"`
+	Key          string `json:"key,omitempty" gorm:"-"` // plaintext key, only available right after creation
+	KeyHash      string `json:"-" gorm:"type:char(64);uniqueIndex"`
+	KeyPrefix    string `json:"key_prefix" gorm:"type:varchar(16)"`
+	Scopes       string `json:"scopes" gorm:"type:text"`               // comma separated permissions and self scopes
+	Subnet       string `json:"subnet" gorm:"default:''"`              // allowed subnets, empty means any ip
+	ExpiredTime  int64  `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	LastUsedTime int64  `json:"last_used_time" gorm:"bigint;default:0"`
+	LastUsedIp   string `json:"last_used_ip" gorm:"type:varchar(64);default:''"`
+}
+
+func AllScopes() []string {
+	return append([]string{ScopeSelf, ScopeSelfRead}, AllPermissions...)
+}
+
+func IsValidScope(scope string) bool {
+	return scope == ScopeSelf || scope == ScopeSelfRead || IsValidPermission(scope)
+}
+
+func (token *AccessToken) ScopeList() []string {
+	if token.Scopes == "" {
+		return nil
+	}
+	return strings.Split(token.Scopes, ",")
+}
+
+// HasScope reports whether the token covers the scope, self covers self:read
+func (token *AccessToken) HasScope(scope string) bool {
+	for _, s := range token.ScopeList() {
+		if s == scope || (s == ScopeSelf && scope == ScopeSelfRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the name, scopes, expiry and subnet and normalizes the scope list
+func (token *AccessToken) Validate() error {
+	token.Name = strings.TrimSpace(token.Name)
+	if token.Name == "" || len(token.Name) > 64 {
+		return errors.New("令牌名称长度必须在1-64之间")
+	}
+	var scopes []string
+	seen := map[string]bool{}
+	for _, scope := range strings.Split(token.Scopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || seen[scope] {
+			continue
+		}
+		if !IsValidScope(scope) {
+			return fmt.Errorf("未知的权限范围：%s", scope)
+		}
+		seen[scope] = true
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return errors.New("请至少选择一个权限范围")
+	}
+	token.Scopes = strings.Join(scopes, ",")
+	if token.ExpiredTime == 0 {
+		token.ExpiredTime = -1
+	}
+	if token.ExpiredTime != -1 && token.ExpiredTime <= helper.GetTimestamp() {
+		return errors.New("过期时间不能早于当前时间")
+	}
+	token.Subnet = strings.TrimSpace(token.Subnet)
+	if token.Subnet != "" {
+		if err := network.IsValidSubnets(token.Subnet); err != nil {
+			return fmt.Errorf("无效的网段：%s", err.Error())
+		}
+	}
+	return nil
+}
+
+func GetUserAccessTokens(userId int) (tokens []*AccessToken, err error) {
+	err = DB.Where("user_id = ?", userId).Order("id desc").Find(&tokens).Error
+	return tokens, err
+}
+
+// Insert generates the key of the token, it is only returned here and stored hashed
+func (token *AccessToken) Insert() error {
+	if err := token.Validate(); err != nil {
+		return err
+	}
+	var count int64
+	if err := DB.Model(&AccessToken{}).Where("user_id = ?", token.UserId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count >= maxAccessTokensPerUser {
+		return fmt.Errorf("每个用户最多只能创建 %d 个 access token", maxAccessTokensPerUser)
+	}
+	if token.Key == "" {
+		token.Key = random.GetUUID()
+	}
+	token.KeyHash = HashTokenKey(token.Key)
+	token.KeyPrefix = getTokenKeyPrefix(token.Key)
+	token.CreatedTime = helper.GetTimestamp()
+	return DB.Create(token).Error
+}
+
+func DeleteAccessTokenById(id int, userId int) error {
+	result := DB.Where("id = ? AND user_id = ?", id, userId).Delete(&AccessToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("access token 不存在")
+	}
+	return nil
+}
+
+// ErrInvalidAccessToken is returned for keys that do not belong to any access token
+var ErrInvalidAccessToken = errors.New("access token 无效")
+
+// ValidateAccessToken returns the user and the access token of the key if it is usable from ip
+func ValidateAccessToken(ctx context.Context, key string, ip string) (*User, *AccessToken, error) {
+	key = strings.TrimSpace(strings.TrimPrefix(key, "Bearer "))
+	if key == "" {
+		return nil, nil, ErrInvalidAccessToken
+	}
+	token := AccessToken{}
+	if err := DB.First(&token, "key_hash = ?", HashTokenKey(key)).Error; err != nil {
+		return nil, nil, ErrInvalidAccessToken
+	}
+	now := helper.GetTimestamp()
+	if token.ExpiredTime != -1 && token.ExpiredTime < now {
+		return nil, nil, errors.New("access token 已过期")
+	}
+	if token.Subnet != "" && !network.IsIpInSubnets(ctx, ip, token.Subnet) {
+		return nil, nil, fmt.Errorf("该 access token 只能在指定网段使用：%s，当前 ip：%s", token.Subnet, ip)
+	}
+	user := User{}
+	if err := DB.First(&user, "id = ?", token.UserId).Error; err != nil {
+		return nil, nil, ErrInvalidAccessToken
+	}
+	if now-token.LastUsedTime >= accessTokenUsedInterval || token.LastUsedIp != ip {
+		token.LastUsedTime = now
+		token.LastUsedIp = ip
+		DB.Model(&AccessToken{}).Where("id = ?", token.Id).Updates(map[string]any{
+			"last_used_time": now,
+			"last_used_ip":   ip,
+		})
+	}
+	return &user, &token, nil
+}
@@ -0,0 +1,215 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// AuditLog records who changed what through the admin APIs,
+// it is stored apart from logs so that it has its own retention
+type AuditLog struct {
+	Id         int    `json:"id"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+	ActorId    int    `json:"actor_id" gorm:"index"`
+	ActorName  string `json:"actor_name" gorm:"type:varchar(64);default:''"`
+	Action     string `json:"action" gorm:"type:varchar(64);index"`
+	TargetType string `json:"target_type" gorm:"type:varchar(32);index:idx_audit_target,priority:1"`
+	TargetId   string `json:"target_id" gorm:"type:varchar(64);index:idx_audit_target,priority:2"`
+	Diff       string `json:"diff" gorm:"type:text"` // json object of field -> {before, after}
+	Ip         string `json:"ip" gorm:"type:varchar(64);default:''"`
+	RequestId  string `json:"request_id" gorm:"type:varchar(64);default:''"`
+}
+
+const (
+	AuditTargetOption     = "option"
+	AuditTargetChannel    = "channel"
+	AuditTargetUser       = "user"
+	AuditTargetRedemption = "redemption"
+	AuditTargetRole       = "role"
+	AuditTargetBan        = "ban"
+	AuditTargetFilterRule = "filter_rule"
+	AuditTargetCapture    = "capture"
+)
+
+const (
+	AuditActionOptionUpdate          = "option.update"
+	AuditActionSensitiveFilterUpdate = "sensitive_filter.update"
+	AuditActionChannelCreate         = "channel.create"
+	AuditActionChannelUpdate         = "channel.update"
+	AuditActionChannelDelete         = "channel.delete"
+	AuditActionChannelDeleteDisabled = "channel.delete_disabled"
+	AuditActionChannelRevealKey      = "channel.reveal_key"
+	AuditActionChannelRotateKeys     = "channel.rotate_keys"
+	AuditActionRedemptionCreate      = "redemption.create"
+	AuditActionUserUpdate            = "user.update"
+	AuditActionUserTopUp             = "user.topup"
+	AuditActionUserRevokeSessions    = "user.revoke_sessions"
+	AuditActionRoleCreate            = "role.create"
+	AuditActionRoleUpdate            = "role.update"
+	AuditActionRoleDelete            = "role.delete"
+	AuditActionBanLift               = "ban.lift"
+	AuditActionFilterRuleCreate      = "filter_rule.create"
+	AuditActionFilterRuleUpdate      = "filter_rule.update"
+	AuditActionFilterRuleDelete      = "filter_rule.delete"
+	AuditActionCaptureView           = "capture.view"
+	// user management actions are recorded as "user." + the action of ManageUser, e.g. user.promote
+	AuditActionUserManagePrefix = "user."
+)
+
+const auditRedacted = "[redacted]"
+
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+func RecordAuditLog(ctx context.Context, log *AuditLog) {
+	log.CreatedAt = helper.GetTimestamp()
+	log.RequestId = helper.GetRequestID(ctx)
+	if log.ActorName == "" && log.ActorId != 0 {
+		log.ActorName = GetUsernameById(log.ActorId)
+	}
+	if err := DB.Create(log).Error; err != nil {
+		logger.Error(ctx, "failed to record audit log: "+err.Error())
+	}
+}
+
+// isSecretField matches field and option names such as key, access_token, sk or SMTPToken
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	switch name {
+	case "sk", "ak":
+		return true
+	}
+	for _, suffix := range []string{"key", "keys", "secret", "token", "password", "adc"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// toAuditMap converts a struct or map to its json fields, json objects stored as strings
+// (e.g. channel config) are expanded so that the secrets inside can be redacted
+func toAuditMap(v any) map[string]any {
+	result := map[string]any{}
+	if v == nil {
+		return result
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return result
+	}
+	_ = json.Unmarshal(data, &result)
+	for k, value := range result {
+		if s, ok := value.(string); ok && strings.HasPrefix(s, "{") {
+			var object map[string]any
+			if json.Unmarshal([]byte(s), &object) == nil {
+				result[k] = object
+			}
+		}
+	}
+	return result
+}
+
+func redactAuditValue(name string, value any) any {
+	if isSecretField(name) {
+		if value == nil || value == "" {
+			return value
+		}
+		return auditRedacted
+	}
+	if object, ok := value.(map[string]any); ok {
+		redacted := make(map[string]any, len(object))
+		for k, v := range object {
+			redacted[k] = redactAuditValue(k, v)
+		}
+		return redacted
+	}
+	return value
+}
+
+// AuditDiff returns the changed fields between before and after as json, secret values are redacted,
+// either side can be nil for created or deleted targets
+func AuditDiff(before any, after any) string {
+	beforeMap, afterMap := toAuditMap(before), toAuditMap(after)
+	changes := map[string]AuditChange{}
+	for k := range beforeMap {
+		if _, ok := afterMap[k]; !ok && after != nil {
+			continue // not part of the update
+		}
+		if !reflect.DeepEqual(beforeMap[k], afterMap[k]) {
+			changes[k] = AuditChange{Before: redactAuditValue(k, beforeMap[k]), After: redactAuditValue(k, afterMap[k])}
+		}
+	}
+	for k, value := range afterMap {
+		if _, ok := beforeMap[k]; !ok {
+			changes[k] = AuditChange{After: redactAuditValue(k, value)}
+		}
+	}
+	data, _ := json.Marshal(changes)
+	return string(data)
+}
+
+type AuditLogFilter struct {
+	ActorId        int
+	Action         string
+	TargetType     string
+	TargetId       string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func GetAuditLogs(filter AuditLogFilter, startIdx int, num int) (logs []*AuditLog, err error) {
+	tx := DB.Model(&AuditLog{})
+	if filter.ActorId != 0 {
+		tx = tx.Where("actor_id = ?", filter.ActorId)
+	}
+	if filter.Action != "" {
+		// "channel." matches all channel actions
+		if strings.HasSuffix(filter.Action, ".") {
+			tx = tx.Where("action LIKE ?", filter.Action+"%")
+		} else {
+			tx = tx.Where("action = ?", filter.Action)
+		}
+	}
+	if filter.TargetType != "" {
+		tx = tx.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetId != "" {
+		tx = tx.Where("target_id = ?", filter.TargetId)
+	}
+	if filter.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", filter.StartTimestamp)
+	}
+	if filter.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", filter.EndTimestamp)
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&logs).Error
+	return logs, err
+}
+
+func DeleteOldAuditLog(targetTimestamp int64) (int64, error) {
+	result := DB.Where("created_at < ?", targetTimestamp).Delete(&AuditLog{})
+	return result.RowsAffected, result.Error
+}
+
+// CleanAuditLogs deletes audit logs older than retentionDays every hour
+func CleanAuditLogs(retentionDays int) {
+	for {
+		count, err := DeleteOldAuditLog(time.Now().AddDate(0, 0, -retentionDays).Unix())
+		if err != nil {
+			logger.SysError("failed to delete old audit logs: " + err.Error())
+		} else if count > 0 {
+			logger.SysLog(fmt.Sprintf("deleted %d old audit logs", count))
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -26,18 +26,15 @@ var (
 )
 
 func CacheGetTokenByKey(key string) (*Token, error) {
-	keyCol := "`key`"
-	if common.UsingPostgreSQL {
-		keyCol = `"key"`
-	}
+	keyHash := HashTokenKey(key)
 	var token Token
 	if !common.RedisEnabled {
-		err := DB.Where(keyCol+" = ?", key).First(&token).Error
+		err := DB.Where("key_hash = ?", keyHash).First(&token).Error
 		return &token, err
 	}
-	tokenObjectString, err := common.RedisGet(fmt.Sprintf("token:%s", key))
+	tokenObjectString, err := common.RedisGet(fmt.Sprintf("token:%s", keyHash))
 	if err != nil {
-		err := DB.Where(keyCol+" = ?", key).First(&token).Error
+		err := DB.Where("key_hash = ?", keyHash).First(&token).Error
 		if err != nil {
 			return nil, err
 		}
@@ -45,7 +42,7 @@ func CacheGetTokenByKey(key string) (*Token, error) {
 		if err != nil {
 			return nil, err
 		}
-		err = common.RedisSet(fmt.Sprintf("token:%s", key), string(jsonBytes), time.Duration(TokenCacheSeconds)*time.Second)
+		err = common.RedisSet(fmt.Sprintf("token:%s", keyHash), string(jsonBytes), time.Duration(TokenCacheSeconds)*time.Second)
 		if err != nil {
 			logger.SysError("Redis set token error: " + err.Error())
 		}
@@ -55,6 +52,16 @@ func CacheGetTokenByKey(key string) (*Token, error) {
 	return &token, err
 }
 
+// CacheDeleteToken drops the cached token, so that e.g. disabling it takes effect at once
+func CacheDeleteToken(keyHash string) {
+	if !common.RedisEnabled || keyHash == "" {
+		return
+	}
+	if err := common.RedisDel(fmt.Sprintf("token:%s", keyHash)); err != nil {
+		logger.SysError("Redis delete token error: " + err.Error())
+	}
+}
+
 func CacheGetUserGroup(id int) (group string, err error) {
 	if !common.RedisEnabled {
 		return GetUserGroup(id)
@@ -148,6 +155,27 @@ func CacheIsUserEnabled(userId int) (bool, error) {
 	return userEnabled, err
 }
 
+func CacheGetUserIpLists(id int) (*UserIpLists, error) {
+	if !common.RedisEnabled {
+		return GetUserIpLists(id)
+	}
+	lists := &UserIpLists{}
+	data, err := common.RedisGet(fmt.Sprintf("user_ip_lists:%d", id))
+	if err == nil && json.Unmarshal([]byte(data), lists) == nil {
+		return lists, nil
+	}
+	lists, err = GetUserIpLists(id)
+	if err != nil {
+		return nil, err
+	}
+	jsonBytes, _ := json.Marshal(lists)
+	err = common.RedisSet(fmt.Sprintf("user_ip_lists:%d", id), string(jsonBytes), time.Duration(UserId2StatusCacheSeconds)*time.Second)
+	if err != nil {
+		logger.SysError("Redis set user ip lists error: " + err.Error())
+	}
+	return lists, nil
+}
+
 func CacheGetGroupModels(ctx context.Context, group string) ([]string, error) {
 	if !common.RedisEnabled {
 		return GetGroupModels(ctx, group)
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// maxCaptureBytes is the most a text column holds on mysql
+const maxCaptureBytes = 65535
+
+// RequestCapture keeps the request and response bodies of a relayed request for debugging,
+// it is stored with the logs and found by the request id of the consume log
+type RequestCapture struct {
+	Id         int    `json:"id"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+	RequestId  string `json:"request_id" gorm:"type:varchar(64);index"`
+	UserId     int    `json:"user_id" gorm:"index"`
+	TokenId    int    `json:"token_id" gorm:"index"`
+	ChannelId  int    `json:"channel_id"`
+	ModelName  string `json:"model_name" gorm:"default:''"`
+	Method     string `json:"method" gorm:"type:varchar(16)"`
+	Path       string `json:"path" gorm:"default:''"`
+	StatusCode int    `json:"status_code"`
+	Request    string `json:"request" gorm:"type:text"`  // redacted, cut at CaptureMaxBytes
+	Response   string `json:"response" gorm:"type:text"` // redacted, cut at CaptureMaxBytes, a stream is kept as sent
+	Truncated  bool   `json:"truncated"`
+}
+
+// ShouldCaptureRequest reports whether the request of the user and token is captured,
+// the listed users and tokens always are, the others by the sample rate
+func ShouldCaptureRequest(userId int, tokenId int) bool {
+	if inIdList(config.CaptureUserIds, userId) || inIdList(config.CaptureTokenIds, tokenId) {
+		return true
+	}
+	return config.CaptureSampleRate > 0 && rand.Float64() < config.CaptureSampleRate
+}
+
+func inIdList(list string, id int) bool {
+	if list == "" || id == 0 {
+		return false
+	}
+	for _, item := range strings.Split(list, ",") {
+		if itemId, err := strconv.Atoi(strings.TrimSpace(item)); err == nil && itemId == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateCaptureOption checks the value of a capture option
+func ValidateCaptureOption(key string, value string) error {
+	switch key {
+	case "CaptureUserIds", "CaptureTokenIds":
+		if value == "" {
+			return nil
+		}
+		for _, item := range strings.Split(value, ",") {
+			if id, err := strconv.Atoi(strings.TrimSpace(item)); err != nil || id <= 0 {
+				return fmt.Errorf("无效的 ID：%s", item)
+			}
+		}
+	case "CaptureSampleRate":
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return errors.New("采样率必须在 0 到 1 之间")
+		}
+	case "CaptureMaxBytes":
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 || size > maxCaptureBytes {
+			return fmt.Errorf("记录长度必须在 1 到 %d 字节之间", maxCaptureBytes)
+		}
+	}
+	return nil
+}
+
+// truncateCapture cuts the text at maxBytes without splitting a character, a text that was already cut
+// may end with a part of a character, which is removed as well
+func truncateCapture(text string, maxBytes int) (string, bool) {
+	text = strings.ToValidUTF8(text, "")
+	if len(text) <= maxBytes {
+		return text, false
+	}
+	end := maxBytes
+	for end > 0 && !isRuneStart(text[end]) {
+		end--
+	}
+	return text[:end], true
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// RecordRequestCapture saves the capture, the bodies are cut at CaptureMaxBytes
+func RecordRequestCapture(ctx context.Context, capture *RequestCapture) {
+	maxBytes := min(config.CaptureMaxBytes, maxCaptureBytes)
+	var requestTruncated, responseTruncated bool
+	capture.Request, requestTruncated = truncateCapture(capture.Request, maxBytes)
+	capture.Response, responseTruncated = truncateCapture(capture.Response, maxBytes)
+	capture.Truncated = capture.Truncated || requestTruncated || responseTruncated
+	capture.CreatedAt = helper.GetTimestamp()
+	capture.RequestId = helper.GetRequestID(ctx)
+	if err := LOG_DB.Create(capture).Error; err != nil {
+		logger.Error(ctx, "failed to record request capture: "+err.Error())
+	}
+}
+
+func GetRequestCapture(requestId string) (*RequestCapture, error) {
+	capture := &RequestCapture{}
+	err := LOG_DB.Where("request_id = ?", requestId).First(capture).Error
+	return capture, err
+}
+
+func DeleteOldRequestCaptures(targetTimestamp int64) (int64, error) {
+	result := LOG_DB.Where("created_at < ?", targetTimestamp).Delete(&RequestCapture{})
+	return result.RowsAffected, result.Error
+}
+
+// CleanRequestCaptures deletes captures older than retentionDays every hour
+func CleanRequestCaptures(retentionDays int) {
+	for {
+		count, err := DeleteOldRequestCaptures(time.Now().AddDate(0, 0, -retentionDays).Unix())
+		if err != nil {
+			logger.SysError("failed to delete old request captures: " + err.Error())
+		} else if count > 0 {
+			logger.SysLog(fmt.Sprintf("deleted %d old request captures", count))
+		}
+		time.Sleep(time.Hour)
+	}
+}
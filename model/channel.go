@@ -7,6 +7,7 @@ import (
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/secret"
 	"gorm.io/gorm"
 )
 
@@ -50,6 +51,16 @@ type ChannelConfig struct {
 	Plugin            string `json:"plugin,omitempty"`
 	VertexAIProjectID string `json:"vertex_ai_project_id,omitempty"`
 	VertexAIADC       string `json:"vertex_ai_adc,omitempty"`
+	// billing rules for the proxy relay mode
+	ProxyModelName            string `json:"proxy_model_name,omitempty"`
+	ProxyPromptTokensPath     string `json:"proxy_prompt_tokens_path,omitempty"`
+	ProxyCompletionTokensPath string `json:"proxy_completion_tokens_path,omitempty"`
+	ProxyQuotaPerCall         int64  `json:"proxy_quota_per_call,omitempty"`
+}
+
+// IsProxyBillingEnabled reports whether requests proxied through this channel are billed
+func (cfg ChannelConfig) IsProxyBillingEnabled() bool {
+	return cfg.ProxyQuotaPerCall > 0 || cfg.ProxyPromptTokensPath != "" || cfg.ProxyCompletionTokensPath != ""
 }
 
 func GetAllChannels(startIdx int, num int, scope string) ([]*Channel, error) {
@@ -84,6 +95,11 @@ func GetChannelById(id int, selectAll bool) (*Channel, error) {
 
 func BatchInsertChannels(channels []Channel) error {
 	var err error
+	for i := range channels {
+		if err = channels[i].encryptSecrets(); err != nil {
+			return err
+		}
+	}
 	err = DB.Create(&channels).Error
 	if err != nil {
 		return err
@@ -126,6 +142,9 @@ func (channel *Channel) GetModelMapping() map[string]string {
 
 func (channel *Channel) Insert() error {
 	var err error
+	if err = channel.encryptSecrets(); err != nil {
+		return err
+	}
 	err = DB.Create(channel).Error
 	if err != nil {
 		return err
@@ -136,6 +155,12 @@ func (channel *Channel) Insert() error {
 
 func (channel *Channel) Update() error {
 	var err error
+	if err = channel.keepConfigSecrets(); err != nil {
+		return err
+	}
+	if err = channel.encryptSecrets(); err != nil {
+		return err
+	}
 	err = DB.Model(channel).Updates(channel).Error
 	if err != nil {
 		return err
@@ -187,6 +212,186 @@ func (channel *Channel) LoadConfig() (ChannelConfig, error) {
 	return cfg, nil
 }
 
+func (cfg *ChannelConfig) secretFields() []*string {
+	return []*string{&cfg.SK, &cfg.AK, &cfg.VertexAIADC}
+}
+
+// secretConfigKeys are the json keys of the secret config fields
+var secretConfigKeys = []string{"sk", "ak", "vertex_ai_adc"}
+
+// ClearedSecret is sent by the edit form for a secret config field the admin clears,
+// an empty field is left redacted and keeps the stored secret
+const ClearedSecret = "[clear]"
+
+// encryptSecrets encrypts the key and the secret config fields before they are written
+func (channel *Channel) encryptSecrets() error {
+	var err error
+	if channel.Key, err = secret.Encrypt(channel.Key); err != nil {
+		return err
+	}
+	return channel.transformConfigSecrets(secret.Encrypt)
+}
+
+// patchConfigSecrets calls patch with the secret fields of the config, only the secret fields are
+// written back, so that the other fields are kept as they are, including ones this version does not know
+func (channel *Channel) patchConfigSecrets(patch func(fields map[string]json.RawMessage) error) error {
+	if channel.Config == "" {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(channel.Config), &fields); err != nil {
+		return err
+	}
+	if err := patch(fields); err != nil {
+		return err
+	}
+	jsonBytes, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	channel.Config = string(jsonBytes)
+	return nil
+}
+
+func getConfigSecret(fields map[string]json.RawMessage, key string) (string, error) {
+	raw, ok := fields[key]
+	if !ok {
+		return "", nil
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("config field %s: %w", key, err)
+	}
+	return value, nil
+}
+
+func setConfigSecret(fields map[string]json.RawMessage, key string, value string) {
+	if value == "" {
+		delete(fields, key)
+		return
+	}
+	raw, _ := json.Marshal(value)
+	fields[key] = raw
+}
+
+func (channel *Channel) transformConfigSecrets(transform func(string) (string, error)) error {
+	return channel.patchConfigSecrets(func(fields map[string]json.RawMessage) error {
+		for _, key := range secretConfigKeys {
+			value, err := getConfigSecret(fields, key)
+			if err != nil {
+				return err
+			}
+			if value, err = transform(value); err != nil {
+				return err
+			}
+			setConfigSecret(fields, key, value)
+		}
+		return nil
+	})
+}
+
+// keepConfigSecrets keeps the stored secrets for config fields left empty by the redacted edit form,
+// and removes the ones sent as ClearedSecret
+func (channel *Channel) keepConfigSecrets() error {
+	if channel.Config == "" {
+		return nil
+	}
+	stored := Channel{}
+	if err := DB.Select("config").First(&stored, "id = ?", channel.Id).Error; err != nil {
+		return err
+	}
+	var storedFields map[string]json.RawMessage
+	if stored.Config != "" {
+		if err := json.Unmarshal([]byte(stored.Config), &storedFields); err != nil {
+			storedFields = nil
+		}
+	}
+	return channel.patchConfigSecrets(func(fields map[string]json.RawMessage) error {
+		for _, key := range secretConfigKeys {
+			value, err := getConfigSecret(fields, key)
+			if err != nil {
+				return err
+			}
+			switch value {
+			case ClearedSecret:
+				delete(fields, key)
+			case "":
+				if raw, ok := storedFields[key]; ok {
+					fields[key] = raw
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// RedactSecrets removes the key and secret config fields before the channel is returned by admin APIs
+func (channel *Channel) RedactSecrets() {
+	channel.Key = ""
+	_ = channel.transformConfigSecrets(func(value string) (string, error) {
+		return "", nil
+	})
+}
+
+// GetDecryptedKey should only be called right before the key is sent upstream
+func (channel *Channel) GetDecryptedKey() (string, error) {
+	return secret.Decrypt(channel.Key)
+}
+
+// LoadDecryptedConfig should only be called right before the config is used upstream
+func (channel *Channel) LoadDecryptedConfig() (ChannelConfig, error) {
+	cfg, err := channel.LoadConfig()
+	if err != nil {
+		return cfg, err
+	}
+	for _, field := range cfg.secretFields() {
+		if *field, err = secret.Decrypt(*field); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// RotateChannelSecrets encrypts plain secrets and re-wraps the ones encrypted with a previous master key
+func RotateChannelSecrets() (int, error) {
+	if !secret.Enabled() {
+		return 0, secret.ErrMasterKeyNotSet
+	}
+	var channels []*Channel
+	if err := DB.Select("id", "key", "config").Find(&channels).Error; err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, channel := range channels {
+		cfg, err := channel.LoadConfig()
+		if err != nil {
+			return count, fmt.Errorf("channel #%d: %w", channel.Id, err)
+		}
+		needsRotation := secret.NeedsRotation(channel.Key)
+		for _, field := range cfg.secretFields() {
+			needsRotation = needsRotation || secret.NeedsRotation(*field)
+		}
+		if !needsRotation {
+			continue
+		}
+		if channel.Key, err = secret.Rotate(channel.Key); err != nil {
+			return count, fmt.Errorf("channel #%d: %w", channel.Id, err)
+		}
+		if err = channel.transformConfigSecrets(secret.Rotate); err != nil {
+			return count, fmt.Errorf("channel #%d: %w", channel.Id, err)
+		}
+		err = DB.Model(&Channel{}).Where("id = ?", channel.Id).Updates(map[string]any{
+			"key":    channel.Key,
+			"config": channel.Config,
+		}).Error
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
 func UpdateChannelStatusById(id int, status int) {
 	err := UpdateAbilityStatus(id, status == ChannelStatusEnabled)
 	if err != nil {
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/filter"
+)
+
+const (
+	FilterDirectionRequest  = "request"
+	FilterDirectionResponse = "response"
+	// the system prompt of the channel
+	FilterDirectionSystemPrompt = "system_prompt"
+)
+
+// FilterHit records a match of a filter rule, it is stored with the logs
+type FilterHit struct {
+	Id        int    `json:"id"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	Username  string `json:"username" gorm:"default:''"`
+	TokenId   int    `json:"token_id" gorm:"index"`
+	TokenName string `json:"token_name" gorm:"default:''"`
+	ModelName string `json:"model_name" gorm:"default:''"`
+	Direction string `json:"direction" gorm:"type:varchar(16)"`
+	RuleId    int    `json:"rule_id" gorm:"index"`
+	Category  string `json:"category" gorm:"type:varchar(32);index"`
+	Action    string `json:"action" gorm:"type:varchar(16)"`
+	Snippet   string `json:"snippet" gorm:"type:text"` // the match in its context, masked and redacted
+	RequestId string `json:"request_id" gorm:"default:''"`
+}
+
+// RecordFilterHits records the hits of a request, the user and token are the same for all hits
+func RecordFilterHits(ctx context.Context, hits []*FilterHit) {
+	if len(hits) == 0 {
+		return
+	}
+	now := helper.GetTimestamp()
+	requestId := helper.GetRequestID(ctx)
+	username := GetUsernameById(hits[0].UserId)
+	for _, hit := range hits {
+		hit.CreatedAt = now
+		hit.RequestId = requestId
+		hit.Username = username
+	}
+	if err := LOG_DB.Create(&hits).Error; err != nil {
+		logger.Error(ctx, "failed to record filter hits: "+err.Error())
+	}
+}
+
+// RecordFilterResult records the hits of a result, base carries the user, token, model and direction
+func RecordFilterResult(ctx context.Context, base FilterHit, result *filter.Result) {
+	hits := make([]*FilterHit, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		logger.Warnf(ctx, "filter rule #%d hit in %s, category: %s, action: %s", hit.Rule.Id, base.Direction, hit.Rule.Category, hit.Rule.Action)
+		record := base
+		record.RuleId = hit.Rule.Id
+		record.Category = hit.Rule.Category
+		record.Action = hit.Rule.Action
+		record.Snippet = hit.Snippet
+		hits = append(hits, &record)
+	}
+	RecordFilterHits(ctx, hits)
+}
+
+type FilterHitFilter struct {
+	UserId         int
+	TokenId        int
+	RuleId         int
+	Category       string
+	Direction      string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func (filter FilterHitFilter) apply() *gorm.DB {
+	tx := LOG_DB.Model(&FilterHit{})
+	if filter.UserId != 0 {
+		tx = tx.Where("user_id = ?", filter.UserId)
+	}
+	if filter.TokenId != 0 {
+		tx = tx.Where("token_id = ?", filter.TokenId)
+	}
+	if filter.RuleId != 0 {
+		tx = tx.Where("rule_id = ?", filter.RuleId)
+	}
+	if filter.Category != "" {
+		tx = tx.Where("category = ?", filter.Category)
+	}
+	if filter.Direction != "" {
+		tx = tx.Where("direction = ?", filter.Direction)
+	}
+	if filter.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", filter.StartTimestamp)
+	}
+	if filter.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", filter.EndTimestamp)
+	}
+	return tx
+}
+
+func GetFilterHits(filter FilterHitFilter, startIdx int, num int) (hits []*FilterHit, err error) {
+	err = filter.apply().Order("id desc").Limit(num).Offset(startIdx).Find(&hits).Error
+	return hits, err
+}
+
+const (
+	FilterHitStatByRule = "rule"
+	FilterHitStatByUser = "user"
+	FilterHitStatByDay  = "day"
+)
+
+type FilterHitStat struct {
+	RuleId   int    `json:"rule_id,omitempty"`
+	Category string `json:"category,omitempty"`
+	UserId   int    `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Day      string `json:"day,omitempty"`
+	Count    int64  `json:"count"`
+}
+
+// GetFilterHitStats counts the hits by rule, user or day, the busiest rules and users come first
+func GetFilterHitStats(filter FilterHitFilter, by string) (stats []*FilterHitStat, err error) {
+	tx := filter.apply()
+	switch by {
+	case FilterHitStatByRule:
+		tx = tx.Select("rule_id, category, count(1) as count").Group("rule_id, category").Order("count desc")
+	case FilterHitStatByUser:
+		tx = tx.Select("user_id, username, count(1) as count").Group("user_id, username").Order("count desc")
+	case FilterHitStatByDay:
+		tx = tx.Select(dayGroupSelect() + ", count(1) as count").Group("day").Order("day")
+	default:
+		return nil, errors.New("无效的统计方式")
+	}
+	err = tx.Scan(&stats).Error
+	return stats, err
+}
+
+// dayGroupSelect selects the day of created_at as day
+func dayGroupSelect() string {
+	if common.UsingPostgreSQL {
+		return "TO_CHAR(date_trunc('day', to_timestamp(created_at)), 'YYYY-MM-DD') as day"
+	}
+	if common.UsingSQLite {
+		return "strftime('%Y-%m-%d', datetime(created_at, 'unixepoch')) as day"
+	}
+	return "DATE_FORMAT(FROM_UNIXTIME(created_at), '%Y-%m-%d') as day"
+}
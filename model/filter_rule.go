@@ -0,0 +1,347 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/filter"
+)
+
+const (
+	FilterRuleStatusEnabled  = 1
+	FilterRuleStatusDisabled = 2
+)
+
+// FilterRule is a content filter rule applied to relayed requests and responses
+type FilterRule struct {
+	Id          int    `json:"id"`
+	Type        string `json:"type" gorm:"type:varchar(16)"`
+	Pattern     string `json:"pattern" gorm:"type:text"`
+	Category    string `json:"category" gorm:"type:varchar(32);index"`
+	Severity    int    `json:"severity" gorm:"default:1"` // the most severe rule decides the response of a category
+	Action      string `json:"action" gorm:"type:varchar(16)"`
+	RouteModel  string `json:"route_model" gorm:"type:varchar(64);default:''"` // the safer model of the route action
+	Description string `json:"description" gorm:"type:varchar(255);default:''"`
+	Status      int    `json:"status" gorm:"default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+func (rule *FilterRule) toRule() *filter.Rule {
+	return &filter.Rule{
+		Id:         rule.Id,
+		Type:       rule.Type,
+		Pattern:    rule.Pattern,
+		Category:   rule.Category,
+		Severity:   rule.Severity,
+		Action:     rule.Action,
+		RouteModel: rule.RouteModel,
+	}
+}
+
+// Validate fills the defaults and checks the rule
+func (rule *FilterRule) Validate() error {
+	if rule.Type == "" {
+		rule.Type = filter.TypeLiteral
+	}
+	if rule.Category == "" {
+		rule.Category = filter.CategoryDefault
+	}
+	if rule.Action == "" {
+		rule.Action = filter.ActionBlock
+	}
+	if rule.Status == 0 {
+		rule.Status = FilterRuleStatusEnabled
+	}
+	if rule.Status != FilterRuleStatusEnabled && rule.Status != FilterRuleStatusDisabled {
+		return errors.New("无效的规则状态")
+	}
+	if rule.Action != filter.ActionRoute {
+		rule.RouteModel = ""
+	}
+	return rule.toRule().Validate()
+}
+
+func GetFilterRules(startIdx int, num int, category string, keyword string) (rules []*FilterRule, err error) {
+	tx := DB.Model(&FilterRule{})
+	if category != "" {
+		tx = tx.Where("category = ?", category)
+	}
+	if keyword != "" {
+		tx = tx.Where("pattern LIKE ? OR description LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&rules).Error
+	return rules, err
+}
+
+func GetFilterRuleById(id int) (*FilterRule, error) {
+	rule := FilterRule{}
+	err := DB.First(&rule, "id = ?", id).Error
+	return &rule, err
+}
+
+func (rule *FilterRule) Insert() error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	rule.CreatedTime = helper.GetTimestamp()
+	rule.UpdatedTime = rule.CreatedTime
+	if err := DB.Create(rule).Error; err != nil {
+		return err
+	}
+	return reloadFilterRules()
+}
+
+func (rule *FilterRule) Update() error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	rule.UpdatedTime = helper.GetTimestamp()
+	err := DB.Model(rule).Select("type", "pattern", "category", "severity", "action", "route_model",
+		"description", "status", "updated_time").Updates(rule).Error
+	if err != nil {
+		return err
+	}
+	return reloadFilterRules()
+}
+
+func DeleteFilterRuleById(id int) error {
+	if err := DB.Delete(&FilterRule{}, "id = ?", id).Error; err != nil {
+		return err
+	}
+	return reloadFilterRules()
+}
+
+const (
+	// filterRulesVersionKey is the option of the version of the rules, it is increased on every change
+	filterRulesVersionKey = "FilterRulesVersion"
+	// filterRulesChannel is the redis channel on which the new versions are published
+	filterRulesChannel = "filter_rules"
+)
+
+var filterRulesLock sync.Mutex
+
+// filterRulesVersion is the version the engine was built from, -1 before the rules are loaded
+var filterRulesVersion int64 = -1
+
+// LoadFilterRules rebuilds the filter engine from the enabled rules, and the words of the sensitive
+// words file in file mode, the engine is replaced at once
+func LoadFilterRules() error {
+	filterRulesLock.Lock()
+	defer filterRulesLock.Unlock()
+	// read the version first, a change in between is loaded again on the next sync
+	version, err := getFilterRulesVersion()
+	if err != nil {
+		return err
+	}
+	var rules []*FilterRule
+	if err := DB.Where("status = ?", FilterRuleStatusEnabled).Find(&rules).Error; err != nil {
+		return err
+	}
+	compiled := make([]*filter.Rule, 0, len(rules))
+	for _, rule := range rules {
+		compiled = append(compiled, rule.toRule())
+	}
+	if config.SensitiveWordsFileEnabled {
+		compiled = append(compiled, readSensitiveWordsFile()...)
+	}
+	filter.Load(compiled)
+	filterRulesVersion = version
+	return nil
+}
+
+func getFilterRulesVersion() (int64, error) {
+	option := Option{}
+	err := DB.Where(&Option{Key: filterRulesVersionKey}).Limit(1).Find(&option).Error
+	if err != nil || option.Value == "" {
+		return 0, err
+	}
+	return strconv.ParseInt(option.Value, 10, 64)
+}
+
+// bumpFilterRulesVersion increases the version of the rules, the update only succeeds if the version
+// has not been increased by another node in the meantime
+func bumpFilterRulesVersion() error {
+	for i := 0; i < 10; i++ {
+		option := Option{}
+		err := DB.Where(Option{Key: filterRulesVersionKey}).Attrs(Option{Value: "0"}).FirstOrCreate(&option).Error
+		if err != nil {
+			return err
+		}
+		version, err := strconv.ParseInt(option.Value, 10, 64)
+		if err != nil {
+			return err
+		}
+		result := DB.Model(&Option{}).Where(&Option{Key: filterRulesVersionKey, Value: option.Value}).
+			Update("value", strconv.FormatInt(version+1, 10))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil
+		}
+	}
+	return errors.New("failed to update the version of the filter rules")
+}
+
+// reloadFilterRules increases the version after a change of the rules, reloads them,
+// and notifies the other nodes
+func reloadFilterRules() error {
+	if err := bumpFilterRulesVersion(); err != nil {
+		return err
+	}
+	if err := LoadFilterRules(); err != nil {
+		return err
+	}
+	if common.RedisEnabled {
+		filterRulesLock.Lock()
+		version := filterRulesVersion
+		filterRulesLock.Unlock()
+		if err := common.RedisPublish(filterRulesChannel, strconv.FormatInt(version, 10)); err != nil {
+			logger.SysError("failed to publish the filter rules version: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// SyncFilterRules reloads the rules if the version is newer than that of the engine,
+// it is called with the versions published by other nodes and synced with the options
+func SyncFilterRules(version string) {
+	v, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return
+	}
+	filterRulesLock.Lock()
+	loaded := filterRulesVersion
+	filterRulesLock.Unlock()
+	// the rules are not loaded yet at startup, or are up to date
+	if loaded < 0 || v <= loaded {
+		return
+	}
+	logger.SysLogf("filter rules changed to version %d, reloading", v)
+	if err := LoadFilterRules(); err != nil {
+		logger.SysError("failed to reload filter rules: " + err.Error())
+	}
+}
+
+// SubscribeFilterRules reloads the rules when another node publishes a new version
+func SubscribeFilterRules() {
+	pubsub := common.RedisSubscribe(filterRulesChannel)
+	for message := range pubsub.Channel() {
+		SyncFilterRules(message.Payload)
+	}
+}
+
+// readSensitiveWordsFile returns the words of the sensitive words file as block rules of the default category
+func readSensitiveWordsFile() []*filter.Rule {
+	content, err := os.ReadFile(config.SensitiveWordsFile)
+	if err != nil {
+		logger.SysError("failed to read sensitive words file: " + err.Error())
+		return nil
+	}
+	var rules []*filter.Rule
+	for _, word := range parseSensitiveWords(string(content)) {
+		rules = append(rules, &filter.Rule{
+			Type:     filter.TypeLiteral,
+			Pattern:  word,
+			Category: filter.CategoryDefault,
+			Severity: 1,
+			Action:   filter.ActionBlock,
+		})
+	}
+	return rules
+}
+
+// WatchSensitiveWordsFile reloads the rules when the sensitive words file changes, the directory is watched
+// so that files replaced by editors or by symlink swaps of mounted volumes are noticed as well
+func WatchSensitiveWordsFile() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.SysError("failed to watch sensitive words file: " + err.Error())
+		return
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(config.SensitiveWordsFile)); err != nil {
+		logger.SysError("failed to watch sensitive words file: " + err.Error())
+		return
+	}
+	content, _ := os.ReadFile(config.SensitiveWordsFile)
+	for {
+		select {
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			newContent, _ := os.ReadFile(config.SensitiveWordsFile)
+			if string(newContent) == string(content) {
+				continue
+			}
+			content = newContent
+			logger.SysLog("sensitive words file changed, reloading filter rules")
+			if err := LoadFilterRules(); err != nil {
+				logger.SysError("failed to reload filter rules: " + err.Error())
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.SysError("sensitive words file watcher error: " + err.Error())
+		}
+	}
+}
+
+func parseSensitiveWords(content string) (words []string) {
+	for _, line := range strings.Split(content, "\n") {
+		for _, word := range strings.Split(line, ",") {
+			if word = strings.TrimSpace(word); word != "" {
+				words = append(words, word)
+			}
+		}
+	}
+	return words
+}
+
+// defaultSensitiveWords is the content of the sensitive words file created by older versions
+const defaultSensitiveWords = "敏感词1\n敏感词2\n敏感词3"
+
+// migrateSensitiveWords imports the words of the sensitive words file as literal block rules,
+// it runs once when the rule table is created, unless the file is used in file mode
+func migrateSensitiveWords() error {
+	if config.SensitiveWordsFileEnabled {
+		return nil
+	}
+	content, err := os.ReadFile(config.SensitiveWordsFile)
+	if err != nil || strings.TrimSpace(string(content)) == defaultSensitiveWords {
+		return nil
+	}
+	now := helper.GetTimestamp()
+	var rules []*FilterRule
+	for _, word := range parseSensitiveWords(string(content)) {
+		rules = append(rules, &FilterRule{
+			Type:        filter.TypeLiteral,
+			Pattern:     word,
+			Category:    filter.CategoryDefault,
+			Severity:    1,
+			Action:      filter.ActionBlock,
+			Status:      FilterRuleStatusEnabled,
+			CreatedTime: now,
+			UpdatedTime: now,
+		})
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	logger.SysLog(fmt.Sprintf("importing %d sensitive words from %s as filter rules", len(rules), config.SensitiveWordsFile))
+	return DB.CreateInBatches(rules, 100).Error
+}
@@ -0,0 +1,203 @@
+package model
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	logArchivePrefix = "logs-"
+	logArchiveSuffix = ".jsonl.gz"
+)
+
+// logCleanPause is the pause between two batches, so that other writes to the logs table are not held up
+const logCleanPause = 100 * time.Millisecond
+
+// LogTypeNames are the names of the log types in LogRetentionDays
+var LogTypeNames = map[string]int{
+	"topup":   LogTypeTopup,
+	"consume": LogTypeConsume,
+	"manage":  LogTypeManage,
+	"system":  LogTypeSystem,
+	"test":    LogTypeTest,
+}
+
+// the cleanup of the scheduled job and of DeleteOldLog do not run at the same time,
+// so that no rows are archived twice
+var logCleanLock sync.Mutex
+
+// ParseLogRetention parses LogRetentionDays into the days of each log type, 0 keeps the logs forever
+func ParseLogRetention(value string) (map[string]int, error) {
+	retention := make(map[string]int)
+	if strings.TrimSpace(value) == "" {
+		return retention, nil
+	}
+	if err := json.Unmarshal([]byte(value), &retention); err != nil {
+		return nil, errors.New("日志保留天数格式错误，应为日志类型到天数的映射")
+	}
+	for name, days := range retention {
+		if _, ok := LogTypeNames[name]; !ok {
+			return nil, fmt.Errorf("未知的日志类型：%s", name)
+		}
+		if days < 0 {
+			return nil, fmt.Errorf("日志类型 %s 的保留天数不能为负数", name)
+		}
+	}
+	return retention, nil
+}
+
+// CleanLogs deletes the logs older than their retention every hour
+func CleanLogs() {
+	for {
+		cleanLogsByRetention()
+		time.Sleep(time.Hour)
+	}
+}
+
+func cleanLogsByRetention() {
+	retention, err := ParseLogRetention(config.LogRetentionDays)
+	if err != nil {
+		logger.SysError("invalid log retention: " + err.Error())
+		return
+	}
+	for name, days := range retention {
+		if days == 0 {
+			continue
+		}
+		logType := LogTypeNames[name]
+		count, err := deleteLogs(&logType, name, time.Now().AddDate(0, 0, -days).Unix())
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to delete old %s logs: %s", name, err.Error()))
+		}
+		if count > 0 {
+			logger.SysLog(fmt.Sprintf("deleted %d %s logs older than %d days", count, name, days))
+		}
+	}
+}
+
+// deleteLogs deletes the logs of the type created before the timestamp in batches, all types if logType is nil,
+// with LogArchiveEnabled each batch is archived before it is deleted
+func deleteLogs(logType *int, name string, before int64) (int64, error) {
+	logCleanLock.Lock()
+	defer logCleanLock.Unlock()
+	archivePath := filepath.Join(logArchiveDir(), fmt.Sprintf("%s%s-%s%s", logArchivePrefix, name, time.Now().Format("20060102-150405"), logArchiveSuffix))
+	batchSize := max(config.LogCleanBatchSize, 1)
+	var total int64
+	for {
+		tx := LOG_DB.Where("created_at < ?", before)
+		if logType != nil {
+			tx = tx.Where("type = ?", *logType)
+		}
+		var logs []*Log
+		if err := tx.Order("id").Limit(batchSize).Find(&logs).Error; err != nil {
+			return total, err
+		}
+		if len(logs) == 0 {
+			return total, nil
+		}
+		if config.LogArchiveEnabled {
+			if err := appendLogArchive(archivePath, logs); err != nil {
+				return total, fmt.Errorf("failed to archive logs: %w", err)
+			}
+		}
+		ids := make([]int, len(logs))
+		for i, log := range logs {
+			ids[i] = log.Id
+		}
+		result := LOG_DB.Where("id IN ?", ids).Delete(&Log{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if len(logs) < batchSize {
+			return total, nil
+		}
+		time.Sleep(logCleanPause)
+	}
+}
+
+func logArchiveDir() string {
+	if config.LogArchiveDir != "" {
+		return config.LogArchiveDir
+	}
+	return filepath.Join(logger.LogDir, "archive")
+}
+
+// appendLogArchive appends the logs to the archive as a gzip member of a json line per log, and syncs it
+// before the logs are deleted, the members of an archive are read as one stream by gzip tools
+func appendLogArchive(path string, logs []*Log) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := gzip.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, log := range logs {
+		if err := encoder.Encode(log); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// LogArchive is an archive file of deleted logs
+type LogArchive struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// GetLogArchives lists the archives, the latest first
+func GetLogArchives() ([]*LogArchive, error) {
+	paths, err := filepath.Glob(filepath.Join(logArchiveDir(), logArchivePrefix+"*"+logArchiveSuffix))
+	if err != nil {
+		return nil, err
+	}
+	archives := make([]*LogArchive, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		archives = append(archives, &LogArchive{Name: filepath.Base(path), Size: info.Size(), UpdatedAt: info.ModTime().Unix()})
+	}
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].UpdatedAt > archives[j].UpdatedAt
+	})
+	return archives, nil
+}
+
+// GetLogArchivePath returns the path of the archive, the name must be one listed by GetLogArchives
+func GetLogArchivePath(name string) (string, error) {
+	if name != filepath.Base(name) || !strings.HasPrefix(name, logArchivePrefix) || !strings.HasSuffix(name, logArchiveSuffix) {
+		return "", errors.New("无效的归档文件名")
+	}
+	path := filepath.Join(logArchiveDir(), name)
+	if !fileExists(path) {
+		return "", errors.New("归档文件不存在")
+	}
+	return path, nil
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -2,13 +2,14 @@ package model
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/env"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
-	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/common/secret"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
@@ -30,20 +31,27 @@ func CreateRootAccountIfNeed() error {
 		if err != nil {
 			return err
 		}
-		accessToken := random.GetUUID()
-		if config.InitialRootAccessToken != "" {
-			accessToken = config.InitialRootAccessToken
-		}
 		rootUser := User{
 			Username:    "root",
 			Password:    hashedPassword,
 			Role:        RoleRootUser,
 			Status:      UserStatusEnabled,
 			DisplayName: "Root User",
-			AccessToken: accessToken,
 			Quota:       500000000000000,
 		}
 		DB.Create(&rootUser)
+		if config.InitialRootAccessToken != "" {
+			logger.SysLog("creating initial root access token as requested")
+			accessToken := AccessToken{
+				UserId: rootUser.Id,
+				Name:   "Initial Root Access Token",
+				Key:    config.InitialRootAccessToken,
+				Scopes: strings.Join(AllScopes(), ","),
+			}
+			if err := accessToken.Insert(); err != nil {
+				logger.SysError("failed to create initial root access token: " + err.Error())
+			}
+		}
 		if config.InitialRootToken != "" {
 			logger.SysLog("creating initial root token as requested")
 			token := Token{
@@ -58,7 +66,7 @@ func CreateRootAccountIfNeed() error {
 				RemainQuota:    500000000000000,
 				UnlimitedQuota: true,
 			}
-			DB.Create(&token)
+			_ = token.Insert()
 		}
 	}
 	return nil
@@ -157,12 +165,195 @@ func migrateDB() error {
 	if err = DB.AutoMigrate(&Log{}); err != nil {
 		return err
 	}
+	if err = DB.AutoMigrate(&FilterHit{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&RequestCapture{}); err != nil {
+		return err
+	}
 	if err = DB.AutoMigrate(&Channel{}); err != nil {
 		return err
 	}
+	if err = DB.AutoMigrate(&AuditLog{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&Role{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&AccessToken{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&Session{}); err != nil {
+		return err
+	}
+	hasFilterRules := DB.Migrator().HasTable(&FilterRule{})
+	if err = DB.AutoMigrate(&FilterRule{}); err != nil {
+		return err
+	}
+	if !hasFilterRules {
+		if err = migrateSensitiveWords(); err != nil {
+			return err
+		}
+	}
+	if err = migrateUserAccessTokens(); err != nil {
+		return err
+	}
+	if err = migrateTokenKeys(); err != nil {
+		return err
+	}
+	if secret.Enabled() {
+		count, err := RotateChannelSecrets()
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			logger.SysLog(fmt.Sprintf("encrypted secrets of %d channels with the current master key", count))
+		}
+	}
 	return nil
 }
 
+// migrateTokenKeys hashes the plaintext keys of old tokens and drops the plaintext column,
+// the column is only dropped once every stored key is verified to match its hash
+func migrateTokenKeys() error {
+	// HasColumn of sqlite also matches key_hash, so look for the exact column name
+	columnTypes, err := DB.Migrator().ColumnTypes(&Token{})
+	if err != nil {
+		return err
+	}
+	hasKeyColumn := false
+	for _, columnType := range columnTypes {
+		if columnType.Name() == "key" {
+			hasKeyColumn = true
+			break
+		}
+	}
+	if !hasKeyColumn {
+		return nil
+	}
+	if config.TokenKeySecret == "" {
+		// hashing with an empty secret and dropping the plaintext keys could not be undone
+		logger.SysError("TOKEN_KEY_SECRET is not set, refusing to hash the keys of existing tokens")
+		return errors.New("TOKEN_KEY_SECRET must be set before the plaintext token keys are migrated")
+	}
+	keyCol := "`key`"
+	if common.UsingPostgreSQL {
+		keyCol = `"key"`
+	}
+	var legacyTokens []struct {
+		Id  int
+		Key string
+	}
+	// keys hashed by an interrupted earlier run are hashed again, in case the secret has changed since
+	err = DB.Raw("SELECT id, " + keyCol + " FROM tokens WHERE " + keyCol + " IS NOT NULL AND " + keyCol + " <> ''").Scan(&legacyTokens).Error
+	if err != nil {
+		return err
+	}
+	if len(legacyTokens) > 0 {
+		logger.SysLog(fmt.Sprintf("hashing keys of %d tokens", len(legacyTokens)))
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		for _, legacyToken := range legacyTokens {
+			err := tx.Model(&Token{}).Where("id = ?", legacyToken.Id).Updates(map[string]any{
+				"key_hash":   HashTokenKey(legacyToken.Key),
+				"key_prefix": getTokenKeyPrefix(legacyToken.Key),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err = verifyTokenKeys(keyCol); err != nil {
+		// keep the plaintext column, so that the migration can be retried
+		logger.SysError("keeping the plaintext token keys: " + err.Error())
+		return nil
+	}
+	if DB.Migrator().HasIndex("tokens", "idx_tokens_key") {
+		if err = DB.Migrator().DropIndex("tokens", "idx_tokens_key"); err != nil {
+			return err
+		}
+	}
+	return DB.Exec("ALTER TABLE tokens DROP COLUMN " + keyCol).Error
+}
+
+// verifyTokenKeys checks that every token with a plaintext key has the hash of that key
+func verifyTokenKeys(keyCol string) error {
+	var tokens []struct {
+		Id      int
+		Key     string
+		KeyHash string
+	}
+	err := DB.Raw("SELECT id, " + keyCol + ", key_hash FROM tokens").Scan(&tokens).Error
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if token.Key == "" {
+			continue
+		}
+		if token.KeyHash != HashTokenKey(token.Key) {
+			return fmt.Errorf("the key hash of token #%d does not match its key", token.Id)
+		}
+	}
+	return nil
+}
+
+// migrateUserAccessTokens moves the single access token of each user to the access token table
+// with all scopes, so that existing automation keeps working, and drops the old column
+func migrateUserAccessTokens() error {
+	columnTypes, err := DB.Migrator().ColumnTypes("users")
+	if err != nil {
+		return err
+	}
+	hasColumn := false
+	for _, columnType := range columnTypes {
+		if columnType.Name() == "access_token" {
+			hasColumn = true
+			break
+		}
+	}
+	if !hasColumn {
+		return nil
+	}
+	var legacyTokens []struct {
+		Id          int
+		AccessToken string
+	}
+	err = DB.Raw("SELECT id, access_token FROM users WHERE access_token IS NOT NULL AND access_token <> ''").Scan(&legacyTokens).Error
+	if err != nil {
+		return err
+	}
+	if len(legacyTokens) > 0 {
+		logger.SysLog(fmt.Sprintf("migrating access tokens of %d users", len(legacyTokens)))
+	}
+	scopes := strings.Join(AllScopes(), ",")
+	now := helper.GetTimestamp()
+	for _, legacyToken := range legacyTokens {
+		accessToken := AccessToken{
+			UserId:      legacyToken.Id,
+			Name:        "Legacy Access Token",
+			KeyHash:     HashTokenKey(legacyToken.AccessToken),
+			KeyPrefix:   getTokenKeyPrefix(legacyToken.AccessToken),
+			Scopes:      scopes,
+			ExpiredTime: -1,
+			CreatedTime: now,
+		}
+		if err = DB.Create(&accessToken).Error; err != nil {
+			return err
+		}
+	}
+	if DB.Migrator().HasIndex("users", "idx_users_access_token") {
+		if err = DB.Migrator().DropIndex("users", "idx_users_access_token"); err != nil {
+			return err
+		}
+	}
+	return DB.Exec("ALTER TABLE users DROP COLUMN access_token").Error
+}
+
 func InitLogDB() {
 	if os.Getenv("LOG_SQL_DSN") == "" {
 		LOG_DB = DB
@@ -197,6 +388,12 @@ func migrateLOGDB() error {
 	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
 		return err
 	}
+	if err = LOG_DB.AutoMigrate(&FilterHit{}); err != nil {
+		return err
+	}
+	if err = LOG_DB.AutoMigrate(&RequestCapture{}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -4,6 +4,8 @@ import (
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/logger"
 	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+	"github.com/songquanpeng/one-api/relay/filter"
+	"github.com/songquanpeng/one-api/relay/pii"
 	"strconv"
 	"strings"
 	"time"
@@ -32,6 +34,7 @@ func InitOptionMap() {
 	config.OptionMap["WeChatAuthEnabled"] = strconv.FormatBool(config.WeChatAuthEnabled)
 	config.OptionMap["TurnstileCheckEnabled"] = strconv.FormatBool(config.TurnstileCheckEnabled)
 	config.OptionMap["RegisterEnabled"] = strconv.FormatBool(config.RegisterEnabled)
+	config.OptionMap["AdminTwoFactorEnabled"] = strconv.FormatBool(config.AdminTwoFactorEnabled)
 	config.OptionMap["AutomaticDisableChannelEnabled"] = strconv.FormatBool(config.AutomaticDisableChannelEnabled)
 	config.OptionMap["AutomaticEnableChannelEnabled"] = strconv.FormatBool(config.AutomaticEnableChannelEnabled)
 	config.OptionMap["ApproximateTokenEnabled"] = strconv.FormatBool(config.ApproximateTokenEnabled)
@@ -44,8 +47,31 @@ func InitOptionMap() {
 	
 	// 敏感词过滤配置
 	config.OptionMap["SensitiveFilterEnabled"] = strconv.FormatBool(config.SensitiveFilterEnabled)
-	config.OptionMap["SensitiveWords"] = strings.Join(config.SensitiveWords, ",")
 	config.OptionMap["SensitiveFilterResponse"] = config.SensitiveFilterResponse
+	config.OptionMap["SensitiveFilterCategoryResponses"] = filter.CategoryResponses2JSONString()
+	config.OptionMap["SensitiveFilterPolicies"] = filter.Policies2JSONString()
+	config.OptionMap["SensitiveFilterGroupPolicies"] = filter.GroupPolicies2JSONString()
+	config.OptionMap["SensitiveFilterModeration"] = filter.Moderation2JSONString()
+	config.OptionMap["IpAllowList"] = config.IpAllowList
+	config.OptionMap["IpDenyList"] = config.IpDenyList
+	config.OptionMap["TokenLeakDetectionEnabled"] = strconv.FormatBool(config.TokenLeakDetectionEnabled)
+	config.OptionMap["TokenLeakWindow"] = strconv.Itoa(config.TokenLeakWindow)
+	config.OptionMap["TokenLeakIpThreshold"] = strconv.Itoa(config.TokenLeakIpThreshold)
+	config.OptionMap["TokenLeakUserAgentThreshold"] = strconv.Itoa(config.TokenLeakUserAgentThreshold)
+	config.OptionMap["TokenSpendAnomalyRatio"] = strconv.FormatFloat(config.TokenSpendAnomalyRatio, 'f', -1, 64)
+	config.OptionMap["TokenSpendAnomalyMinQuota"] = strconv.FormatInt(config.TokenSpendAnomalyMinQuota, 10)
+	config.OptionMap["TokenSpendBaselineHours"] = strconv.Itoa(config.TokenSpendBaselineHours)
+	config.OptionMap["TokenLeakAction"] = config.TokenLeakAction
+	config.OptionMap["PiiRedactionRules"] = config.PiiRedactionRules
+	config.OptionMap["PiiRedactionGroupRules"] = pii.GroupRules2JSONString()
+	config.OptionMap["PiiRedactionRestoreEnabled"] = strconv.FormatBool(config.PiiRedactionRestoreEnabled)
+	config.OptionMap["CaptureUserIds"] = config.CaptureUserIds
+	config.OptionMap["CaptureTokenIds"] = config.CaptureTokenIds
+	config.OptionMap["CaptureSampleRate"] = strconv.FormatFloat(config.CaptureSampleRate, 'f', -1, 64)
+	config.OptionMap["CaptureMaxBytes"] = strconv.Itoa(config.CaptureMaxBytes)
+	config.OptionMap["LogLevel"] = config.LogLevel
+	config.OptionMap["LogRetentionDays"] = config.LogRetentionDays
+	config.OptionMap["LogArchiveEnabled"] = strconv.FormatBool(config.LogArchiveEnabled)
 	
 	config.OptionMap["SMTPServer"] = ""
 	config.OptionMap["SMTPFrom"] = ""
@@ -76,6 +102,9 @@ func InitOptionMap() {
 	config.OptionMap["ModelRatio"] = billingratio.ModelRatio2JSONString()
 	config.OptionMap["GroupRatio"] = billingratio.GroupRatio2JSONString()
 	config.OptionMap["CompletionRatio"] = billingratio.CompletionRatio2JSONString()
+	config.OptionMap["AudioCharacterRatio"] = billingratio.AudioCharacterRatio2JSONString()
+	config.OptionMap["AudioSecondRatio"] = billingratio.AudioSecondRatio2JSONString()
+	config.OptionMap["RerankSearchRatio"] = billingratio.RerankSearchRatio2JSONString()
 	config.OptionMap["TopUpLink"] = config.TopUpLink
 	config.OptionMap["ChatLink"] = config.ChatLink
 	config.OptionMap["QuotaPerUnit"] = strconv.FormatFloat(config.QuotaPerUnit, 'f', -1, 64)
@@ -145,6 +174,8 @@ func updateOptionMap(key string, value string) (err error) {
 			config.TurnstileCheckEnabled = boolValue
 		case "RegisterEnabled":
 			config.RegisterEnabled = boolValue
+		case "AdminTwoFactorEnabled":
+			config.AdminTwoFactorEnabled = boolValue
 		case "EmailDomainRestrictionEnabled":
 			config.EmailDomainRestrictionEnabled = boolValue
 		case "AutomaticDisableChannelEnabled":
@@ -161,33 +192,66 @@ func updateOptionMap(key string, value string) (err error) {
 			config.DisplayTokenStatEnabled = boolValue
 		case "SensitiveFilterEnabled":
 			config.SensitiveFilterEnabled = boolValue
+		case "PiiRedactionRestoreEnabled":
+			config.PiiRedactionRestoreEnabled = boolValue
+		case "TokenLeakDetectionEnabled":
+			config.TokenLeakDetectionEnabled = boolValue
+		case "LogArchiveEnabled":
+			config.LogArchiveEnabled = boolValue
 		}
 	}
 	switch key {
 	case "EmailDomainWhitelist":
 		config.EmailDomainWhitelist = strings.Split(value, ",")
-	case "SensitiveWords":
-		// 保存原始格式的敏感词列表到配置项
-		config.OptionMap["SensitiveWords"] = value
-		
-		// 处理敏感词列表，支持每行一个敏感词或逗号分隔
-		var words []string
-		// 首先按逗号分隔
-		commaSplit := strings.Split(value, ",")
-		for _, part := range commaSplit {
-			// 按换行符分隔
-			lines := strings.Split(part, "\n")
-			for _, line := range lines {
-				// 去除空白字符
-				word := strings.TrimSpace(line)
-				if word != "" {
-					words = append(words, word)
-				}
-			}
-		}
-		config.SensitiveWords = words
 	case "SensitiveFilterResponse":
 		config.SensitiveFilterResponse = value
+	case "SensitiveFilterCategoryResponses":
+		err = filter.UpdateCategoryResponsesByJSONString(value)
+	case "SensitiveFilterPolicies":
+		err = filter.UpdatePoliciesByJSONString(value)
+	case "SensitiveFilterGroupPolicies":
+		err = filter.UpdateGroupPoliciesByJSONString(value)
+	case "SensitiveFilterModeration":
+		err = filter.UpdateModerationByJSONString(value)
+	case filterRulesVersionKey:
+		// the rules are read from the database, not under the lock of the options
+		go SyncFilterRules(value)
+	case "IpAllowList":
+		config.IpAllowList = value
+	case "IpDenyList":
+		config.IpDenyList = value
+	case "TokenLeakWindow":
+		config.TokenLeakWindow, _ = strconv.Atoi(value)
+	case "TokenLeakIpThreshold":
+		config.TokenLeakIpThreshold, _ = strconv.Atoi(value)
+	case "TokenLeakUserAgentThreshold":
+		config.TokenLeakUserAgentThreshold, _ = strconv.Atoi(value)
+	case "TokenSpendAnomalyRatio":
+		config.TokenSpendAnomalyRatio, _ = strconv.ParseFloat(value, 64)
+	case "TokenSpendAnomalyMinQuota":
+		config.TokenSpendAnomalyMinQuota, _ = strconv.ParseInt(value, 10, 64)
+	case "TokenSpendBaselineHours":
+		config.TokenSpendBaselineHours, _ = strconv.Atoi(value)
+	case "TokenLeakAction":
+		config.TokenLeakAction = value
+	case "PiiRedactionRules":
+		config.PiiRedactionRules = value
+	case "PiiRedactionGroupRules":
+		err = pii.UpdateGroupRulesByJSONString(value)
+	case "CaptureUserIds":
+		config.CaptureUserIds = value
+	case "CaptureTokenIds":
+		config.CaptureTokenIds = value
+	case "CaptureSampleRate":
+		config.CaptureSampleRate, _ = strconv.ParseFloat(value, 64)
+	case "CaptureMaxBytes":
+		config.CaptureMaxBytes, _ = strconv.Atoi(value)
+	case "LogRetentionDays":
+		config.LogRetentionDays = value
+	case "LogLevel":
+		if err = logger.SetLevels(value); err == nil {
+			config.LogLevel = value
+		}
 	case "SMTPServer":
 		config.SMTPServer = value
 	case "SMTPPort":
@@ -259,6 +323,12 @@ func updateOptionMap(key string, value string) (err error) {
 		err = billingratio.UpdateGroupRatioByJSONString(value)
 	case "CompletionRatio":
 		err = billingratio.UpdateCompletionRatioByJSONString(value)
+	case "AudioCharacterRatio":
+		err = billingratio.UpdateAudioCharacterRatioByJSONString(value)
+	case "AudioSecondRatio":
+		err = billingratio.UpdateAudioSecondRatioByJSONString(value)
+	case "RerankSearchRatio":
+		err = billingratio.UpdateRerankSearchRatioByJSONString(value)
 	case "TopUpLink":
 		config.TopUpLink = value
 	case "ChatLink":
@@ -0,0 +1,257 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+const (
+	PermissionChannelRead      = "channel:read"
+	PermissionChannelWrite     = "channel:write"
+	PermissionChannelTest      = "channel:test"
+	PermissionChannelSecret    = "channel:secret" // reveal and rotate channel keys
+	PermissionUserRead         = "user:read"
+	PermissionUserWrite        = "user:write"
+	PermissionUserQuota        = "user:quota"
+	PermissionRedemptionRead   = "redemption:read"
+	PermissionRedemptionCreate = "redemption:create"
+	PermissionRedemptionWrite  = "redemption:write"
+	PermissionLogReadAll       = "log:read:all"
+	PermissionLogDelete        = "log:delete"
+	PermissionGroupRead        = "group:read"
+	PermissionFilterRead       = "filter:read"
+	PermissionFilterWrite      = "filter:write"
+	PermissionOptionRead       = "option:read"
+	PermissionOptionWrite      = "option:write"
+	PermissionAuditRead        = "audit:read"
+	PermissionRoleWrite        = "role:write" // manage roles and assign them, as powerful as root
+	PermissionBanRead          = "ban:read"
+	PermissionBanWrite         = "ban:write"
+	PermissionCaptureRead      = "capture:read" // captured request and response bodies
+)
+
+var AllPermissions = []string{
+	PermissionChannelRead,
+	PermissionChannelWrite,
+	PermissionChannelTest,
+	PermissionChannelSecret,
+	PermissionUserRead,
+	PermissionUserWrite,
+	PermissionUserQuota,
+	PermissionRedemptionRead,
+	PermissionRedemptionCreate,
+	PermissionRedemptionWrite,
+	PermissionLogReadAll,
+	PermissionLogDelete,
+	PermissionGroupRead,
+	PermissionFilterRead,
+	PermissionFilterWrite,
+	PermissionOptionRead,
+	PermissionOptionWrite,
+	PermissionAuditRead,
+	PermissionRoleWrite,
+	PermissionBanRead,
+	PermissionBanWrite,
+	PermissionCaptureRead,
+}
+
+// permissions of the built-in roles, they keep what AdminAuth and RootAuth used to allow
+var adminPermissions = []string{
+	PermissionChannelRead,
+	PermissionChannelWrite,
+	PermissionChannelTest,
+	PermissionUserRead,
+	PermissionUserWrite,
+	PermissionUserQuota,
+	PermissionRedemptionRead,
+	PermissionRedemptionCreate,
+	PermissionRedemptionWrite,
+	PermissionLogReadAll,
+	PermissionLogDelete,
+	PermissionGroupRead,
+	PermissionFilterRead,
+	PermissionFilterWrite,
+	PermissionBanRead,
+	PermissionBanWrite,
+}
+
+// Role is a named set of permissions, a user with a role gets its permissions
+// in addition to the permissions of the built-in role
+type Role struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(32);uniqueIndex"`
+	Description string `json:"description" gorm:"type:varchar(255);default:''"`
+	Permissions string `json:"permissions" gorm:"type:text"` // comma separated
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func IsValidPermission(permission string) bool {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func builtinPermissions(role int) []string {
+	switch {
+	case role >= RoleRootUser:
+		return AllPermissions
+	case role >= RoleAdminUser:
+		return adminPermissions
+	}
+	return nil
+}
+
+func (role *Role) PermissionList() []string {
+	if role.Permissions == "" {
+		return nil
+	}
+	return strings.Split(role.Permissions, ",")
+}
+
+// Validate checks the name and permissions and normalizes the permission list
+func (role *Role) Validate() error {
+	role.Name = strings.TrimSpace(role.Name)
+	if role.Name == "" || len(role.Name) > 32 {
+		return errors.New("角色名称长度必须在1-32之间")
+	}
+	var permissions []string
+	seen := map[string]bool{}
+	for _, permission := range strings.Split(role.Permissions, ",") {
+		permission = strings.TrimSpace(permission)
+		if permission == "" || seen[permission] {
+			continue
+		}
+		if !IsValidPermission(permission) {
+			return fmt.Errorf("未知的权限：%s", permission)
+		}
+		seen[permission] = true
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+	role.Permissions = strings.Join(permissions, ",")
+	return nil
+}
+
+func GetAllRoles() (roles []*Role, err error) {
+	err = DB.Order("id asc").Find(&roles).Error
+	return roles, err
+}
+
+func GetRoleById(id int) (*Role, error) {
+	role := Role{}
+	err := DB.First(&role, "id = ?", id).Error
+	return &role, err
+}
+
+func (role *Role) Insert() error {
+	if err := role.Validate(); err != nil {
+		return err
+	}
+	role.CreatedTime = helper.GetTimestamp()
+	return DB.Create(role).Error
+}
+
+func (role *Role) Update() error {
+	if err := role.Validate(); err != nil {
+		return err
+	}
+	return DB.Model(role).Select("name", "description", "permissions").Updates(role).Error
+}
+
+// DeleteRoleById removes the role from its users as well
+func DeleteRoleById(id int) error {
+	if err := DB.Model(&User{}).Where("role_id = ?", id).Update("role_id", 0).Error; err != nil {
+		return err
+	}
+	return DB.Delete(&Role{}, "id = ?", id).Error
+}
+
+// SetUserRoleId assigns a custom role to the user, 0 removes it
+func SetUserRoleId(userId int, roleId int) error {
+	if roleId != 0 {
+		if _, err := GetRoleById(roleId); err != nil {
+			return errors.New("角色不存在")
+		}
+	}
+	return DB.Model(&User{}).Where("id = ?", userId).Update("role_id", roleId).Error
+}
+
+// GetUserPermissions returns the permissions of the built-in role and the custom role of the user
+func GetUserPermissions(userId int) ([]string, error) {
+	user := User{}
+	if err := DB.Select("id", "role", "role_id").First(&user, "id = ?", userId).Error; err != nil {
+		return nil, err
+	}
+	return user.GetPermissions(), nil
+}
+
+func (user *User) GetPermissions() []string {
+	permissions := builtinPermissions(user.Role)
+	if user.RoleId == 0 {
+		return permissions
+	}
+	role, err := GetRoleById(user.RoleId)
+	if err != nil {
+		// the role may have been deleted, fall back to the built-in permissions
+		return permissions
+	}
+	seen := map[string]bool{}
+	var result []string
+	for _, permission := range append(append([]string{}, permissions...), role.PermissionList()...) {
+		if !seen[permission] {
+			seen[permission] = true
+			result = append(result, permission)
+		}
+	}
+	return result
+}
+
+func UserHasPermission(userId int, permission string) bool {
+	permissions, err := GetUserPermissions(userId)
+	if err != nil {
+		return false
+	}
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CanManageUser reports whether the actor may read or manage the target. Root manages everyone, other users
+// manage users of a lower built-in role, or common users if they are common users themselves (with user
+// permissions through a custom role), and only if every permission of the target is held by the actor as well,
+// so that managing a user, e.g. resetting the password, never grants more permissions
+func CanManageUser(actor *User, target *User) bool {
+	if actor.Role == RoleRootUser {
+		return true
+	}
+	return canManageUser(actor, target, actor.GetPermissions(), target.GetPermissions())
+}
+
+func canManageUser(actor *User, target *User, actorPermissions []string, targetPermissions []string) bool {
+	if actor.Role == RoleRootUser {
+		return true
+	}
+	if actor.Id == target.Id || actor.Role < target.Role || (actor.Role == target.Role && actor.Role != RoleCommonUser) {
+		return false
+	}
+	held := make(map[string]bool, len(actorPermissions))
+	for _, permission := range actorPermissions {
+		held[permission] = true
+	}
+	for _, permission := range targetPermissions {
+		if !held[permission] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCanManageUser(t *testing.T) {
+	Convey("canManageUser", t, func() {
+		root := &User{Id: 1, Role: RoleRootUser}
+		admin := &User{Id: 2, Role: RoleAdminUser}
+		manager := &User{Id: 3, Role: RoleCommonUser, RoleId: 1}
+		managerPermissions := []string{PermissionUserRead, PermissionUserWrite}
+		common := &User{Id: 4, Role: RoleCommonUser}
+		roleAdmin := &User{Id: 5, Role: RoleCommonUser, RoleId: 2}
+		roleAdminPermissions := []string{PermissionRoleWrite}
+
+		Convey("root manages everyone", func() {
+			So(canManageUser(root, roleAdmin, AllPermissions, roleAdminPermissions), ShouldBeTrue)
+			So(canManageUser(root, admin, AllPermissions, adminPermissions), ShouldBeTrue)
+		})
+
+		Convey("admins do not manage users with permissions they lack", func() {
+			So(canManageUser(admin, common, adminPermissions, nil), ShouldBeTrue)
+			So(canManageUser(admin, roleAdmin, adminPermissions, roleAdminPermissions), ShouldBeFalse)
+			So(canManageUser(admin, &User{Id: 6, Role: RoleAdminUser}, adminPermissions, adminPermissions), ShouldBeFalse)
+			So(canManageUser(admin, root, adminPermissions, AllPermissions), ShouldBeFalse)
+		})
+
+		Convey("custom role holders manage common users with fewer permissions", func() {
+			So(canManageUser(manager, common, managerPermissions, nil), ShouldBeTrue)
+			So(canManageUser(manager, roleAdmin, managerPermissions, roleAdminPermissions), ShouldBeFalse)
+			So(canManageUser(manager, admin, managerPermissions, adminPermissions), ShouldBeFalse)
+			So(canManageUser(manager, manager, managerPermissions, managerPermissions), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,218 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// Session is a login session, the cookie only carries its token so that it can be revoked
+type Session struct {
+	Id           int    `json:"id"`
+	TokenHash    string `json:"-" gorm:"type:char(64);uniqueIndex"`
+	UserId       int    `json:"user_id" gorm:"index"` // 0 before login, e.g. pending oauth or 2fa
+	Data         []byte `json:"-"`                    // gob encoded session values
+	Ip           string `json:"ip" gorm:"type:varchar(64);default:''"`
+	UserAgent    string `json:"user_agent" gorm:"type:varchar(255);default:''"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	LastSeenTime int64  `json:"last_seen_time" gorm:"bigint"`
+	ExpiredTime  int64  `json:"expired_time" gorm:"bigint;index"`
+	Current      bool   `json:"current" gorm:"-:all"`
+}
+
+const (
+	SessionCacheSeconds = 60
+	// sessionSeenInterval limits how often the last seen time is written
+	sessionSeenInterval = 60
+)
+
+// cachedSession keeps the fields that are hidden from json in the cache
+type cachedSession struct {
+	Session
+	TokenHash string
+	Data      []byte
+}
+
+func sessionCacheKey(tokenHash string) string {
+	return fmt.Sprintf("session:%s", tokenHash)
+}
+
+func newSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetSessionByToken returns the unexpired session of the token
+func GetSessionByToken(token string) (*Session, error) {
+	tokenHash := HashTokenKey(token)
+	now := helper.GetTimestamp()
+	if common.RedisEnabled {
+		cached := cachedSession{}
+		if data, err := common.RedisGet(sessionCacheKey(tokenHash)); err == nil && json.Unmarshal([]byte(data), &cached) == nil {
+			if cached.ExpiredTime <= now {
+				return nil, errors.New("session expired")
+			}
+			session := cached.Session
+			session.TokenHash, session.Data = cached.TokenHash, cached.Data
+			return &session, nil
+		}
+	}
+	session := Session{}
+	// Find instead of First, revoked tokens are expected and should not be logged as errors
+	result := DB.Where("token_hash = ? AND expired_time > ?", tokenHash, now).Limit(1).Find(&session)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("session not found")
+	}
+	if common.RedisEnabled {
+		data, _ := json.Marshal(cachedSession{session, session.TokenHash, session.Data})
+		if err := common.RedisSet(sessionCacheKey(tokenHash), string(data), SessionCacheSeconds*time.Second); err != nil {
+			logger.SysError("Redis set session error: " + err.Error())
+		}
+	}
+	return &session, nil
+}
+
+// SaveSession stores the session values of token and returns the token to put in the cookie,
+// a new token is issued when the session changes its user so that a login never reuses a token
+func SaveSession(token string, userId int, data []byte, ip string, userAgent string, maxAge int) (string, error) {
+	now := helper.GetTimestamp()
+	if token != "" {
+		session, err := GetSessionByToken(token)
+		if err == nil && session.UserId == userId {
+			err = DB.Model(&Session{}).Where("id = ?", session.Id).Updates(map[string]any{
+				"data":           data,
+				"last_seen_time": now,
+				"expired_time":   now + int64(maxAge),
+			}).Error
+			invalidateSessionCache(session.TokenHash)
+			return token, err
+		}
+		if err == nil {
+			DeleteSessionByToken(token)
+		}
+	}
+	token, err := newSessionToken()
+	if err != nil {
+		return "", err
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	session := Session{
+		TokenHash:    HashTokenKey(token),
+		UserId:       userId,
+		Data:         data,
+		Ip:           ip,
+		UserAgent:    userAgent,
+		CreatedTime:  now,
+		LastSeenTime: now,
+		ExpiredTime:  now + int64(maxAge),
+	}
+	return token, DB.Create(&session).Error
+}
+
+// TouchSession updates the last seen time and ip of the session, at most once a minute
+func TouchSession(session *Session, ip string) {
+	now := helper.GetTimestamp()
+	if now-session.LastSeenTime < sessionSeenInterval && session.Ip == ip {
+		return
+	}
+	session.LastSeenTime = now
+	session.Ip = ip
+	DB.Model(&Session{}).Where("id = ?", session.Id).Updates(map[string]any{
+		"last_seen_time": now,
+		"ip":             ip,
+	})
+	invalidateSessionCache(session.TokenHash)
+}
+
+func invalidateSessionCache(tokenHashes ...string) {
+	if !common.RedisEnabled {
+		return
+	}
+	for _, tokenHash := range tokenHashes {
+		if err := common.RedisDel(sessionCacheKey(tokenHash)); err != nil {
+			logger.SysError("Redis delete session error: " + err.Error())
+		}
+	}
+}
+
+func DeleteSessionByToken(token string) {
+	tokenHash := HashTokenKey(token)
+	DB.Where("token_hash = ?", tokenHash).Delete(&Session{})
+	invalidateSessionCache(tokenHash)
+}
+
+// GetUserSessions lists the unexpired sessions of the user, current marks the session of token
+func GetUserSessions(userId int, token string) (sessions []*Session, err error) {
+	err = DB.Where("user_id = ? AND expired_time > ?", userId, helper.GetTimestamp()).
+		Order("last_seen_time desc").Find(&sessions).Error
+	currentHash := HashTokenKey(token)
+	for _, session := range sessions {
+		session.Current = token != "" && session.TokenHash == currentHash
+	}
+	return sessions, err
+}
+
+func DeleteSessionById(id int, userId int) error {
+	session := Session{}
+	if err := DB.First(&session, "id = ? AND user_id = ?", id, userId).Error; err != nil {
+		return err
+	}
+	if err := DB.Delete(&session).Error; err != nil {
+		return err
+	}
+	invalidateSessionCache(session.TokenHash)
+	return nil
+}
+
+// RevokeUserSessions logs the user out everywhere, except the session of exceptToken if it is not empty
+func RevokeUserSessions(userId int, exceptToken string) error {
+	tx := DB.Model(&Session{}).Where("user_id = ?", userId)
+	if exceptToken != "" {
+		tx = tx.Where("token_hash <> ?", HashTokenKey(exceptToken))
+	}
+	var tokenHashes []string
+	if err := tx.Pluck("token_hash", &tokenHashes).Error; err != nil {
+		return err
+	}
+	if len(tokenHashes) == 0 {
+		return nil
+	}
+	if err := DB.Where("token_hash IN ?", tokenHashes).Delete(&Session{}).Error; err != nil {
+		return err
+	}
+	invalidateSessionCache(tokenHashes...)
+	return nil
+}
+
+func DeleteExpiredSessions() (int64, error) {
+	result := DB.Where("expired_time <= ?", helper.GetTimestamp()).Delete(&Session{})
+	return result.RowsAffected, result.Error
+}
+
+// CleanSessions deletes expired sessions every hour
+func CleanSessions() {
+	for {
+		count, err := DeleteExpiredSessions()
+		if err != nil {
+			logger.SysError("failed to delete expired sessions: " + err.Error())
+		} else if count > 0 {
+			logger.SysLog(fmt.Sprintf("deleted %d expired sessions", count))
+		}
+		time.Sleep(time.Hour)
+	}
+}
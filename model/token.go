@@ -1,8 +1,12 @@
 package model
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
 
 	"gorm.io/gorm"
 
@@ -23,7 +27,9 @@ const (
 type Token struct {
 	Id             int     `json:"id"`
 	UserId         int     `json:"user_id"`
-	Key            string  `json:"key" gorm:"type:char(48);uniqueIndex"`
+	Key            string  `json:"key" gorm:"-"`                       // plaintext key, only available right after creation
+	KeyHash        string  `json:"-" gorm:"type:char(64);uniqueIndex"` // keyed hash of the key, used for lookups
+	KeyPrefix      string  `json:"key_prefix" gorm:"type:varchar(16)"` // first characters of the key, for display
 	Status         int     `json:"status" gorm:"default:1"`
 	Name           string  `json:"name" gorm:"index" `
 	CreatedTime    int64   `json:"created_time" gorm:"bigint"`
@@ -34,6 +40,32 @@ type Token struct {
 	UsedQuota      int64   `json:"used_quota" gorm:"bigint;default:0"` // used quota
 	Models         *string `json:"models" gorm:"type:text"`            // allowed models
 	Subnet         *string `json:"subnet" gorm:"default:''"`           // allowed subnet
+	PiiRules       *string `json:"pii_rules" gorm:"default:''"`        // pii redaction rules, empty follows the group
+	FilterPolicy   *string `json:"filter_policy" gorm:"default:''"`    // content filter policy, empty follows the group
+}
+
+const tokenKeyPrefixLength = 8
+
+// ErrInvalidToken is returned for keys that do not belong to any token, e.g. guessed keys
+var ErrInvalidToken = errors.New("无效的令牌")
+
+// HashTokenKey returns the keyed hash stored in place of the plaintext key
+func HashTokenKey(key string) string {
+	mac := hmac.New(sha256.New, []byte(config.TokenKeySecret))
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func getTokenKeyPrefix(key string) string {
+	if len(key) > tokenKeyPrefixLength {
+		return key[:tokenKeyPrefixLength]
+	}
+	return key
+}
+
+// MaskKey replaces the key with its display prefix, the full key can not be recovered once stored
+func (t *Token) MaskKey() {
+	t.Key = t.KeyPrefix + strings.Repeat("*", 16)
 }
 
 func GetAllUserTokens(userId int, startIdx int, num int, order string) ([]*Token, error) {
@@ -67,7 +99,7 @@ func ValidateUserToken(key string) (token *Token, err error) {
 	if err != nil {
 		logger.SysError("CacheGetTokenByKey failed: " + err.Error())
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("无效的令牌")
+			return nil, ErrInvalidToken
 		}
 		return nil, errors.New("令牌验证失败")
 	}
@@ -125,6 +157,10 @@ func GetTokenById(id int) (*Token, error) {
 
 func (t *Token) Insert() error {
 	var err error
+	if t.Key != "" {
+		t.KeyHash = HashTokenKey(t.Key)
+		t.KeyPrefix = getTokenKeyPrefix(t.Key)
+	}
 	err = DB.Create(t).Error
 	return err
 }
@@ -132,7 +168,7 @@ func (t *Token) Insert() error {
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (t *Token) Update() error {
 	var err error
-	err = DB.Model(t).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "models", "subnet").Updates(t).Error
+	err = DB.Model(t).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "models", "subnet", "pii_rules", "filter_policy").Updates(t).Error
 	return err
 }
 
@@ -276,6 +312,9 @@ func PreConsumeTokenQuota(tokenId int, quota int64) (err error) {
 		}
 	}
 	err = DecreaseUserQuota(token.UserId, quota)
+	if err == nil {
+		recordTokenSpend(tokenId, quota)
+	}
 	return err
 }
 
@@ -299,5 +338,6 @@ func PostConsumeTokenQuota(tokenId int, quota int64) (err error) {
 			return err
 		}
 	}
+	recordTokenSpend(tokenId, quota)
 	return nil
 }
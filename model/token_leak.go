@@ -0,0 +1,323 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/blacklist"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/message"
+)
+
+const (
+	TokenLeakActionNotify  = "notify"  // only notify the owner
+	TokenLeakActionBan     = "ban"     // ban the token for AutoBanDuration seconds
+	TokenLeakActionDisable = "disable" // disable the token until the owner enables it again
+)
+
+func IsValidTokenLeakAction(action string) bool {
+	return action == TokenLeakActionNotify || action == TokenLeakActionBan || action == TokenLeakActionDisable
+}
+
+// a leaked token is handled once, the detection is paused until it is enabled again or the pause expires
+const tokenLeakPause = time.Hour
+
+const maxTrackedUserAgentLength = 256
+
+// tokenLeakState is the in-memory state used when Redis is not enabled,
+// idle tokens are swept at most once per window
+var tokenLeakState = struct {
+	sync.Mutex
+	ips        map[int]map[string]int64 // token id -> ip -> last seen
+	userAgents map[int]map[string]int64 // token id -> user agent -> last seen
+	spends     map[int]map[int64]int64  // token id -> hour -> quota
+	paused     map[int]int64            // token id -> pause expiry
+	lastSweep  int64
+}{
+	ips:        make(map[int]map[string]int64),
+	userAgents: make(map[int]map[string]int64),
+	spends:     make(map[int]map[int64]int64),
+	paused:     make(map[int]int64),
+}
+
+func tokenLeakIpsKey(tokenId int) string {
+	return fmt.Sprintf("token_leak_ips:%d", tokenId)
+}
+
+func tokenLeakUserAgentsKey(tokenId int) string {
+	return fmt.Sprintf("token_leak_uas:%d", tokenId)
+}
+
+func tokenLeakPausedKey(tokenId int) string {
+	return fmt.Sprintf("token_leak_paused:%d", tokenId)
+}
+
+func tokenSpendKey(tokenId int, hour int64) string {
+	return fmt.Sprintf("token_spend:%d:%d", tokenId, hour)
+}
+
+// RecordTokenAccess tracks the distinct ips and user agents a token is used from within the sliding window,
+// it may block on Redis and should be called off the request path
+func RecordTokenAccess(tokenId int, ip string, userAgent string) {
+	if !config.TokenLeakDetectionEnabled || config.TokenLeakWindow <= 0 || isTokenLeakPaused(tokenId) {
+		return
+	}
+	if len(userAgent) > maxTrackedUserAgentLength {
+		userAgent = userAgent[:maxTrackedUserAgentLength]
+	}
+	now := time.Now().Unix()
+	var ipCount, userAgentCount int
+	if common.RedisEnabled {
+		ctx := context.Background()
+		window := time.Duration(config.TokenLeakWindow) * time.Second
+		pipe := common.RDB.TxPipeline()
+		ipCard := trackDistinct(ctx, pipe, tokenLeakIpsKey(tokenId), ip, now, window)
+		userAgentCard := trackDistinct(ctx, pipe, tokenLeakUserAgentsKey(tokenId), userAgent, now, window)
+		if _, err := pipe.Exec(ctx); err != nil {
+			logger.SysError("Redis track token access error: " + err.Error())
+			return
+		}
+		ipCount, userAgentCount = int(ipCard.Val()), int(userAgentCard.Val())
+	} else {
+		tokenLeakState.Lock()
+		if now-tokenLeakState.lastSweep > int64(config.TokenLeakWindow) {
+			sweepTokenLeakState(now)
+		}
+		ipCount = trackDistinctLocally(tokenLeakState.ips, tokenId, ip, now)
+		userAgentCount = trackDistinctLocally(tokenLeakState.userAgents, tokenId, userAgent, now)
+		tokenLeakState.Unlock()
+	}
+	if config.TokenLeakIpThreshold > 0 && ipCount > config.TokenLeakIpThreshold {
+		handleTokenLeak(tokenId, fmt.Sprintf("%d 秒内来自 %d 个不同的 IP", config.TokenLeakWindow, ipCount))
+	} else if config.TokenLeakUserAgentThreshold > 0 && userAgentCount > config.TokenLeakUserAgentThreshold {
+		handleTokenLeak(tokenId, fmt.Sprintf("%d 秒内来自 %d 个不同的客户端", config.TokenLeakWindow, userAgentCount))
+	}
+}
+
+func trackDistinct(ctx context.Context, pipe redis.Pipeliner, key string, member string, now int64, window time.Duration) *redis.IntCmd {
+	pipe.ZAdd(ctx, key, &redis.Z{Score: float64(now), Member: member})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now-int64(window.Seconds()), 10))
+	card := pipe.ZCard(ctx, key)
+	pipe.Expire(ctx, key, window)
+	return card
+}
+
+func trackDistinctLocally(values map[int]map[string]int64, tokenId int, value string, now int64) int {
+	seen, ok := values[tokenId]
+	if !ok {
+		seen = make(map[string]int64)
+		values[tokenId] = seen
+	}
+	seen[value] = now
+	for v, lastSeen := range seen {
+		if lastSeen <= now-int64(config.TokenLeakWindow) {
+			delete(seen, v)
+		}
+	}
+	return len(seen)
+}
+
+// sweepTokenLeakState deletes the tokens not seen within the window, the expired pauses
+// and the spends older than the baseline, the caller must hold the lock
+func sweepTokenLeakState(now int64) {
+	for _, values := range []map[int]map[string]int64{tokenLeakState.ips, tokenLeakState.userAgents} {
+		for tokenId, seen := range values {
+			for v, lastSeen := range seen {
+				if lastSeen <= now-int64(config.TokenLeakWindow) {
+					delete(seen, v)
+				}
+			}
+			if len(seen) == 0 {
+				delete(values, tokenId)
+			}
+		}
+	}
+	baselineHours := int64(config.TokenSpendBaselineHours)
+	if baselineHours <= 0 {
+		baselineHours = 1
+	}
+	oldestHour := now/3600 - baselineHours
+	for tokenId, spends := range tokenLeakState.spends {
+		for h := range spends {
+			if h < oldestHour {
+				delete(spends, h)
+			}
+		}
+		if len(spends) == 0 {
+			delete(tokenLeakState.spends, tokenId)
+		}
+	}
+	for tokenId, expiry := range tokenLeakState.paused {
+		if expiry <= now {
+			delete(tokenLeakState.paused, tokenId)
+		}
+	}
+	tokenLeakState.lastSweep = now
+}
+
+// recordTokenSpend adds the consumed quota to the hourly spend of the token, and compares the spend of
+// the current hour with the hourly average of the trailing baseline
+func recordTokenSpend(tokenId int, quota int64) {
+	if !config.TokenLeakDetectionEnabled || quota == 0 {
+		return
+	}
+	hour := time.Now().Unix() / 3600
+	baselineHours := int64(config.TokenSpendBaselineHours)
+	if baselineHours <= 0 {
+		baselineHours = 1
+	}
+	var current, baseline int64
+	if common.RedisEnabled {
+		ctx := context.Background()
+		key := tokenSpendKey(tokenId, hour)
+		var err error
+		current, err = common.RDB.IncrBy(ctx, key, quota).Result()
+		if err != nil {
+			logger.SysError("Redis record token spend error: " + err.Error())
+			return
+		}
+		common.RDB.Expire(ctx, key, time.Duration(baselineHours+1)*time.Hour)
+		if quota < 0 || !shouldCheckTokenSpend(current) || isTokenLeakPaused(tokenId) {
+			return
+		}
+		keys := make([]string, 0, baselineHours)
+		for h := hour - baselineHours; h < hour; h++ {
+			keys = append(keys, tokenSpendKey(tokenId, h))
+		}
+		values, err := common.RDB.MGet(ctx, keys...).Result()
+		if err != nil {
+			logger.SysError("Redis get token spend error: " + err.Error())
+			return
+		}
+		for _, value := range values {
+			if s, ok := value.(string); ok {
+				n, _ := strconv.ParseInt(s, 10, 64)
+				baseline += n
+			}
+		}
+	} else {
+		tokenLeakState.Lock()
+		spends, ok := tokenLeakState.spends[tokenId]
+		if !ok {
+			spends = make(map[int64]int64)
+			tokenLeakState.spends[tokenId] = spends
+		}
+		spends[hour] += quota
+		current = spends[hour]
+		for h, n := range spends {
+			if h < hour-baselineHours {
+				delete(spends, h)
+			} else if h < hour {
+				baseline += n
+			}
+		}
+		tokenLeakState.Unlock()
+		if quota < 0 || !shouldCheckTokenSpend(current) || isTokenLeakPaused(tokenId) {
+			return
+		}
+	}
+	average := float64(baseline) / float64(baselineHours)
+	if float64(current) <= average*config.TokenSpendAnomalyRatio {
+		return
+	}
+	if baseline == 0 {
+		handleTokenLeak(tokenId, fmt.Sprintf("本小时消耗额度 %d，过去 %d 小时内没有消耗", current, baselineHours))
+		return
+	}
+	handleTokenLeak(tokenId, fmt.Sprintf("本小时消耗额度 %d，超过过去 %d 小时平均值 %.0f 的 %g 倍",
+		current, baselineHours, average, config.TokenSpendAnomalyRatio))
+}
+
+func shouldCheckTokenSpend(current int64) bool {
+	return config.TokenSpendAnomalyRatio > 0 && current >= config.TokenSpendAnomalyMinQuota
+}
+
+func isTokenLeakPaused(tokenId int) bool {
+	if common.RedisEnabled {
+		n, err := common.RDB.Exists(context.Background(), tokenLeakPausedKey(tokenId)).Result()
+		return err == nil && n > 0
+	}
+	tokenLeakState.Lock()
+	defer tokenLeakState.Unlock()
+	return tokenLeakState.paused[tokenId] > time.Now().Unix()
+}
+
+// pauseTokenLeakDetection reports whether the token was not paused, so that a leak is handled only once across nodes
+func pauseTokenLeakDetection(tokenId int) bool {
+	if common.RedisEnabled {
+		ok, err := common.RDB.SetNX(context.Background(), tokenLeakPausedKey(tokenId), 1, tokenLeakPause).Result()
+		return err == nil && ok
+	}
+	tokenLeakState.Lock()
+	defer tokenLeakState.Unlock()
+	now := time.Now().Unix()
+	if tokenLeakState.paused[tokenId] > now {
+		return false
+	}
+	tokenLeakState.paused[tokenId] = now + int64(tokenLeakPause.Seconds())
+	return true
+}
+
+// ResetTokenLeakDetection clears the tracked usage of a token, e.g. when its owner enables it again
+func ResetTokenLeakDetection(tokenId int) {
+	if common.RedisEnabled {
+		hour := time.Now().Unix() / 3600
+		err := common.RDB.Del(context.Background(), tokenLeakIpsKey(tokenId), tokenLeakUserAgentsKey(tokenId),
+			tokenLeakPausedKey(tokenId), tokenSpendKey(tokenId, hour)).Err()
+		if err != nil {
+			logger.SysError("Redis reset token leak detection error: " + err.Error())
+		}
+		return
+	}
+	tokenLeakState.Lock()
+	delete(tokenLeakState.ips, tokenId)
+	delete(tokenLeakState.userAgents, tokenId)
+	delete(tokenLeakState.spends, tokenId)
+	delete(tokenLeakState.paused, tokenId)
+	tokenLeakState.Unlock()
+}
+
+func handleTokenLeak(tokenId int, reason string) {
+	if !pauseTokenLeakDetection(tokenId) {
+		return
+	}
+	token, err := GetTokenById(tokenId)
+	if err != nil {
+		logger.SysError("failed to get leaked token: " + err.Error())
+		return
+	}
+	logger.SysLogf("token #%d of user #%d may be leaked: %s", token.Id, token.UserId, reason)
+	action := "请确认是否为本人使用"
+	switch config.TokenLeakAction {
+	case TokenLeakActionBan:
+		blacklist.AutoBan(blacklist.BanTypeToken, strconv.Itoa(token.Id), "疑似泄露："+reason)
+		action = "已被临时封禁"
+	case TokenLeakActionDisable:
+		if err := DB.Model(token).Update("status", TokenStatusDisabled).Error; err != nil {
+			logger.SysError("failed to disable leaked token: " + err.Error())
+			return
+		}
+		CacheDeleteToken(token.KeyHash)
+		action = "已被禁用，确认安全后请在令牌页面重新启用，建议删除该令牌并创建新的令牌"
+	}
+	content := fmt.Sprintf("令牌 %s（#%d）疑似泄露：%s，%s", token.Name, token.Id, reason, action)
+	RecordLog(context.Background(), token.UserId, LogTypeSystem, content)
+	go func() {
+		email, err := GetUserEmail(token.UserId)
+		if err != nil {
+			logger.SysError("failed to fetch user email: " + err.Error())
+			return
+		}
+		err = message.NotifyUser(email, "令牌泄露提醒", fmt.Sprintf("<p>您好！</p><p>%s。</p>", content))
+		if err != nil {
+			logger.SysError("failed to send token leak notice: " + err.Error())
+		}
+	}()
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+func TestRecordTokenAccess(t *testing.T) {
+	Convey("RecordTokenAccess without Redis", t, func() {
+		redisEnabled, enabled, window := common.RedisEnabled, config.TokenLeakDetectionEnabled, config.TokenLeakWindow
+		ipThreshold, userAgentThreshold := config.TokenLeakIpThreshold, config.TokenLeakUserAgentThreshold
+		common.RedisEnabled, config.TokenLeakDetectionEnabled, config.TokenLeakWindow = false, true, 60
+		config.TokenLeakIpThreshold, config.TokenLeakUserAgentThreshold = 0, 0
+		Reset(func() {
+			for _, tokenId := range []int{1, 2, 3} {
+				ResetTokenLeakDetection(tokenId)
+			}
+			tokenLeakState.lastSweep = 0
+			common.RedisEnabled, config.TokenLeakDetectionEnabled, config.TokenLeakWindow = redisEnabled, enabled, window
+			config.TokenLeakIpThreshold, config.TokenLeakUserAgentThreshold = ipThreshold, userAgentThreshold
+		})
+
+		RecordTokenAccess(1, "10.0.0.1", "curl")
+		RecordTokenAccess(1, "10.0.0.2", "curl")
+		RecordTokenAccess(2, "10.0.0.1", "python")
+		So(tokenLeakState.ips[1], ShouldHaveLength, 2)
+		So(tokenLeakState.userAgents[1], ShouldHaveLength, 1)
+
+		Convey("evicts the tokens not seen within the window", func() {
+			now := time.Now().Unix()
+			tokenLeakState.Lock()
+			tokenLeakState.ips[1]["10.0.0.1"] = now - 120
+			tokenLeakState.ips[2]["10.0.0.1"] = now - 120
+			tokenLeakState.userAgents[2]["python"] = now - 120
+			tokenLeakState.spends[2] = map[int64]int64{now/3600 - 1000: 10}
+			tokenLeakState.paused[3] = now - 1
+			tokenLeakState.lastSweep = now - 120
+			tokenLeakState.Unlock()
+
+			RecordTokenAccess(1, "10.0.0.3", "curl")
+			So(tokenLeakState.ips[1], ShouldHaveLength, 2)
+			So(tokenLeakState.ips, ShouldNotContainKey, 2)
+			So(tokenLeakState.userAgents, ShouldNotContainKey, 2)
+			So(tokenLeakState.spends, ShouldNotContainKey, 2)
+			So(tokenLeakState.paused, ShouldNotContainKey, 3)
+		})
+
+		Convey("keeps the tokens within the window", func() {
+			tokenLeakState.lastSweep = 0
+			RecordTokenAccess(1, "10.0.0.1", "curl")
+			So(tokenLeakState.ips, ShouldContainKey, 2)
+			So(tokenLeakState.userAgents, ShouldContainKey, 2)
+		})
+	})
+}
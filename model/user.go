@@ -2,9 +2,16 @@ package model
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -13,7 +20,10 @@ import (
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/network"
 	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/common/secret"
+	"github.com/songquanpeng/one-api/common/totp"
 )
 
 const (
@@ -32,25 +42,32 @@ const (
 // User if you add sensitive fields, don't forget to clean them in setupLogin function.
 // Otherwise, the sensitive information will be saved on local storage in plain text!
 type User struct {
-	Id               int    `json:"id"`
-	Username         string `json:"username" gorm:"unique;index" validate:"max=12"`
-	Password         string `json:"password" gorm:"not null;" validate:"min=8,max=20"`
-	DisplayName      string `json:"display_name" gorm:"index" validate:"max=20"`
-	Role             int    `json:"role" gorm:"type:int;default:1"`   // admin, util
-	Status           int    `json:"status" gorm:"type:int;default:1"` // enabled, disabled
-	Email            string `json:"email" gorm:"index" validate:"max=50"`
-	GitHubId         string `json:"github_id" gorm:"column:github_id;index"`
-	WeChatId         string `json:"wechat_id" gorm:"column:wechat_id;index"`
-	LarkId           string `json:"lark_id" gorm:"column:lark_id;index"`
-	OidcId           string `json:"oidc_id" gorm:"column:oidc_id;index"`
-	VerificationCode string `json:"verification_code" gorm:"-:all"`                                    // this field is only for Email verification, don't save it to database!
-	AccessToken      string `json:"access_token" gorm:"type:char(32);column:access_token;uniqueIndex"` // this token is for system management
-	Quota            int64  `json:"quota" gorm:"bigint;default:0"`
-	UsedQuota        int64  `json:"used_quota" gorm:"bigint;default:0;column:used_quota"` // used quota
-	RequestCount     int    `json:"request_count" gorm:"type:int;default:0;"`             // request number
-	Group            string `json:"group" gorm:"type:varchar(32);default:'default'"`
-	AffCode          string `json:"aff_code" gorm:"type:varchar(32);column:aff_code;uniqueIndex"`
-	InviterId        int    `json:"inviter_id" gorm:"type:int;column:inviter_id;index"`
+	Id                int      `json:"id"`
+	Username          string   `json:"username" gorm:"unique;index" validate:"max=12"`
+	Password          string   `json:"password" gorm:"not null;" validate:"min=8,max=20"`
+	DisplayName       string   `json:"display_name" gorm:"index" validate:"max=20"`
+	Role              int      `json:"role" gorm:"type:int;default:1"`          // admin, util
+	RoleId            int      `json:"role_id" gorm:"type:int;default:0;index"` // custom role, see Role
+	Status            int      `json:"status" gorm:"type:int;default:1"`        // enabled, disabled
+	Email             string   `json:"email" gorm:"index" validate:"max=50"`
+	GitHubId          string   `json:"github_id" gorm:"column:github_id;index"`
+	WeChatId          string   `json:"wechat_id" gorm:"column:wechat_id;index"`
+	LarkId            string   `json:"lark_id" gorm:"column:lark_id;index"`
+	OidcId            string   `json:"oidc_id" gorm:"column:oidc_id;index"`
+	VerificationCode  string   `json:"verification_code" gorm:"-:all"` // this field is only for Email verification, don't save it to database!
+	Quota             int64    `json:"quota" gorm:"bigint;default:0"`
+	UsedQuota         int64    `json:"used_quota" gorm:"bigint;default:0;column:used_quota"` // used quota
+	RequestCount      int      `json:"request_count" gorm:"type:int;default:0;"`             // request number
+	Group             string   `json:"group" gorm:"type:varchar(32);default:'default'"`
+	AffCode           string   `json:"aff_code" gorm:"type:varchar(32);column:aff_code;uniqueIndex"`
+	InviterId         int      `json:"inviter_id" gorm:"type:int;column:inviter_id;index"`
+	TotpEnabled       bool     `json:"totp_enabled" gorm:"default:false"`
+	TotpSecret        string   `json:"-" gorm:"type:text"`             // encrypted when a master key is set
+	TotpRecoveryCodes string   `json:"-" gorm:"type:text"`             // hashes of unused recovery codes
+	TotpLastStep      int64    `json:"-" gorm:"bigint;default:0"`      // last accepted time step, prevents replaying a code
+	IpAllowList       string   `json:"ip_allow_list" gorm:"type:text"` // comma separated cidr, empty allows all
+	IpDenyList        string   `json:"ip_deny_list" gorm:"type:text"`
+	Permissions       []string `json:"permissions,omitempty" gorm:"-:all"` // only filled for the logged-in user
 }
 
 func GetMaxUserId() int {
@@ -95,7 +112,7 @@ func GetUserById(id int, selectAll bool) (*User, error) {
 	if selectAll {
 		err = DB.First(&user, "id = ?", id).Error
 	} else {
-		err = DB.Omit("password", "access_token").First(&user, "id = ?", id).Error
+		err = DB.Omit("password").First(&user, "id = ?", id).Error
 	}
 	return &user, err
 }
@@ -126,7 +143,6 @@ func (user *User) Insert(ctx context.Context, inviterId int) error {
 		}
 	}
 	user.Quota = config.QuotaForNewUser
-	user.AccessToken = random.GetUUID()
 	user.AffCode = random.GetRandomString(4)
 	result := DB.Create(user)
 	if result.Error != nil {
@@ -188,8 +204,10 @@ func (user *User) Delete() error {
 	blacklist.BanUser(user.Id)
 	user.Username = fmt.Sprintf("deleted_%s", random.GetUUID())
 	user.Status = UserStatusDeleted
-	err := DB.Model(user).Updates(user).Error
-	return err
+	if err := DB.Model(user).Updates(user).Error; err != nil {
+		return err
+	}
+	return RevokeUserSessions(user.Id, "")
 }
 
 // ValidateAndFill check password & user status
@@ -305,8 +323,14 @@ func ResetUserPasswordByEmail(email string, password string) error {
 	if err != nil {
 		return err
 	}
-	err = DB.Model(&User{}).Where("email = ?", email).Update("password", hashedPassword).Error
-	return err
+	user := User{}
+	if err = DB.Select("id").First(&user, "email = ?", email).Error; err != nil {
+		return err
+	}
+	if err = DB.Model(&User{}).Where("id = ?", user.Id).Update("password", hashedPassword).Error; err != nil {
+		return err
+	}
+	return RevokeUserSessions(user.Id, "")
 }
 
 func IsAdmin(userId int) bool {
@@ -334,18 +358,6 @@ func IsUserEnabled(userId int) (bool, error) {
 	return user.Status == UserStatusEnabled, nil
 }
 
-func ValidateAccessToken(token string) (user *User) {
-	if token == "" {
-		return nil
-	}
-	token = strings.Replace(token, "Bearer ", "", 1)
-	user = &User{}
-	if DB.Where("access_token = ?", token).First(user).RowsAffected == 1 {
-		return user
-	}
-	return nil
-}
-
 func GetUserQuota(id int) (quota int64, err error) {
 	err = DB.Model(&User{}).Where("id = ?", id).Select("quota").Find(&quota).Error
 	return quota, err
@@ -451,3 +463,141 @@ func GetUsernameById(id int) (username string) {
 	DB.Model(&User{}).Where("id = ?", id).Select("username").Find(&username)
 	return username
 }
+
+// RequireTwoFactor reports whether the user must enable 2FA before logging in
+func (user *User) RequireTwoFactor() bool {
+	return config.AdminTwoFactorEnabled && user.Role >= RoleAdminUser
+}
+
+// SetUserTotpSecret saves a secret that is not enabled until the user confirms a code
+func SetUserTotpSecret(id int, totpSecret string) error {
+	encrypted, err := secret.Encrypt(totpSecret)
+	if err != nil {
+		return err
+	}
+	return DB.Model(&User{}).Where("id = ? and totp_enabled = ?", id, false).Update("totp_secret", encrypted).Error
+}
+
+// hashRecoveryCode returns the hash stored for a recovery code of the user, keyed by TokenKeySecret
+// and salted with the user id, so that equal codes of different users do not share a hash
+func hashRecoveryCode(userId int, code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	mac := hmac.New(sha256.New, []byte(config.TokenKeySecret))
+	mac.Write([]byte(fmt.Sprintf("%d:%s", userId, code)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateRecoveryCode returns a code of 8 base32 characters from 40 random bits
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.ToLower(base32.StdEncoding.EncodeToString(b)), nil
+}
+
+// EnableUserTotp enables 2FA and returns the recovery codes, which are only stored as hashes
+func EnableUserTotp(id int, step int64) ([]string, error) {
+	codes := make([]string, 10)
+	hashes := make([]string, len(codes))
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = hashRecoveryCode(id, code)
+	}
+	err := DB.Model(&User{}).Where("id = ?", id).Updates(map[string]any{
+		"totp_enabled":        true,
+		"totp_recovery_codes": strings.Join(hashes, ","),
+		"totp_last_step":      step,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func DisableUserTotp(id int) error {
+	return DB.Model(&User{}).Where("id = ?", id).Updates(map[string]any{
+		"totp_enabled":        false,
+		"totp_secret":         "",
+		"totp_recovery_codes": "",
+		"totp_last_step":      0,
+	}).Error
+}
+
+func (user *User) GetTotpSecret() (string, error) {
+	return secret.Decrypt(user.TotpSecret)
+}
+
+// ValidateTwoFactor accepts a TOTP code or an unused recovery code, each code can only be used once
+func (user *User) ValidateTwoFactor(code string) bool {
+	if !user.TotpEnabled {
+		return false
+	}
+	totpSecret, err := user.GetTotpSecret()
+	if err != nil {
+		logger.SysError(fmt.Sprintf("decrypt totp secret of user %d failed: %s", user.Id, err.Error()))
+		return false
+	}
+	if step, ok := totp.Validate(totpSecret, code, time.Now()); ok {
+		result := DB.Model(&User{}).Where("id = ? and totp_last_step < ?", user.Id, step).Update("totp_last_step", step)
+		return result.Error == nil && result.RowsAffected == 1
+	}
+	hash := hashRecoveryCode(user.Id, code)
+	hashes := strings.Split(user.TotpRecoveryCodes, ",")
+	for i, h := range hashes {
+		if h == "" || subtle.ConstantTimeCompare([]byte(h), []byte(hash)) != 1 {
+			continue
+		}
+		remaining := strings.Join(append(hashes[:i:i], hashes[i+1:]...), ",")
+		result := DB.Model(&User{}).Where("id = ? and totp_recovery_codes = ?", user.Id, user.TotpRecoveryCodes).Update("totp_recovery_codes", remaining)
+		if result.Error != nil || result.RowsAffected != 1 {
+			return false
+		}
+		user.TotpRecoveryCodes = remaining
+		return true
+	}
+	return false
+}
+
+// UserIpLists are the ip lists of a user, as cached for TokenAuth and authHelper
+type UserIpLists struct {
+	AllowList string `json:"allow_list"`
+	DenyList  string `json:"deny_list"`
+}
+
+func GetUserIpLists(id int) (*UserIpLists, error) {
+	user := User{}
+	if err := DB.Select("ip_allow_list", "ip_deny_list").First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &UserIpLists{AllowList: user.IpAllowList, DenyList: user.IpDenyList}, nil
+}
+
+// SetUserIpLists validates and saves the ip lists of the user, empty lists are saved as well
+func SetUserIpLists(id int, allowList string, denyList string) error {
+	for _, list := range []string{allowList, denyList} {
+		if list == "" {
+			continue
+		}
+		if err := network.IsValidSubnets(list); err != nil {
+			return fmt.Errorf("无效的网段：%s", err.Error())
+		}
+	}
+	err := DB.Model(&User{}).Where("id = ?", id).Updates(map[string]any{
+		"ip_allow_list": allowList,
+		"ip_deny_list":  denyList,
+	}).Error
+	if err != nil {
+		return err
+	}
+	if common.RedisEnabled {
+		if err := common.RedisDel(fmt.Sprintf("user_ip_lists:%d", id)); err != nil {
+			logger.SysError("Redis delete user ip lists error: " + err.Error())
+		}
+	}
+	return nil
+}
@@ -29,6 +29,8 @@ func (a *Adaptor) GetRequestURL(meta *meta.Meta) (string, error) {
 		fullRequestURL = fmt.Sprintf("%s/api/v1/services/embeddings/text-embedding/text-embedding", meta.BaseURL)
 	case relaymode.ImagesGenerations:
 		fullRequestURL = fmt.Sprintf("%s/api/v1/services/aigc/text2image/image-synthesis", meta.BaseURL)
+	case relaymode.ImagesEdits:
+		fullRequestURL = fmt.Sprintf("%s/api/v1/services/aigc/image2image/image-synthesis", meta.BaseURL)
 	default:
 		fullRequestURL = fmt.Sprintf("%s/api/v1/services/aigc/text-generation/generation", meta.BaseURL)
 	}
@@ -44,7 +46,11 @@ func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Request, meta *me
 	}
 	req.Header.Set("Authorization", "Bearer "+meta.APIKey)
 
-	if meta.Mode == relaymode.ImagesGenerations {
+	switch meta.Mode {
+	case relaymode.ImagesGenerations:
+		req.Header.Set("X-DashScope-Async", "enable")
+	case relaymode.ImagesEdits:
+		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("X-DashScope-Async", "enable")
 	}
 	if a.meta.Config.Plugin != "" {
@@ -76,6 +82,16 @@ func (a *Adaptor) ConvertImageRequest(request *model.ImageRequest) (any, error)
 	return aliRequest, nil
 }
 
+func (a *Adaptor) ConvertImageEditRequest(relayMode int, request *model.ImageEditRequest) (any, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	if relayMode != relaymode.ImagesEdits {
+		return nil, errors.New("image variations are not supported by ali")
+	}
+	return ConvertImageEditRequest(*request)
+}
+
 func (a *Adaptor) DoRequest(c *gin.Context, meta *meta.Meta, requestBody io.Reader) (*http.Response, error) {
 	return adaptor.DoRequestHelper(a, c, meta, requestBody)
 }
@@ -87,7 +103,7 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Met
 		switch meta.Mode {
 		case relaymode.Embeddings:
 			err, usage = EmbeddingHandler(c, resp)
-		case relaymode.ImagesGenerations:
+		case relaymode.ImagesGenerations, relaymode.ImagesEdits:
 			err, usage = ImageHandler(c, resp)
 		default:
 			err, usage = Handler(c, resp)
@@ -12,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/image"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
 	"github.com/songquanpeng/one-api/relay/model"
@@ -78,6 +79,28 @@ func ConvertImageRequest(request model.ImageRequest) *ImageRequest {
 	return &imageRequest
 }
 
+func ConvertImageEditRequest(request model.ImageEditRequest) (*ImageEditRequest, error) {
+	var imageRequest ImageEditRequest
+	imageRequest.Model = request.Model
+	imageRequest.Input.Prompt = request.Prompt
+	imageRequest.Input.Function = "description_edit"
+	baseImage, err := image.GetImageDataURLFromFile(request.Image)
+	if err != nil {
+		return nil, err
+	}
+	imageRequest.Input.BaseImageUrl = baseImage
+	if request.Mask != nil {
+		maskImage, err := image.GetImageDataURLFromFile(request.Mask)
+		if err != nil {
+			return nil, err
+		}
+		imageRequest.Input.Function = "description_edit_with_mask"
+		imageRequest.Input.MaskImageUrl = maskImage
+	}
+	imageRequest.Parameters.N = request.N
+	return &imageRequest, nil
+}
+
 func EmbeddingHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusCode, *model.Usage) {
 	var aliResponse EmbeddingResponse
 	err := json.NewDecoder(resp.Body).Decode(&aliResponse)
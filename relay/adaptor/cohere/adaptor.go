@@ -8,8 +8,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/relay/adaptor"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
 	"github.com/songquanpeng/one-api/relay/meta"
 	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
 )
 
 type Adaptor struct{}
@@ -26,6 +28,9 @@ func (a *Adaptor) Init(meta *meta.Meta) {
 }
 
 func (a *Adaptor) GetRequestURL(meta *meta.Meta) (string, error) {
+	if meta.Mode == relaymode.Rerank {
+		return fmt.Sprintf("%s/v1/rerank", meta.BaseURL), nil
+	}
 	return fmt.Sprintf("%s/v1/chat", meta.BaseURL), nil
 }
 
@@ -47,7 +52,9 @@ func (a *Adaptor) DoRequest(c *gin.Context, meta *meta.Meta, requestBody io.Read
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Meta) (usage *model.Usage, err *model.ErrorWithStatusCode) {
-	if meta.IsStream {
+	if meta.Mode == relaymode.Rerank {
+		err, usage = openai.RerankHandler(c, resp, meta.PromptTokens, meta.ActualModelName)
+	} else if meta.IsStream {
 		err, usage = StreamHandler(c, resp)
 	} else {
 		err, usage = Handler(c, resp, meta.PromptTokens, meta.ActualModelName)
@@ -19,3 +19,10 @@ type Adaptor interface {
 	GetModelList() []string
 	GetChannelName() string
 }
+
+// ImageEditAdaptor is implemented by adaptors whose upstream needs /v1/images/edits
+// and /v1/images/variations translated into its own request format.
+// Adaptors without it receive the original multipart body.
+type ImageEditAdaptor interface {
+	ConvertImageEditRequest(relayMode int, request *model.ImageEditRequest) (any, error)
+}
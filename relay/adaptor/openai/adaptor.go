@@ -39,6 +39,12 @@ func (a *Adaptor) GetRequestURL(meta *meta.Meta) (string, error) {
 			fullRequestURL := fmt.Sprintf("%s/openai/deployments/%s/images/generations?api-version=%s", meta.BaseURL, meta.ActualModelName, meta.Config.APIVersion)
 			return fullRequestURL, nil
 		}
+		if meta.Mode == relaymode.ImagesEdits || meta.Mode == relaymode.ImagesVariations {
+			// https://{resource_name}.openai.azure.com/openai/deployments/dall-e-2/images/edits?api-version=2025-04-01-preview
+			task := strings.TrimPrefix(strings.Split(meta.RequestURLPath, "?")[0], "/v1/")
+			fullRequestURL := fmt.Sprintf("%s/openai/deployments/%s/%s?api-version=%s", meta.BaseURL, meta.ActualModelName, task, meta.Config.APIVersion)
+			return fullRequestURL, nil
+		}
 
 		// https://learn.microsoft.com/en-us/azure/cognitive-services/openai/chatgpt-quickstart?pivots=rest-api&tabs=command-line#rest-api
 		requestURL := strings.Split(meta.RequestURLPath, "?")[0]
@@ -119,8 +125,12 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Met
 		}
 	} else {
 		switch meta.Mode {
-		case relaymode.ImagesGenerations:
+		case relaymode.ImagesGenerations,
+			relaymode.ImagesEdits,
+			relaymode.ImagesVariations:
 			err, _ = ImageHandler(c, resp)
+		case relaymode.Rerank:
+			err, usage = RerankHandler(c, resp, meta.PromptTokens, meta.ActualModelName)
 		default:
 			err, usage = Handler(c, resp, meta.PromptTokens, meta.ActualModelName)
 		}
@@ -0,0 +1,180 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/meta"
+)
+
+// https://platform.openai.com/docs/guides/realtime
+
+type RealtimeUsage struct {
+	TotalTokens       int `json:"total_tokens"`
+	InputTokens       int `json:"input_tokens"`
+	OutputTokens      int `json:"output_tokens"`
+	InputTokenDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+		TextTokens   int `json:"text_tokens"`
+		AudioTokens  int `json:"audio_tokens"`
+	} `json:"input_token_details"`
+	OutputTokenDetails struct {
+		TextTokens  int `json:"text_tokens"`
+		AudioTokens int `json:"audio_tokens"`
+	} `json:"output_token_details"`
+}
+
+type RealtimeEvent struct {
+	Type     string `json:"type"`
+	Response *struct {
+		Usage *RealtimeUsage `json:"usage,omitempty"`
+	} `json:"response,omitempty"`
+}
+
+type RealtimeErrorEvent struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func GetRealtimeURL(meta *meta.Meta) (string, error) {
+	baseURL := meta.BaseURL
+	switch {
+	case strings.HasPrefix(baseURL, "https://"):
+		baseURL = "wss://" + strings.TrimPrefix(baseURL, "https://")
+	case strings.HasPrefix(baseURL, "http://"):
+		baseURL = "ws://" + strings.TrimPrefix(baseURL, "http://")
+	}
+	switch meta.ChannelType {
+	case channeltype.OpenAI:
+		return fmt.Sprintf("%s/v1/realtime?model=%s", baseURL, url.QueryEscape(meta.ActualModelName)), nil
+	case channeltype.Azure:
+		// https://learn.microsoft.com/en-us/azure/ai-services/openai/how-to/realtime-audio-websockets
+		return fmt.Sprintf("%s/openai/realtime?api-version=%s&deployment=%s", baseURL, meta.Config.APIVersion, url.QueryEscape(meta.ActualModelName)), nil
+	}
+	return "", fmt.Errorf("realtime is not supported by channel type %d", meta.ChannelType)
+}
+
+// DialRealtime opens the upstream session, resp is returned for handshake errors
+func DialRealtime(meta *meta.Meta) (*websocket.Conn, *http.Response, error) {
+	fullRequestURL, err := GetRealtimeURL(meta)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := http.Header{}
+	if meta.ChannelType == channeltype.Azure {
+		header.Set("api-key", meta.APIKey)
+	} else {
+		header.Set("Authorization", "Bearer "+meta.APIKey)
+		header.Set("OpenAI-Beta", "realtime=v1")
+	}
+	dialer := websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+		Proxy:            http.ProxyFromEnvironment,
+	}
+	if config.RelayProxy != "" {
+		proxyURL, err := url.Parse(config.RelayProxy)
+		if err != nil {
+			return nil, nil, err
+		}
+		dialer.Proxy = http.ProxyURL(proxyURL)
+	}
+	return dialer.Dial(fullRequestURL, header)
+}
+
+var responseDoneEventType = []byte(`"response.done"`)
+
+// RealtimeHandler pipes events between the client and the upstream until one side closes.
+// onUsage is called with the usage of every response.done event after it is forwarded,
+// a non-nil error sends an error event to the client and ends the session.
+func RealtimeHandler(clientConn *websocket.Conn, upstreamConn *websocket.Conn, onUsage func(usage *RealtimeUsage) error) error {
+	errChan := make(chan error, 2)
+	var once sync.Once
+	finish := func(err error) {
+		once.Do(func() {
+			errChan <- err
+		})
+	}
+
+	// client -> upstream
+	go func() {
+		for {
+			messageType, message, err := clientConn.ReadMessage()
+			if err != nil {
+				finish(err)
+				return
+			}
+			if err = upstreamConn.WriteMessage(messageType, message); err != nil {
+				finish(err)
+				return
+			}
+		}
+	}()
+
+	// upstream -> client
+	go func() {
+		for {
+			messageType, message, err := upstreamConn.ReadMessage()
+			if err != nil {
+				finish(err)
+				return
+			}
+			if err = clientConn.WriteMessage(messageType, message); err != nil {
+				finish(err)
+				return
+			}
+			if messageType != websocket.TextMessage || !bytes.Contains(message, responseDoneEventType) {
+				continue
+			}
+			var event RealtimeEvent
+			if err = json.Unmarshal(message, &event); err != nil || event.Type != "response.done" {
+				continue
+			}
+			if event.Response == nil || event.Response.Usage == nil {
+				continue
+			}
+			if err = onUsage(event.Response.Usage); err != nil {
+				errorEvent := RealtimeErrorEvent{Type: "error"}
+				errorEvent.Error.Type = "insufficient_quota"
+				errorEvent.Error.Code = "insufficient_quota"
+				errorEvent.Error.Message = err.Error()
+				_ = clientConn.WriteJSON(errorEvent)
+				finish(err)
+				return
+			}
+		}
+	}()
+
+	err := <-errChan
+	closeCode, closeText := websocket.CloseNormalClosure, ""
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		closeCode, closeText = closeErr.Code, closeErr.Text
+		if closeCode == websocket.CloseNoStatusReceived || closeCode == websocket.CloseAbnormalClosure {
+			closeCode = websocket.CloseNormalClosure
+		}
+		err = nil
+	} else if err != nil {
+		closeCode, closeText = websocket.ClosePolicyViolation, err.Error()
+	}
+	deadline := time.Now().Add(time.Second)
+	_ = clientConn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, closeText), deadline)
+	_ = upstreamConn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, closeText), deadline)
+	_ = clientConn.Close()
+	_ = upstreamConn.Close()
+	return err
+}
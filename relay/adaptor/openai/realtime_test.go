@@ -0,0 +1,136 @@
+package openai
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/meta"
+)
+
+const responseDoneEvent = `{"type":"response.done","response":{"usage":{"total_tokens":30,"input_tokens":10,"output_tokens":20,"input_token_details":{"text_tokens":4,"audio_tokens":6},"output_token_details":{"text_tokens":5,"audio_tokens":15}}}}`
+
+// newRealtimeUpstream answers every response.create with a response.done event carrying usage
+func newRealtimeUpstream() *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/realtime" || r.URL.Query().Get("model") == "" || r.Header.Get("Authorization") != "Bearer sk-upstream" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"session.created"}`))
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if strings.Contains(string(message), "response.create") {
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(responseDoneEvent))
+			}
+		}
+	}))
+}
+
+func newRealtimeProxy(upstreamURL string, onUsage func(usage *RealtimeUsage) error) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamConn, _, err := DialRealtime(&meta.Meta{
+			ChannelType:     channeltype.OpenAI,
+			APIKey:          "sk-upstream",
+			BaseURL:         upstreamURL,
+			ActualModelName: "gpt-4o-realtime-preview",
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		clientConn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = RealtimeHandler(clientConn, upstreamConn, onUsage)
+	}))
+}
+
+func dialProxy(proxy *httptest.Server) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(proxy.URL, "http"), nil)
+	return conn, err
+}
+
+func TestRealtimeHandler(t *testing.T) {
+	Convey("RealtimeHandler", t, func() {
+		upstream := newRealtimeUpstream()
+		defer upstream.Close()
+
+		Convey("forwards events and reports usage", func() {
+			usageChan := make(chan *RealtimeUsage, 2)
+			proxy := newRealtimeProxy(upstream.URL, func(usage *RealtimeUsage) error {
+				usageChan <- usage
+				return nil
+			})
+			defer proxy.Close()
+			conn, err := dialProxy(proxy)
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			_, message, err := conn.ReadMessage()
+			So(err, ShouldBeNil)
+			So(string(message), ShouldContainSubstring, "session.created")
+			for i := 0; i < 2; i++ {
+				So(conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"response.create"}`)), ShouldBeNil)
+				_, message, err = conn.ReadMessage()
+				So(err, ShouldBeNil)
+				So(string(message), ShouldEqual, responseDoneEvent)
+				usage := <-usageChan
+				So(usage.TotalTokens, ShouldEqual, 30)
+				So(usage.InputTokenDetails.AudioTokens, ShouldEqual, 6)
+				So(usage.OutputTokenDetails.AudioTokens, ShouldEqual, 15)
+			}
+		})
+
+		Convey("ends the session when usage is rejected", func() {
+			proxy := newRealtimeProxy(upstream.URL, func(usage *RealtimeUsage) error {
+				return errors.New("quota exhausted")
+			})
+			defer proxy.Close()
+			conn, err := dialProxy(proxy)
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			_, _, err = conn.ReadMessage()
+			So(err, ShouldBeNil)
+			So(conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"response.create"}`)), ShouldBeNil)
+			_, message, err := conn.ReadMessage()
+			So(err, ShouldBeNil)
+			So(string(message), ShouldEqual, responseDoneEvent)
+			_, message, err = conn.ReadMessage()
+			So(err, ShouldBeNil)
+			So(string(message), ShouldContainSubstring, "insufficient_quota")
+			_, _, err = conn.ReadMessage()
+			So(websocket.IsCloseError(err, websocket.ClosePolicyViolation), ShouldBeTrue)
+		})
+
+		Convey("fails the dial when upstream rejects the key", func() {
+			_, resp, err := DialRealtime(&meta.Meta{
+				ChannelType:     channeltype.OpenAI,
+				APIKey:          "sk-wrong",
+				BaseURL:         upstream.URL,
+				ActualModelName: "gpt-4o-realtime-preview",
+			})
+			So(err, ShouldNotBeNil)
+			So(resp, ShouldNotBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+		})
+	})
+}
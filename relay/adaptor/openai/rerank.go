@@ -0,0 +1,57 @@
+package openai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// RerankHandler converts cohere, jina and siliconflow style responses into model.RerankResponse
+func RerankHandler(c *gin.Context, resp *http.Response, promptTokens int, modelName string) (*model.ErrorWithStatusCode, *model.Usage) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+	var rerankResponse model.RerankResponse
+	err = json.Unmarshal(responseBody, &rerankResponse)
+	if err != nil {
+		return ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+
+	usage := &model.Usage{}
+	switch {
+	case rerankResponse.Usage != nil && rerankResponse.Usage.TotalTokens != 0:
+		usage.PromptTokens = rerankResponse.Usage.TotalTokens
+	case rerankResponse.Meta != nil && rerankResponse.Meta.Tokens != nil:
+		usage.PromptTokens = rerankResponse.Meta.Tokens.InputTokens + rerankResponse.Meta.Tokens.OutputTokens
+	case rerankResponse.Meta != nil && rerankResponse.Meta.BilledUnits != nil && rerankResponse.Meta.BilledUnits.InputTokens != 0:
+		usage.PromptTokens = rerankResponse.Meta.BilledUnits.InputTokens + rerankResponse.Meta.BilledUnits.OutputTokens
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = promptTokens
+	}
+	usage.TotalTokens = usage.PromptTokens
+	rerankResponse.Usage = usage
+	if rerankResponse.Model == "" {
+		rerankResponse.Model = modelName
+	}
+
+	jsonResponse, err := json.Marshal(rerankResponse)
+	if err != nil {
+		return ErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, err = c.Writer.Write(jsonResponse)
+	if err != nil {
+		return ErrorWrapper(err, "write_response_body_failed", http.StatusInternalServerError), nil
+	}
+	return nil, usage
+}
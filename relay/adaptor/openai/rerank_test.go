@@ -0,0 +1,72 @@
+package openai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+func TestRerankHandler(t *testing.T) {
+	Convey("RerankHandler", t, func() {
+		gin.SetMode(gin.TestMode)
+		cases := []struct {
+			name         string
+			body         string
+			promptTokens int
+		}{
+			{"usage total tokens", `{"model":"jina-reranker-v2","results":[],"usage":{"total_tokens":42}}`, 42},
+			{"meta tokens", `{"results":[],"meta":{"tokens":{"input_tokens":40,"output_tokens":2},"billed_units":{"search_units":1}}}`, 42},
+			{"meta billed units", `{"results":[],"meta":{"billed_units":{"input_tokens":30,"output_tokens":12}}}`, 42},
+			{"search units only", `{"results":[],"meta":{"billed_units":{"search_units":1}}}`, 7},
+			{"no usage", `{"results":[{"index":0,"relevance_score":0.9}]}`, 7},
+		}
+		for _, tc := range cases {
+			Convey(tc.name, func() {
+				recorder := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(recorder)
+				resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(tc.body))}
+				errWithStatus, usage := RerankHandler(c, resp, 7, "rerank-v3.5")
+				So(errWithStatus, ShouldBeNil)
+				So(usage.PromptTokens, ShouldEqual, tc.promptTokens)
+				So(usage.TotalTokens, ShouldEqual, tc.promptTokens)
+
+				var rerankResponse model.RerankResponse
+				So(json.Unmarshal(recorder.Body.Bytes(), &rerankResponse), ShouldBeNil)
+				So(rerankResponse.Usage.TotalTokens, ShouldEqual, tc.promptTokens)
+				So(rerankResponse.Model, ShouldNotBeEmpty)
+			})
+		}
+
+		Convey("keeps the upstream model and fills a missing one", func() {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"model":"jina-reranker-v2","results":[]}`))}
+			_, _ = RerankHandler(c, resp, 1, "rerank-v3.5")
+			So(recorder.Body.String(), ShouldContainSubstring, `"model":"jina-reranker-v2"`)
+
+			recorder = httptest.NewRecorder()
+			c, _ = gin.CreateTestContext(recorder)
+			resp = &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"results":[]}`))}
+			_, _ = RerankHandler(c, resp, 1, "rerank-v3.5")
+			So(recorder.Body.String(), ShouldContainSubstring, `"model":"rerank-v3.5"`)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, "application/json")
+		})
+
+		Convey("invalid json", func() {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`not json`))}
+			errWithStatus, usage := RerankHandler(c, resp, 1, "rerank-v3.5")
+			So(usage, ShouldBeNil)
+			So(errWithStatus, ShouldNotBeNil)
+			So(errWithStatus.StatusCode, ShouldEqual, http.StatusInternalServerError)
+		})
+	})
+}
@@ -36,7 +36,22 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Met
 	}
 
 	c.Writer.WriteHeader(resp.StatusCode)
-	if _, gerr := io.Copy(c.Writer, resp.Body); gerr != nil {
+	extractor := &usageExtractor{config: meta.Config}
+	var gerr error
+	switch {
+	case !meta.Config.IsProxyBillingEnabled() || resp.StatusCode/100 != 2:
+		_, gerr = io.Copy(c.Writer, resp.Body)
+	case strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"):
+		gerr = extractor.copyEventStream(c.Writer, resp.Body, c.Writer.Flush)
+	default:
+		var body []byte
+		body, gerr = io.ReadAll(resp.Body)
+		if gerr == nil {
+			_, gerr = c.Writer.Write(body)
+			extractor.extract(body)
+		}
+	}
+	if gerr != nil {
 		return nil, &relaymodel.ErrorWithStatusCode{
 			StatusCode: http.StatusInternalServerError,
 			Error: relaymodel.Error{
@@ -45,7 +60,7 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Met
 		}
 	}
 
-	return nil, nil
+	return extractor.Usage(), nil
 }
 
 func (a *Adaptor) GetModelList() (models []string) {
@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	dbmodel "github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// lookupJSONPath supports the common subset of JSONPath: $.a.b, $.a[0].b, $['a'] and negative indexes
+func lookupJSONPath(data any, path string) (any, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	for path != "" {
+		switch {
+		case path[0] == '.':
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end == -1 {
+				end = len(path)
+			}
+			key := path[:end]
+			path = path[end:]
+			object, ok := data.(map[string]any)
+			if !ok {
+				return nil, errors.Errorf("%s is not an object", key)
+			}
+			if data, ok = object[key]; !ok {
+				return nil, errors.Errorf("key %s not found", key)
+			}
+		case path[0] == '[':
+			end := strings.IndexByte(path, ']')
+			if end == -1 {
+				return nil, errors.New("unclosed bracket")
+			}
+			selector := path[1:end]
+			path = path[end+1:]
+			if unquoted := strings.Trim(selector, `'"`); unquoted != selector {
+				object, ok := data.(map[string]any)
+				if !ok {
+					return nil, errors.Errorf("%s is not an object", unquoted)
+				}
+				if data, ok = object[unquoted]; !ok {
+					return nil, errors.Errorf("key %s not found", unquoted)
+				}
+				continue
+			}
+			index, err := strconv.Atoi(selector)
+			if err != nil {
+				return nil, errors.Errorf("invalid index %s", selector)
+			}
+			array, ok := data.([]any)
+			if !ok {
+				return nil, errors.Errorf("[%d] is not an array", index)
+			}
+			if index < 0 {
+				index += len(array)
+			}
+			if index < 0 || index >= len(array) {
+				return nil, errors.Errorf("index %d out of range", index)
+			}
+			data = array[index]
+		default:
+			// allow paths without the leading $.
+			path = "." + path
+		}
+	}
+	return data, nil
+}
+
+func lookupTokens(data any, path string) (int, bool) {
+	if path == "" {
+		return 0, false
+	}
+	value, err := lookupJSONPath(data, path)
+	if err != nil {
+		return 0, false
+	}
+	switch v := value.(type) {
+	case float64:
+		return int(v), true
+	case string:
+		tokens, err := strconv.Atoi(v)
+		return tokens, err == nil
+	}
+	return 0, false
+}
+
+// usageExtractor collects usage from json bodies or server-sent events,
+// for streams the last non-zero value of each path wins
+type usageExtractor struct {
+	config dbmodel.ChannelConfig
+	usage  *model.Usage
+}
+
+func (e *usageExtractor) extract(body []byte) {
+	var data any
+	if err := json.Unmarshal(body, &data); err != nil {
+		return
+	}
+	if tokens, ok := lookupTokens(data, e.config.ProxyPromptTokensPath); ok && tokens > 0 {
+		e.getUsage().PromptTokens = tokens
+	}
+	if tokens, ok := lookupTokens(data, e.config.ProxyCompletionTokensPath); ok && tokens > 0 {
+		e.getUsage().CompletionTokens = tokens
+	}
+}
+
+func (e *usageExtractor) getUsage() *model.Usage {
+	if e.usage == nil {
+		e.usage = &model.Usage{}
+	}
+	return e.usage
+}
+
+func (e *usageExtractor) Usage() *model.Usage {
+	if e.usage != nil {
+		e.usage.TotalTokens = e.usage.PromptTokens + e.usage.CompletionTokens
+	}
+	return e.usage
+}
+
+// copyEventStream forwards the stream line by line and extracts usage from every data line
+func (e *usageExtractor) copyEventStream(w io.Writer, r io.Reader, flush func()) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, werr := w.Write(line); werr != nil {
+				return werr
+			}
+			if data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:")); ok {
+				e.extract(bytes.TrimSpace(data))
+			}
+			if len(bytes.TrimSpace(line)) == 0 {
+				flush()
+			}
+		}
+		if err == io.EOF {
+			flush()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
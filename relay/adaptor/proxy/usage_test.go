@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	dbmodel "github.com/songquanpeng/one-api/model"
+)
+
+func TestUsageExtractor(t *testing.T) {
+	Convey("usageExtractor", t, func() {
+		Convey("json body", func() {
+			extractor := &usageExtractor{config: dbmodel.ChannelConfig{
+				ProxyPromptTokensPath:     "$.usage.input_tokens",
+				ProxyCompletionTokensPath: "$['usage'].details[-1].tokens",
+			}}
+			extractor.extract([]byte(`{"usage":{"input_tokens":12,"details":[{"tokens":1},{"tokens":"30"}]}}`))
+			usage := extractor.Usage()
+			So(usage, ShouldNotBeNil)
+			So(usage.PromptTokens, ShouldEqual, 12)
+			So(usage.CompletionTokens, ShouldEqual, 30)
+			So(usage.TotalTokens, ShouldEqual, 42)
+		})
+
+		Convey("missing usage", func() {
+			extractor := &usageExtractor{config: dbmodel.ChannelConfig{ProxyPromptTokensPath: "usage.prompt_tokens"}}
+			extractor.extract([]byte(`{"choices":[]}`))
+			So(extractor.Usage(), ShouldBeNil)
+		})
+
+		Convey("event stream", func() {
+			extractor := &usageExtractor{config: dbmodel.ChannelConfig{
+				ProxyPromptTokensPath:     "usage.prompt_tokens",
+				ProxyCompletionTokensPath: "usage.completion_tokens",
+			}}
+			stream := "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+				"data: {\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":0}}\n\n" +
+				"data: {\"usage\":{\"prompt_tokens\":0,\"completion_tokens\":7}}\n\n" +
+				"data: [DONE]\n\n"
+			out := &bytes.Buffer{}
+			err := extractor.copyEventStream(out, strings.NewReader(stream), func() {})
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldEqual, stream)
+			usage := extractor.Usage()
+			So(usage.PromptTokens, ShouldEqual, 5)
+			So(usage.CompletionTokens, ShouldEqual, 7)
+		})
+	})
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"github.com/songquanpeng/one-api/common/image"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/relay/adaptor"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
@@ -39,6 +40,47 @@ func (*Adaptor) ConvertImageRequest(request *model.ImageRequest) (any, error) {
 	}, nil
 }
 
+// ConvertImageEditRequest implements adaptor.ImageEditAdaptor.
+//
+// edits are sent to flux-fill models, variations to flux-redux models.
+func (*Adaptor) ConvertImageEditRequest(relayMode int, request *model.ImageEditRequest) (any, error) {
+	img, err := image.GetImageDataURLFromFile(request.Image)
+	if err != nil {
+		return nil, errors.Wrap(err, "read image")
+	}
+
+	if relayMode == relaymode.ImagesVariations {
+		return ReduxImageRequest{
+			Input: FluxReduxInput{
+				ReduxImage:   img,
+				Seed:         int(time.Now().UnixNano()),
+				AspectRatio:  "1:1",
+				NumOutputs:   1, // replicate will always return 1 image
+				OutputFormat: "png",
+			},
+		}, nil
+	}
+
+	var mask string
+	if request.Mask != nil {
+		if mask, err = image.GetImageDataURLFromFile(request.Mask); err != nil {
+			return nil, errors.Wrap(err, "read mask")
+		}
+	}
+	return InpaintingImageByFlusReplicateRequest{
+		Input: FluxInpaintingInput{
+			Mask:            mask,
+			Image:           img,
+			Seed:            int(time.Now().UnixNano()),
+			Steps:           50,
+			Prompt:          request.Prompt,
+			Guidance:        3,
+			OutputFormat:    "png",
+			SafetyTolerance: 5,
+		},
+	}, nil
+}
+
 func (a *Adaptor) ConvertRequest(c *gin.Context, relayMode int, request *model.GeneralOpenAIRequest) (any, error) {
 	if !request.Stream {
 		// TODO: support non-stream mode
@@ -105,6 +147,9 @@ func (a *Adaptor) GetRequestURL(meta *meta.Meta) (string, error) {
 
 func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Request, meta *meta.Meta) error {
 	adaptor.SetupCommonRequestHeader(c, req, meta)
+	if meta.Mode == relaymode.ImagesEdits || meta.Mode == relaymode.ImagesVariations {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	req.Header.Set("Authorization", "Bearer "+meta.APIKey)
 	return nil
 }
@@ -116,7 +161,9 @@ func (a *Adaptor) DoRequest(c *gin.Context, meta *meta.Meta, requestBody io.Read
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Meta) (usage *model.Usage, err *model.ErrorWithStatusCode) {
 	switch meta.Mode {
-	case relaymode.ImagesGenerations:
+	case relaymode.ImagesGenerations,
+		relaymode.ImagesEdits,
+		relaymode.ImagesVariations:
 		err, usage = ImageHandler(c, resp)
 	case relaymode.ChatCompletions:
 		err, usage = ChatHandler(c, resp)
@@ -41,7 +41,7 @@ type InpaintingImageByFlusReplicateRequest struct {
 //
 // https://replicate.com/black-forest-labs/flux-fill-pro/api/schema
 type FluxInpaintingInput struct {
-	Mask             string `json:"mask" binding:"required"`
+	Mask             string `json:"mask,omitempty"`
 	Image            string `json:"image" binding:"required"`
 	Seed             int    `json:"seed"`
 	Steps            int    `json:"steps" binding:"required,min=1"`
@@ -52,6 +52,24 @@ type FluxInpaintingInput struct {
 	PromptUnsampling bool   `json:"prompt_unsampling"`
 }
 
+// ReduxImageRequest is request to generate variations of an image by flux redux
+//
+// https://replicate.com/black-forest-labs/flux-redux-dev/api/schema
+type ReduxImageRequest struct {
+	Input FluxReduxInput `json:"input"`
+}
+
+// FluxReduxInput is input of ReduxImageRequest
+//
+// https://replicate.com/black-forest-labs/flux-redux-dev/api/schema
+type FluxReduxInput struct {
+	ReduxImage   string `json:"redux_image" binding:"required"`
+	Seed         int    `json:"seed"`
+	AspectRatio  string `json:"aspect_ratio"`
+	NumOutputs   int    `json:"num_outputs" binding:"min=1,max=4"`
+	OutputFormat string `json:"output_format"`
+}
+
 // ImageResponse is response of DrawImageByFluxProRequest
 //
 // https://replicate.com/black-forest-labs/flux-pro?prediction=kg1krwsdf9rg80ch1sgsrgq7h8&output=json
@@ -0,0 +1,109 @@
+package ratio
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+var audioRatioLock sync.RWMutex
+
+// AudioCharacterRatio is the quota of one input character for text-to-speech models
+// 1 === $0.002 / 1K characters
+var AudioCharacterRatio = map[string]float64{
+	"tts-1":         7.5, // $0.015 / 1K characters
+	"tts-1-1106":    7.5,
+	"tts-1-hd":      15, // $0.030 / 1K characters
+	"tts-1-hd-1106": 15,
+}
+
+// AudioSecondRatio is the quota of one second of audio for speech-to-text models
+// 1 === $0.002 / 1K seconds
+var AudioSecondRatio = map[string]float64{
+	"whisper-1":              50, // $0.006 / minute
+	"gpt-4o-transcribe":      50, // $0.006 / minute (estimated)
+	"gpt-4o-mini-transcribe": 25, // $0.003 / minute (estimated)
+}
+
+func AudioCharacterRatio2JSONString() string {
+	jsonBytes, err := json.Marshal(AudioCharacterRatio)
+	if err != nil {
+		logger.SysError("error marshalling audio character ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateAudioCharacterRatioByJSONString(jsonStr string) error {
+	audioRatioLock.Lock()
+	defer audioRatioLock.Unlock()
+	AudioCharacterRatio = make(map[string]float64)
+	return json.Unmarshal([]byte(jsonStr), &AudioCharacterRatio)
+}
+
+func AudioSecondRatio2JSONString() string {
+	jsonBytes, err := json.Marshal(AudioSecondRatio)
+	if err != nil {
+		logger.SysError("error marshalling audio second ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateAudioSecondRatioByJSONString(jsonStr string) error {
+	audioRatioLock.Lock()
+	defer audioRatioLock.Unlock()
+	AudioSecondRatio = make(map[string]float64)
+	return json.Unmarshal([]byte(jsonStr), &AudioSecondRatio)
+}
+
+func getAudioRatio(ratios map[string]float64, name string, channelType int) (float64, bool) {
+	audioRatioLock.RLock()
+	defer audioRatioLock.RUnlock()
+	if ratio, ok := ratios[fmt.Sprintf("%s(%d)", name, channelType)]; ok {
+		return ratio, true
+	}
+	ratio, ok := ratios[name]
+	return ratio, ok
+}
+
+// GetAudioCharacterRatio returns false if the model is not billed by characters
+func GetAudioCharacterRatio(name string, channelType int) (float64, bool) {
+	return getAudioRatio(AudioCharacterRatio, name, channelType)
+}
+
+// GetAudioSecondRatio returns false if the model is not billed by seconds
+func GetAudioSecondRatio(name string, channelType int) (float64, bool) {
+	return getAudioRatio(AudioSecondRatio, name, channelType)
+}
+
+// AudioPromptRatio is the price of an input audio token relative to an input text token
+// for models that take audio tokens, such as the realtime models
+var AudioPromptRatio = map[string]float64{
+	"gpt-4o-realtime-preview":                 8,     // $40 / 1M tokens
+	"gpt-4o-realtime-preview-2024-12-17":      8,     // $40 / 1M tokens
+	"gpt-4o-mini-realtime-preview":            16.67, // $10 / 1M tokens
+	"gpt-4o-mini-realtime-preview-2024-12-17": 16.67, // $10 / 1M tokens
+}
+
+// AudioCompletionRatio is the price of an output audio token relative to an output text token
+var AudioCompletionRatio = map[string]float64{
+	"gpt-4o-realtime-preview":                 4,    // $80 / 1M tokens
+	"gpt-4o-realtime-preview-2024-12-17":      4,    // $80 / 1M tokens
+	"gpt-4o-mini-realtime-preview":            8.33, // $20 / 1M tokens
+	"gpt-4o-mini-realtime-preview-2024-12-17": 8.33, // $20 / 1M tokens
+}
+
+func GetAudioPromptRatio(name string) float64 {
+	if ratio, ok := AudioPromptRatio[name]; ok {
+		return ratio
+	}
+	return 1
+}
+
+func GetAudioCompletionRatio(name string) float64 {
+	if ratio, ok := AudioCompletionRatio[name]; ok {
+		return ratio
+	}
+	return 1
+}
@@ -0,0 +1,50 @@
+package ratio
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+var rerankSearchRatioLock sync.RWMutex
+
+// RerankSearchRatio is the quota of one search for rerank models billed by searches,
+// a search is one query with up to RerankDocumentsPerSearch documents.
+// Rerank models not listed here are billed by tokens with ModelRatio.
+// 1 === $0.002 / 1K searches
+// https://cohere.com/pricing
+var RerankSearchRatio = map[string]float64{
+	"rerank-v3.5":              1000, // $2 / 1K searches
+	"rerank-english-v3.0":      1000,
+	"rerank-multilingual-v3.0": 1000,
+}
+
+const RerankDocumentsPerSearch = 100
+
+func RerankSearchRatio2JSONString() string {
+	jsonBytes, err := json.Marshal(RerankSearchRatio)
+	if err != nil {
+		logger.SysError("error marshalling rerank search ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateRerankSearchRatioByJSONString(jsonStr string) error {
+	rerankSearchRatioLock.Lock()
+	defer rerankSearchRatioLock.Unlock()
+	RerankSearchRatio = make(map[string]float64)
+	return json.Unmarshal([]byte(jsonStr), &RerankSearchRatio)
+}
+
+// GetRerankSearchRatio returns false if the model is billed by tokens
+func GetRerankSearchRatio(name string, channelType int) (float64, bool) {
+	rerankSearchRatioLock.RLock()
+	defer rerankSearchRatioLock.RUnlock()
+	if ratio, ok := RerankSearchRatio[fmt.Sprintf("%s(%d)", name, channelType)]; ok {
+		return ratio, true
+	}
+	ratio, ok := RerankSearchRatio[name]
+	return ratio, ok
+}
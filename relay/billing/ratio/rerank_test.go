@@ -0,0 +1,60 @@
+package ratio
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/relay/channeltype"
+)
+
+func TestGetRerankSearchRatio(t *testing.T) {
+	Convey("GetRerankSearchRatio", t, func() {
+		original := RerankSearchRatio2JSONString()
+		Reset(func() {
+			So(UpdateRerankSearchRatioByJSONString(original), ShouldBeNil)
+		})
+
+		Convey("default models", func() {
+			cases := []struct {
+				name         string
+				ratio        float64
+				billBySearch bool
+			}{
+				{"rerank-v3.5", 1000, true},
+				{"rerank-english-v3.0", 1000, true},
+				{"rerank-multilingual-v3.0", 1000, true},
+				{"jina-reranker-v2-base-multilingual", 0, false},
+			}
+			for _, tc := range cases {
+				ratio, billBySearch := GetRerankSearchRatio(tc.name, channeltype.Cohere)
+				So(ratio, ShouldEqual, tc.ratio)
+				So(billBySearch, ShouldEqual, tc.billBySearch)
+			}
+		})
+
+		Convey("channel specific ratio takes precedence", func() {
+			So(UpdateRerankSearchRatioByJSONString(`{"rerank-v3.5":1000,"rerank-v3.5(1)":1500}`), ShouldBeNil)
+			ratio, billBySearch := GetRerankSearchRatio("rerank-v3.5", channeltype.OpenAI)
+			So(billBySearch, ShouldBeTrue)
+			So(ratio, ShouldEqual, 1500)
+			ratio, billBySearch = GetRerankSearchRatio("rerank-v3.5", channeltype.Cohere)
+			So(billBySearch, ShouldBeTrue)
+			So(ratio, ShouldEqual, 1000)
+		})
+
+		Convey("updating replaces the whole map", func() {
+			So(UpdateRerankSearchRatioByJSONString(`{"custom-rerank":2}`), ShouldBeNil)
+			_, billBySearch := GetRerankSearchRatio("rerank-v3.5", channeltype.Cohere)
+			So(billBySearch, ShouldBeFalse)
+			ratio, billBySearch := GetRerankSearchRatio("custom-rerank", channeltype.Cohere)
+			So(billBySearch, ShouldBeTrue)
+			So(ratio, ShouldEqual, 2)
+			So(RerankSearchRatio2JSONString(), ShouldEqual, `{"custom-rerank":2}`)
+		})
+
+		Convey("invalid json", func() {
+			So(UpdateRerankSearchRatioByJSONString(`{"custom-rerank":`), ShouldNotBeNil)
+		})
+	})
+}
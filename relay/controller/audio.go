@@ -8,11 +8,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/audio"
 	"github.com/songquanpeng/one-api/common/client"
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/ctxkey"
@@ -39,10 +42,11 @@ func RelayAudioHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 	group := c.GetString(ctxkey.Group)
 	tokenName := c.GetString(ctxkey.TokenName)
 
-	var ttsRequest openai.TextToSpeechRequest
+	var err error
+	ttsRequest := &openai.TextToSpeechRequest{}
 	if relayMode == relaymode.AudioSpeech {
 		// Read JSON
-		err := common.UnmarshalBodyReusable(c, &ttsRequest)
+		ttsRequest, err = common.UnmarshalBodyOnce[openai.TextToSpeechRequest](c)
 		// Check if JSON is valid
 		if err != nil {
 			return openai.ErrorWrapper(err, "invalid_json", http.StatusBadRequest)
@@ -52,19 +56,44 @@ func RelayAudioHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 		if len(ttsRequest.Input) > 4096 {
 			return openai.ErrorWrapper(errors.New("input is too long (over 4096 characters)"), "text_too_long", http.StatusBadRequest)
 		}
+	} else if meta.OriginModelName != "" {
+		audioModel = meta.OriginModelName
 	}
 
 	modelRatio := billingratio.GetModelRatio(audioModel, channelType)
 	groupRatio := billingratio.GetGroupRatio(group)
-	ratio := modelRatio * groupRatio
+	secondRatio, billBySecond := billingratio.GetAudioSecondRatio(audioModel, channelType)
 	var quota int64
 	var preConsumedQuota int64
+	var billedUnit string
+	var duration float64
 	switch relayMode {
 	case relaymode.AudioSpeech:
-		preConsumedQuota = int64(float64(len(ttsRequest.Input)) * ratio)
+		if characterRatio, ok := billingratio.GetAudioCharacterRatio(audioModel, channelType); ok {
+			characters := utf8.RuneCountInString(ttsRequest.Input)
+			modelRatio = characterRatio
+			billedUnit = fmt.Sprintf("%d 字符", characters)
+			preConsumedQuota = int64(float64(characters) * modelRatio * groupRatio)
+		} else {
+			billedUnit = fmt.Sprintf("%d 字节", len(ttsRequest.Input))
+			preConsumedQuota = int64(float64(len(ttsRequest.Input)) * modelRatio * groupRatio)
+		}
 		quota = preConsumedQuota
 	default:
-		preConsumedQuota = int64(float64(config.PreConsumedQuota) * ratio)
+		if billBySecond {
+			duration, err = getAudioDuration(c)
+			if err != nil {
+				logger.Warnf(ctx, "failed to get audio duration, fallback to response: %s", err.Error())
+			}
+		}
+		if duration > 0 {
+			modelRatio = secondRatio
+			preConsumedQuota = getAudioSecondQuota(duration, modelRatio, groupRatio)
+			billedUnit = fmt.Sprintf("%.0f 秒", math.Ceil(duration))
+		} else {
+			preConsumedQuota = int64(float64(config.PreConsumedQuota) * modelRatio * groupRatio)
+		}
+		quota = preConsumedQuota
 	}
 	userQuota, err := model.CacheGetUserQuota(ctx, userId)
 	if err != nil {
@@ -207,7 +236,22 @@ func RelayAudioHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 		if err != nil {
 			return openai.ErrorWrapper(err, "get_text_from_body_err", http.StatusInternalServerError)
 		}
-		quota = int64(openai.CountTokenText(text, audioModel))
+		// the duration measured by the provider is trusted over the headers of the uploaded file
+		if billBySecond && responseFormat == "verbose_json" {
+			if providerDuration, _ := getDurationFromVerboseJSON(responseBody); providerDuration > 0 {
+				duration = providerDuration
+				modelRatio = secondRatio
+				quota = getAudioSecondQuota(duration, modelRatio, groupRatio)
+				billedUnit = fmt.Sprintf("%.0f 秒", math.Ceil(duration))
+			}
+		}
+		if duration == 0 {
+			// the duration is unknown, bill by tokens of the returned text
+			tokens := openai.CountTokenText(text, audioModel)
+			modelRatio = billingratio.GetModelRatio(audioModel, channelType)
+			quota = int64(float64(tokens) * modelRatio * groupRatio)
+			billedUnit = fmt.Sprintf("%d tokens", tokens)
+		}
 		resp.Body = io.NopCloser(bytes.NewBuffer(responseBody))
 	}
 	if resp.StatusCode != http.StatusOK {
@@ -216,7 +260,7 @@ func RelayAudioHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 	succeed = true
 	quotaDelta := quota - preConsumedQuota
 	defer func(ctx context.Context) {
-		go billing.PostConsumeQuota(ctx, tokenId, quotaDelta, quota, userId, channelId, modelRatio, groupRatio, audioModel, tokenName)
+		go billing.PostConsumeQuota(ctx, tokenId, quotaDelta, quota, userId, channelId, modelRatio, groupRatio, audioModel, tokenName, billedUnit)
 	}(c.Request.Context())
 
 	for k, v := range resp.Header {
@@ -235,6 +279,44 @@ func RelayAudioHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 	return nil
 }
 
+// getAudioDuration reads the duration of the uploaded file from its container headers
+func getAudioDuration(c *gin.Context) (float64, error) {
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	}()
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return 0, err
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return 0, err
+	}
+	return audio.GetAudioDuration(data)
+}
+
+// getAudioSecondQuota bills every started second
+func getAudioSecondQuota(duration float64, secondRatio float64, groupRatio float64) int64 {
+	return int64(math.Ceil(duration) * secondRatio * groupRatio)
+}
+
+func getDurationFromVerboseJSON(body []byte) (float64, error) {
+	var whisperResponse openai.WhisperVerboseJSONResponse
+	if err := json.Unmarshal(body, &whisperResponse); err != nil {
+		return 0, fmt.Errorf("unmarshal_response_body_failed err :%w", err)
+	}
+	return whisperResponse.Duration, nil
+}
+
 func getTextFromVTT(body []byte) (string, error) {
 	return getTextFromSRT(body)
 }
@@ -15,20 +15,22 @@ import (
 
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/model"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
 	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
 	"github.com/songquanpeng/one-api/relay/channeltype"
 	"github.com/songquanpeng/one-api/relay/controller/validator"
+	"github.com/songquanpeng/one-api/relay/filter"
 	"github.com/songquanpeng/one-api/relay/meta"
 	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/pii"
 	"github.com/songquanpeng/one-api/relay/relaymode"
 )
 
 func getAndValidateTextRequest(c *gin.Context, relayMode int) (*relaymodel.GeneralOpenAIRequest, error) {
-	textRequest := &relaymodel.GeneralOpenAIRequest{}
-	err := common.UnmarshalBodyReusable(c, textRequest)
+	textRequest, err := common.UnmarshalBodyOnce[relaymodel.GeneralOpenAIRequest](c)
 	if err != nil {
 		return nil, err
 	}
@@ -94,7 +96,7 @@ func preConsumeQuota(ctx context.Context, textRequest *relaymodel.GeneralOpenAIR
 	return preConsumedQuota, nil
 }
 
-func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.Meta, textRequest *relaymodel.GeneralOpenAIRequest, ratio float64, preConsumedQuota int64, modelRatio float64, groupRatio float64, systemPromptReset bool) {
+func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.Meta, textRequest *relaymodel.GeneralOpenAIRequest, ratio float64, preConsumedQuota int64, modelRatio float64, groupRatio float64, systemPromptReset bool, redactor *pii.Redactor) {
 	if usage == nil {
 		logger.Error(ctx, "usage is nil, which is unexpected")
 		return
@@ -123,6 +125,9 @@ func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.M
 		logger.Error(ctx, "error update user quota cache: "+err.Error())
 	}
 	logContent := fmt.Sprintf("倍率：%.2f × %.2f × %.2f", modelRatio, groupRatio, completionRatio)
+	if summary := redactor.Summary(); summary != "" {
+		logContent += fmt.Sprintf("，脱敏：%s", summary)
+	}
 	model.RecordConsumeLog(ctx, &model.Log{
 		UserId:            meta.UserId,
 		ChannelId:         meta.ChannelId,
@@ -135,6 +140,50 @@ func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.M
 		IsStream:          meta.IsStream,
 		ElapsedTime:       helper.CalcElapsedTime(meta.StartTime),
 		SystemPromptReset: systemPromptReset,
+		PiiRedactions:     redactor.Count(),
+	})
+	model.UpdateUserUsedQuotaAndRequestCount(meta.UserId, quota)
+	model.UpdateChannelUsedQuota(meta.ChannelId, quota)
+}
+
+// preConsumeFixedQuota is used when the quota is known before the request is sent
+func preConsumeFixedQuota(ctx context.Context, preConsumedQuota int64, meta *meta.Meta) (int64, *relaymodel.ErrorWithStatusCode) {
+	userQuota, err := model.CacheGetUserQuota(ctx, meta.UserId)
+	if err != nil {
+		return preConsumedQuota, openai.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
+	}
+	if userQuota-preConsumedQuota < 0 {
+		return preConsumedQuota, openai.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
+	}
+	err = model.CacheDecreaseUserQuota(meta.UserId, preConsumedQuota)
+	if err != nil {
+		return preConsumedQuota, openai.ErrorWrapper(err, "decrease_user_quota_failed", http.StatusInternalServerError)
+	}
+	err = model.PreConsumeTokenQuota(meta.TokenId, preConsumedQuota)
+	if err != nil {
+		return preConsumedQuota, openai.ErrorWrapper(err, "pre_consume_token_quota_failed", http.StatusForbidden)
+	}
+	return preConsumedQuota, nil
+}
+
+func postConsumeQuotaWithLog(ctx context.Context, meta *meta.Meta, usage *relaymodel.Usage, quota int64, preConsumedQuota int64, logContent string) {
+	err := model.PostConsumeTokenQuota(meta.TokenId, quota-preConsumedQuota)
+	if err != nil {
+		logger.Error(ctx, "error consuming token remain quota: "+err.Error())
+	}
+	err = model.CacheUpdateUserQuota(ctx, meta.UserId)
+	if err != nil {
+		logger.Error(ctx, "error update user quota cache: "+err.Error())
+	}
+	model.RecordConsumeLog(ctx, &model.Log{
+		UserId:       meta.UserId,
+		ChannelId:    meta.ChannelId,
+		PromptTokens: usage.PromptTokens,
+		ModelName:    meta.ActualModelName,
+		TokenName:    meta.TokenName,
+		Quota:        int(quota),
+		Content:      logContent,
+		ElapsedTime:  helper.CalcElapsedTime(meta.StartTime),
 	})
 	model.UpdateUserUsedQuotaAndRequestCount(meta.UserId, quota)
 	model.UpdateChannelUsedQuota(meta.ChannelId, quota)
@@ -196,3 +245,37 @@ func setSystemPrompt(ctx context.Context, request *relaymodel.GeneralOpenAIReque
 	logger.Infof(ctx, "add system prompt")
 	return true
 }
+
+// applySystemPrompt puts the system prompt of the channel into the request after checking it with the content
+// filter policy of the request, relay modes apply the channel prompt only through here so that it is always checked
+func applySystemPrompt(c *gin.Context, meta *meta.Meta, request *relaymodel.GeneralOpenAIRequest) (reset bool, bizErr *relaymodel.ErrorWithStatusCode) {
+	if bizErr = filterSystemPrompt(c, meta, request); bizErr != nil {
+		return false, bizErr
+	}
+	return setSystemPrompt(c.Request.Context(), request, meta.ForcedSystemPrompt), nil
+}
+
+// filterSystemPrompt checks the system prompt of the channel with the content filter policy of the request,
+// the matches of mask rules are masked in place
+func filterSystemPrompt(c *gin.Context, meta *meta.Meta, request *relaymodel.GeneralOpenAIRequest) *relaymodel.ErrorWithStatusCode {
+	policy, ok := c.Value(ctxkey.FilterPolicy).(*filter.Policy)
+	if !ok || !policy.CheckRequest || meta.ForcedSystemPrompt == "" || len(request.Messages) == 0 {
+		return nil
+	}
+	result := policy.Check(filter.Current(), meta.ForcedSystemPrompt)
+	if !result.Hit() {
+		return nil
+	}
+	model.RecordFilterResult(c.Request.Context(), model.FilterHit{
+		UserId:    meta.UserId,
+		TokenId:   meta.TokenId,
+		TokenName: c.GetString(ctxkey.TokenName),
+		ModelName: meta.OriginModelName,
+		Direction: model.FilterDirectionSystemPrompt,
+	}, result)
+	if result.Action == filter.ActionBlock || result.Action == filter.ActionRoute {
+		return openai.ErrorWrapper(errors.New(policy.ResponseFor(result.Rule.Category)), "content_filter_system_prompt", http.StatusBadRequest)
+	}
+	meta.ForcedSystemPrompt = result.Mask(meta.ForcedSystemPrompt)
+	return nil
+}
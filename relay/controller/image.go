@@ -7,6 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -16,16 +18,18 @@ import (
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/model"
 	"github.com/songquanpeng/one-api/relay"
+	relayadaptor "github.com/songquanpeng/one-api/relay/adaptor"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/apitype"
 	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
 	"github.com/songquanpeng/one-api/relay/channeltype"
 	"github.com/songquanpeng/one-api/relay/meta"
 	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
 )
 
 func getImageRequest(c *gin.Context, _ int) (*relaymodel.ImageRequest, error) {
-	imageRequest := &relaymodel.ImageRequest{}
-	err := common.UnmarshalBodyReusable(c, imageRequest)
+	imageRequest, err := common.UnmarshalBodyOnce[relaymodel.ImageRequest](c)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +107,98 @@ func getImageCostRatio(imageRequest *relaymodel.ImageRequest) (float64, error) {
 	return imageCostRatio, nil
 }
 
+func getImageEditRequest(c *gin.Context, _ int) (*relaymodel.ImageEditRequest, error) {
+	imageEditRequest, err := common.UnmarshalBodyOnce[relaymodel.ImageEditRequest](c)
+	if err != nil {
+		return nil, err
+	}
+	if imageEditRequest.N == 0 {
+		imageEditRequest.N = 1
+	}
+	if imageEditRequest.Size == "" {
+		imageEditRequest.Size = "1024x1024"
+	}
+	if imageEditRequest.Model == "" {
+		imageEditRequest.Model = "dall-e-2"
+	}
+	return imageEditRequest, nil
+}
+
+func validateImageEditRequest(imageEditRequest *relaymodel.ImageEditRequest, relayMode int) *relaymodel.ErrorWithStatusCode {
+	if imageEditRequest.Image == nil {
+		return openai.ErrorWrapper(errors.New("image is required"), "image_missing", http.StatusBadRequest)
+	}
+	if relayMode == relaymode.ImagesEdits {
+		if imageEditRequest.Prompt == "" {
+			return openai.ErrorWrapper(errors.New("prompt is required"), "prompt_missing", http.StatusBadRequest)
+		}
+		if !isValidImagePromptLength(imageEditRequest.Model, len(imageEditRequest.Prompt)) {
+			return openai.ErrorWrapper(errors.New("prompt is too long"), "prompt_too_long", http.StatusBadRequest)
+		}
+	} else if imageEditRequest.Mask != nil {
+		return openai.ErrorWrapper(errors.New("mask is only supported by image edits"), "mask_not_supported", http.StatusBadRequest)
+	}
+	if !isValidImageSize(imageEditRequest.Model, imageEditRequest.Size) {
+		return openai.ErrorWrapper(errors.New("size not supported for this image model"), "size_not_supported", http.StatusBadRequest)
+	}
+	if !isWithinRange(imageEditRequest.Model, imageEditRequest.N) {
+		return openai.ErrorWrapper(errors.New("invalid value of n"), "n_not_within_range", http.StatusBadRequest)
+	}
+	return nil
+}
+
+// rebuildImageEditBody re-encodes the multipart form with the mapped model name,
+// keeping the original boundary so that the Content-Type header stays valid
+func rebuildImageEditBody(c *gin.Context, modelName string) (io.Reader, error) {
+	form := c.Request.MultipartForm
+	if form == nil {
+		return nil, errors.New("multipart form is not parsed")
+	}
+	_, params, err := mime.ParseMediaType(c.Request.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err = writer.SetBoundary(params["boundary"]); err != nil {
+		return nil, err
+	}
+	for key, values := range form.Value {
+		if key == "model" {
+			continue
+		}
+		for _, value := range values {
+			if err = writer.WriteField(key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = writer.WriteField("model", modelName); err != nil {
+		return nil, err
+	}
+	for key, fileHeaders := range form.File {
+		for _, fileHeader := range fileHeaders {
+			part, err := writer.CreatePart(fileHeader.Header)
+			if err != nil {
+				return nil, err
+			}
+			file, err := fileHeader.Open()
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.Copy(part, file)
+			_ = file.Close()
+			if err != nil {
+				return nil, fmt.Errorf("copy %s failed: %w", key, err)
+			}
+		}
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
 func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatusCode {
 	ctx := c.Request.Context()
 	meta := meta.GetByContext(c)
@@ -236,3 +332,122 @@ func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 
 	return nil
 }
+
+func RelayImageEditHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatusCode {
+	ctx := c.Request.Context()
+	meta := meta.GetByContext(c)
+	imageEditRequest, err := getImageEditRequest(c, meta.Mode)
+	if err != nil {
+		logger.Errorf(ctx, "getImageEditRequest failed: %s", err.Error())
+		return openai.ErrorWrapper(err, "invalid_image_request", http.StatusBadRequest)
+	}
+
+	// map model name
+	var isModelMapped bool
+	meta.OriginModelName = imageEditRequest.Model
+	imageEditRequest.Model, isModelMapped = getMappedModelName(imageEditRequest.Model, meta.ModelMapping)
+	meta.ActualModelName = imageEditRequest.Model
+
+	bizErr := validateImageEditRequest(imageEditRequest, relayMode)
+	if bizErr != nil {
+		return bizErr
+	}
+
+	imageCostRatio, err := getImageCostRatio(imageEditRequest.ToImageRequest())
+	if err != nil {
+		return openai.ErrorWrapper(err, "get_image_cost_ratio_failed", http.StatusInternalServerError)
+	}
+	c.Set("response_format", imageEditRequest.ResponseFormat)
+
+	var requestBody io.Reader
+	if isModelMapped {
+		requestBody, err = rebuildImageEditBody(c, imageEditRequest.Model)
+		if err != nil {
+			return openai.ErrorWrapper(err, "rebuild_image_request_failed", http.StatusInternalServerError)
+		}
+	} else {
+		requestBody = c.Request.Body
+	}
+
+	adaptor := relay.GetAdaptor(meta.APIType)
+	if adaptor == nil {
+		return openai.ErrorWrapper(fmt.Errorf("invalid api type: %d", meta.APIType), "invalid_api_type", http.StatusBadRequest)
+	}
+	adaptor.Init(meta)
+
+	// providers with their own image edit api need the request converted
+	if editAdaptor, ok := adaptor.(relayadaptor.ImageEditAdaptor); ok {
+		finalRequest, err := editAdaptor.ConvertImageEditRequest(relayMode, imageEditRequest)
+		if err != nil {
+			return openai.ErrorWrapper(err, "convert_image_request_failed", http.StatusBadRequest)
+		}
+		jsonStr, err := json.Marshal(finalRequest)
+		if err != nil {
+			return openai.ErrorWrapper(err, "marshal_image_request_failed", http.StatusInternalServerError)
+		}
+		requestBody = bytes.NewBuffer(jsonStr)
+	} else if meta.APIType != apitype.OpenAI {
+		return openai.ErrorWrapper(fmt.Errorf("image edits are not supported by channel type %d", meta.ChannelType), "image_edit_not_supported", http.StatusBadRequest)
+	}
+
+	modelRatio := billingratio.GetModelRatio(imageEditRequest.Model, meta.ChannelType)
+	groupRatio := billingratio.GetGroupRatio(meta.Group)
+	ratio := modelRatio * groupRatio
+	userQuota, err := model.CacheGetUserQuota(ctx, meta.UserId)
+	if err != nil {
+		return openai.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
+	}
+
+	var quota int64
+	switch meta.ChannelType {
+	case channeltype.Replicate:
+		// replicate always return 1 image
+		quota = int64(ratio * imageCostRatio * 1000)
+	default:
+		quota = int64(ratio*imageCostRatio*1000) * int64(imageEditRequest.N)
+	}
+
+	if userQuota-quota < 0 {
+		return openai.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
+	}
+
+	// do request
+	resp, err := adaptor.DoRequest(c, meta, requestBody)
+	if err != nil {
+		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
+		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if isErrorHappened(meta, resp) {
+		return RelayErrorHandler(resp)
+	}
+
+	// do response
+	_, respErr := adaptor.DoResponse(c, resp, meta)
+	if respErr != nil {
+		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
+		return respErr
+	}
+
+	err = model.PostConsumeTokenQuota(meta.TokenId, quota)
+	if err != nil {
+		logger.SysError("error consuming token remain quota: " + err.Error())
+	}
+	err = model.CacheUpdateUserQuota(ctx, meta.UserId)
+	if err != nil {
+		logger.SysError("error update user quota cache: " + err.Error())
+	}
+	if quota != 0 {
+		logContent := fmt.Sprintf("倍率：%.2f × %.2f", modelRatio, groupRatio)
+		model.RecordConsumeLog(ctx, &model.Log{
+			UserId:    meta.UserId,
+			ChannelId: meta.ChannelId,
+			ModelName: imageEditRequest.Model,
+			TokenName: c.GetString(ctxkey.TokenName),
+			Quota:     int(quota),
+			Content:   logContent,
+		})
+		model.UpdateUserUsedQuotaAndRequestCount(meta.UserId, quota)
+		model.UpdateChannelUsedQuota(meta.ChannelId, quota)
+	}
+	return nil
+}
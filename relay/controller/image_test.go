@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// newImageEditContext builds a multipart image edit request, files maps a field name to its content
+func newImageEditContext(fields map[string]string, files map[string]string) *gin.Context {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for key, value := range fields {
+		_ = writer.WriteField(key, value)
+	}
+	for key, content := range files {
+		part, _ := writer.CreateFormFile(key, key+".png")
+		_, _ = part.Write([]byte(content))
+	}
+	_ = writer.Close()
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/edits", body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	return c
+}
+
+func TestRebuildImageEditBody(t *testing.T) {
+	Convey("rebuildImageEditBody", t, func() {
+		c := newImageEditContext(map[string]string{
+			"model":  "my-dall-e",
+			"prompt": "a cat",
+			"n":      "2",
+			"size":   "512x512",
+		}, map[string]string{
+			"image": "image-bytes",
+			"mask":  "mask-bytes",
+		})
+		request, err := getImageEditRequest(c, relaymode.ImagesEdits)
+		So(err, ShouldBeNil)
+		So(request.Model, ShouldEqual, "my-dall-e")
+		So(request.Mask, ShouldNotBeNil)
+
+		body, err := rebuildImageEditBody(c, "dall-e-2")
+		So(err, ShouldBeNil)
+
+		// the rebuilt body must parse with the original Content-Type header
+		upstream := httptest.NewRequest(http.MethodPost, "/v1/images/edits", body)
+		upstream.Header.Set("Content-Type", c.Request.Header.Get("Content-Type"))
+		So(upstream.ParseMultipartForm(1<<20), ShouldBeNil)
+		form := upstream.MultipartForm
+		So(form.Value["model"], ShouldResemble, []string{"dall-e-2"})
+		So(form.Value["prompt"], ShouldResemble, []string{"a cat"})
+		So(form.Value["n"], ShouldResemble, []string{"2"})
+		So(form.Value["size"], ShouldResemble, []string{"512x512"})
+		for key, content := range map[string]string{"image": "image-bytes", "mask": "mask-bytes"} {
+			So(form.File[key], ShouldHaveLength, 1)
+			So(form.File[key][0].Filename, ShouldEqual, key+".png")
+			file, err := form.File[key][0].Open()
+			So(err, ShouldBeNil)
+			data, _ := io.ReadAll(file)
+			_ = file.Close()
+			So(string(data), ShouldEqual, content)
+		}
+
+		Convey("fails without a parsed form", func() {
+			c := newImageEditContext(map[string]string{"model": "dall-e-2"}, nil)
+			_, err := rebuildImageEditBody(c, "dall-e-2")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("keeps the boundary", func() {
+			_, params, _ := mime.ParseMediaType(c.Request.Header.Get("Content-Type"))
+			body, err := rebuildImageEditBody(c, "dall-e-2")
+			So(err, ShouldBeNil)
+			data, _ := io.ReadAll(body)
+			So(string(data), ShouldStartWith, "--"+params["boundary"])
+		})
+	})
+}
+
+func TestValidateImageEditRequest(t *testing.T) {
+	Convey("validateImageEditRequest", t, func() {
+		image := map[string]string{"image": "image-bytes"}
+		withMask := map[string]string{"image": "image-bytes", "mask": "mask-bytes"}
+		cases := []struct {
+			name      string
+			relayMode int
+			fields    map[string]string
+			files     map[string]string
+			errCode   string
+		}{
+			{"edit with mask", relaymode.ImagesEdits, map[string]string{"prompt": "a cat"}, withMask, ""},
+			{"variation", relaymode.ImagesVariations, map[string]string{"n": "3"}, image, ""},
+			{"missing image", relaymode.ImagesEdits, map[string]string{"prompt": "a cat"}, map[string]string{"mask": "mask-bytes"}, "invalid_image_request"},
+			{"missing prompt", relaymode.ImagesEdits, nil, image, "prompt_missing"},
+			{"mask on a variation", relaymode.ImagesVariations, nil, withMask, "mask_not_supported"},
+			{"unsupported size", relaymode.ImagesEdits, map[string]string{"prompt": "a cat", "size": "1792x1024"}, image, "size_not_supported"},
+			{"too many images", relaymode.ImagesEdits, map[string]string{"prompt": "a cat", "n": "11"}, withMask, "n_not_within_range"},
+			{"negative n", relaymode.ImagesVariations, map[string]string{"n": "-1"}, image, "n_not_within_range"},
+		}
+		for _, tc := range cases {
+			Convey(tc.name, func() {
+				c := newImageEditContext(tc.fields, tc.files)
+				request, err := getImageEditRequest(c, tc.relayMode)
+				if tc.errCode == "invalid_image_request" {
+					So(err, ShouldNotBeNil)
+					return
+				}
+				So(err, ShouldBeNil)
+				bizErr := validateImageEditRequest(request, tc.relayMode)
+				if tc.errCode == "" {
+					So(bizErr, ShouldBeNil)
+					return
+				}
+				So(bizErr, ShouldNotBeNil)
+				So(bizErr.StatusCode, ShouldEqual, http.StatusBadRequest)
+				So(bizErr.Error.Code, ShouldEqual, tc.errCode)
+			})
+		}
+	})
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/relay"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/billing"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
 	"github.com/songquanpeng/one-api/relay/meta"
 	relaymodel "github.com/songquanpeng/one-api/relay/model"
 )
@@ -24,18 +26,73 @@ func RelayProxyHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 	}
 	adaptor.Init(meta)
 
+	// channels may bill proxied requests per call, or by the usage extracted from the response
+	cfg := meta.Config
+	billByCall := cfg.ProxyQuotaPerCall > 0
+	billByUsage := !billByCall && cfg.IsProxyBillingEnabled()
+	modelName := cfg.ProxyModelName
+	if modelName == "" {
+		modelName = meta.OriginModelName
+	}
+	meta.ActualModelName = modelName
+	groupRatio := billingratio.GetGroupRatio(meta.Group)
+	var modelRatio float64
+	var preConsumedQuota int64
+	var bizErr *relaymodel.ErrorWithStatusCode
+	switch {
+	case billByCall:
+		preConsumedQuota, bizErr = preConsumeFixedQuota(ctx, int64(float64(cfg.ProxyQuotaPerCall)*groupRatio), meta)
+	case billByUsage:
+		if modelName == "" {
+			return openai.ErrorWrapper(fmt.Errorf("model name is required to bill proxied usage"), "model_name_required", http.StatusBadRequest)
+		}
+		modelRatio = billingratio.GetModelRatio(modelName, meta.ChannelType)
+		preConsumedQuota, bizErr = preConsumeQuota(ctx, &relaymodel.GeneralOpenAIRequest{}, 0, modelRatio*groupRatio, meta)
+	}
+	if bizErr != nil {
+		logger.Warnf(ctx, "preConsumeQuota failed: %+v", *bizErr)
+		return bizErr
+	}
+
 	resp, err := adaptor.DoRequest(c, meta, c.Request.Body)
 	if err != nil {
 		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
 		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
 	}
 
 	// do response
-	_, respErr := adaptor.DoResponse(c, resp, meta)
+	usage, respErr := adaptor.DoResponse(c, resp, meta)
 	if respErr != nil {
 		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
 		return respErr
 	}
 
+	// upstream errors are passed through as is and not billed
+	if resp.StatusCode/100 != 2 {
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return nil
+	}
+	switch {
+	case billByCall:
+		quota := int64(float64(cfg.ProxyQuotaPerCall) * groupRatio)
+		logContent := fmt.Sprintf("倍率：%d × %.2f，计费：按次", cfg.ProxyQuotaPerCall, groupRatio)
+		if usage == nil {
+			usage = &relaymodel.Usage{}
+		}
+		go postConsumeQuotaWithLog(ctx, meta, usage, quota, preConsumedQuota, logContent)
+	case billByUsage:
+		if usage == nil || usage.PromptTokens+usage.CompletionTokens == 0 {
+			// the configured paths yield nothing, the request is settled at the quota it would pre-consume
+			// rather than billed as free
+			logger.Warnf(ctx, "no usage found in the proxied response of channel #%d, billed at the pre-consumed quota", meta.ChannelId)
+			quota := getPreConsumedQuota(&relaymodel.GeneralOpenAIRequest{}, 0, modelRatio*groupRatio)
+			logContent := fmt.Sprintf("倍率：%.2f × %.2f，计费：未能提取用量，按预扣额度", modelRatio, groupRatio)
+			go postConsumeQuotaWithLog(ctx, meta, &relaymodel.Usage{}, quota, preConsumedQuota, logContent)
+			return nil
+		}
+		go postConsumeQuota(ctx, usage, meta, &relaymodel.GeneralOpenAIRequest{Model: modelName}, modelRatio*groupRatio, preConsumedQuota, modelRatio, groupRatio, false, nil)
+	}
 	return nil
 }
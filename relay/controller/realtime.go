@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/meta"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+var realtimeUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+	Subprotocols: []string{"realtime"},
+}
+
+type realtimeBilling struct {
+	sync.Mutex
+	ctx              context.Context
+	meta             *meta.Meta
+	modelRatio       float64
+	groupRatio       float64
+	completionRatio  float64
+	audioPrompt      float64
+	audioCompletion  float64
+	promptTokens     int
+	completionTokens int
+	responses        int
+	quota            int64
+}
+
+func (b *realtimeBilling) getQuota(usage *openai.RealtimeUsage) int64 {
+	promptTokens := float64(usage.InputTokenDetails.TextTokens) + float64(usage.InputTokenDetails.AudioTokens)*b.audioPrompt
+	completionTokens := float64(usage.OutputTokenDetails.TextTokens) + float64(usage.OutputTokenDetails.AudioTokens)*b.audioCompletion
+	// old events only carry the totals
+	if usage.InputTokenDetails.TextTokens+usage.InputTokenDetails.AudioTokens == 0 {
+		promptTokens = float64(usage.InputTokens)
+	}
+	if usage.OutputTokenDetails.TextTokens+usage.OutputTokenDetails.AudioTokens == 0 {
+		completionTokens = float64(usage.OutputTokens)
+	}
+	ratio := b.modelRatio * b.groupRatio
+	quota := int64(math.Ceil((promptTokens + completionTokens*b.completionRatio) * ratio))
+	if ratio != 0 && quota <= 0 {
+		quota = 1
+	}
+	return quota
+}
+
+// consume is called on every response.done, an error ends the session
+func (b *realtimeBilling) consume(usage *openai.RealtimeUsage) error {
+	b.Lock()
+	defer b.Unlock()
+	quota := b.getQuota(usage)
+	b.promptTokens += usage.InputTokens
+	b.completionTokens += usage.OutputTokens
+	b.responses++
+	b.quota += quota
+	err := model.PostConsumeTokenQuota(b.meta.TokenId, quota)
+	if err != nil {
+		logger.Error(b.ctx, "error consuming token remain quota: "+err.Error())
+	}
+	err = model.CacheUpdateUserQuota(b.ctx, b.meta.UserId)
+	if err != nil {
+		logger.Error(b.ctx, "error update user quota cache: "+err.Error())
+	}
+	userQuota, err := model.CacheGetUserQuota(b.ctx, b.meta.UserId)
+	if err == nil && userQuota <= 0 {
+		return errors.New("用户额度不足，会话已结束")
+	}
+	token, err := model.GetTokenById(b.meta.TokenId)
+	if err == nil && !token.UnlimitedQuota && token.RemainQuota <= 0 {
+		return errors.New("令牌额度已用尽，会话已结束")
+	}
+	return nil
+}
+
+func (b *realtimeBilling) settle() {
+	b.Lock()
+	defer b.Unlock()
+	if b.responses == 0 {
+		return
+	}
+	logContent := fmt.Sprintf("倍率：%.2f × %.2f × %.2f，音频倍率：%.2f / %.2f，实时会话：%d 次响应", b.modelRatio, b.groupRatio, b.completionRatio, b.audioPrompt, b.audioCompletion, b.responses)
+	model.RecordConsumeLog(b.ctx, &model.Log{
+		UserId:           b.meta.UserId,
+		ChannelId:        b.meta.ChannelId,
+		PromptTokens:     b.promptTokens,
+		CompletionTokens: b.completionTokens,
+		ModelName:        b.meta.ActualModelName,
+		TokenName:        b.meta.TokenName,
+		Quota:            int(b.quota),
+		Content:          logContent,
+		ElapsedTime:      helper.CalcElapsedTime(b.meta.StartTime),
+	})
+	model.UpdateUserUsedQuotaAndRequestCount(b.meta.UserId, b.quota)
+	model.UpdateChannelUsedQuota(b.meta.ChannelId, b.quota)
+}
+
+func RelayRealtimeHelper(c *gin.Context) *relaymodel.ErrorWithStatusCode {
+	ctx := c.Request.Context()
+	meta := meta.GetByContext(c)
+	if meta.ChannelType != channeltype.OpenAI && meta.ChannelType != channeltype.Azure {
+		return openai.ErrorWrapper(fmt.Errorf("realtime is not supported by channel type %d", meta.ChannelType), "realtime_not_supported", http.StatusBadRequest)
+	}
+	if meta.OriginModelName == "" {
+		meta.OriginModelName = c.Query("model")
+	}
+	if meta.OriginModelName == "" {
+		return openai.ErrorWrapper(errors.New("model is required"), "invalid_realtime_request", http.StatusBadRequest)
+	}
+	meta.ActualModelName, _ = getMappedModelName(meta.OriginModelName, meta.ModelMapping)
+
+	// realtime sessions are billed per response, so only make sure the user has some quota left
+	userQuota, err := model.CacheGetUserQuota(ctx, meta.UserId)
+	if err != nil {
+		return openai.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
+	}
+	if userQuota <= 0 {
+		return openai.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
+	}
+
+	// dial upstream before upgrading, so that failures can still be retried on other channels
+	upstreamConn, resp, err := openai.DialRealtime(meta)
+	if err != nil {
+		logger.Errorf(ctx, "DialRealtime failed: %s", err.Error())
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			return RelayErrorHandler(resp)
+		}
+		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	clientConn, err := realtimeUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has already written the error response
+		logger.Errorf(ctx, "upgrade realtime connection failed: %s", err.Error())
+		_ = upstreamConn.Close()
+		return nil
+	}
+
+	session := &realtimeBilling{
+		ctx:             ctx,
+		meta:            meta,
+		modelRatio:      billingratio.GetModelRatio(meta.ActualModelName, meta.ChannelType),
+		groupRatio:      billingratio.GetGroupRatio(meta.Group),
+		completionRatio: billingratio.GetCompletionRatio(meta.ActualModelName, meta.ChannelType),
+		audioPrompt:     billingratio.GetAudioPromptRatio(meta.ActualModelName),
+		audioCompletion: billingratio.GetAudioCompletionRatio(meta.ActualModelName),
+	}
+	err = openai.RealtimeHandler(clientConn, upstreamConn, session.consume)
+	if err != nil {
+		logger.Warnf(ctx, "realtime session ended: %s", err.Error())
+	}
+	session.settle()
+	return nil
+}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/apitype"
+	"github.com/songquanpeng/one-api/relay/billing"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+	"github.com/songquanpeng/one-api/relay/meta"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+func getAndValidateRerankRequest(c *gin.Context) (*relaymodel.RerankRequest, error) {
+	rerankRequest, err := common.UnmarshalBodyOnce[relaymodel.RerankRequest](c)
+	if err != nil {
+		return nil, err
+	}
+	if rerankRequest.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	if rerankRequest.Query == "" {
+		return nil, errors.New("query is required")
+	}
+	if len(rerankRequest.Documents) == 0 {
+		return nil, errors.New("documents is required")
+	}
+	if len(rerankRequest.DocumentTexts()) != len(rerankRequest.Documents) {
+		return nil, errors.New("documents must be strings or objects with a text field")
+	}
+	if rerankRequest.TopN < 0 {
+		return nil, errors.New("top_n must not be negative")
+	}
+	return rerankRequest, nil
+}
+
+func getRerankPromptTokens(rerankRequest *relaymodel.RerankRequest) int {
+	promptTokens := openai.CountTokenText(rerankRequest.Query, rerankRequest.Model)
+	for _, text := range rerankRequest.DocumentTexts() {
+		promptTokens += openai.CountTokenText(text, rerankRequest.Model)
+	}
+	return promptTokens
+}
+
+func getRerankSearchUnits(rerankRequest *relaymodel.RerankRequest) int {
+	return int(math.Ceil(float64(len(rerankRequest.Documents)) / billingratio.RerankDocumentsPerSearch))
+}
+
+// getRerankQuota bills per-search models by search units and per-token models by prompt tokens
+func getRerankQuota(billBySearch bool, searchUnits int, promptTokens int, ratio float64) int64 {
+	if billBySearch {
+		return int64(math.Ceil(float64(searchUnits) * ratio))
+	}
+	quota := int64(math.Ceil(float64(promptTokens) * ratio))
+	if ratio != 0 && quota <= 0 {
+		quota = 1
+	}
+	return quota
+}
+
+func RelayRerankHelper(c *gin.Context) *relaymodel.ErrorWithStatusCode {
+	ctx := c.Request.Context()
+	meta := meta.GetByContext(c)
+	rerankRequest, err := getAndValidateRerankRequest(c)
+	if err != nil {
+		logger.Errorf(ctx, "getAndValidateRerankRequest failed: %s", err.Error())
+		return openai.ErrorWrapper(err, "invalid_rerank_request", http.StatusBadRequest)
+	}
+
+	// cohere and openai compatible channels share the same rerank schema
+	if meta.APIType != apitype.OpenAI && meta.APIType != apitype.Cohere {
+		return openai.ErrorWrapper(fmt.Errorf("rerank is not supported by channel type %d", meta.ChannelType), "rerank_not_supported", http.StatusBadRequest)
+	}
+
+	// map model name
+	var isModelMapped bool
+	meta.OriginModelName = rerankRequest.Model
+	rerankRequest.Model, isModelMapped = getMappedModelName(rerankRequest.Model, meta.ModelMapping)
+	meta.ActualModelName = rerankRequest.Model
+
+	// per-search models are billed up front, per-token models are settled with the returned usage
+	groupRatio := billingratio.GetGroupRatio(meta.Group)
+	searchRatio, billBySearch := billingratio.GetRerankSearchRatio(rerankRequest.Model, meta.ChannelType)
+	modelRatio := searchRatio
+	if !billBySearch {
+		modelRatio = billingratio.GetModelRatio(rerankRequest.Model, meta.ChannelType)
+	}
+	ratio := modelRatio * groupRatio
+	meta.PromptTokens = getRerankPromptTokens(rerankRequest)
+	searchUnits := getRerankSearchUnits(rerankRequest)
+	var preConsumedQuota int64
+	var bizErr *relaymodel.ErrorWithStatusCode
+	if billBySearch {
+		preConsumedQuota, bizErr = preConsumeFixedQuota(ctx, getRerankQuota(true, searchUnits, 0, ratio), meta)
+	} else {
+		preConsumedQuota, bizErr = preConsumeQuota(ctx, &relaymodel.GeneralOpenAIRequest{}, meta.PromptTokens, ratio, meta)
+	}
+	if bizErr != nil {
+		logger.Warnf(ctx, "preConsumeQuota failed: %+v", *bizErr)
+		return bizErr
+	}
+
+	adaptor := relay.GetAdaptor(meta.APIType)
+	if adaptor == nil {
+		return openai.ErrorWrapper(fmt.Errorf("invalid api type: %d", meta.APIType), "invalid_api_type", http.StatusBadRequest)
+	}
+	adaptor.Init(meta)
+
+	var requestBody io.Reader
+	if isModelMapped {
+		jsonStr, err := json.Marshal(rerankRequest)
+		if err != nil {
+			return openai.ErrorWrapper(err, "marshal_rerank_request_failed", http.StatusInternalServerError)
+		}
+		requestBody = bytes.NewBuffer(jsonStr)
+	} else {
+		requestBody = c.Request.Body
+	}
+
+	// do request
+	resp, err := adaptor.DoRequest(c, meta, requestBody)
+	if err != nil {
+		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
+		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if isErrorHappened(meta, resp) {
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return RelayErrorHandler(resp)
+	}
+
+	// do response
+	usage, respErr := adaptor.DoResponse(c, resp, meta)
+	if respErr != nil {
+		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return respErr
+	}
+	if usage == nil {
+		usage = &relaymodel.Usage{PromptTokens: meta.PromptTokens, TotalTokens: meta.PromptTokens}
+	}
+
+	quota := getRerankQuota(billBySearch, searchUnits, usage.PromptTokens, ratio)
+	logContent := fmt.Sprintf("倍率：%.2f × %.2f", modelRatio, groupRatio)
+	if billBySearch {
+		logContent = fmt.Sprintf("倍率：%.2f × %.2f，计费：%d 次搜索", modelRatio, groupRatio, searchUnits)
+	}
+	go postConsumeQuotaWithLog(ctx, meta, usage, quota, preConsumedQuota, logContent)
+	return nil
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+func newRerankContext(body string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/rerank", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return c
+}
+
+func TestGetAndValidateRerankRequest(t *testing.T) {
+	Convey("getAndValidateRerankRequest", t, func() {
+		Convey("accepts string and object documents", func() {
+			request, err := getAndValidateRerankRequest(newRerankContext(`{"model":"rerank-v3.5","query":"q","documents":["a",{"text":"b"}],"top_n":1}`))
+			So(err, ShouldBeNil)
+			So(request.Model, ShouldEqual, "rerank-v3.5")
+			So(request.DocumentTexts(), ShouldResemble, []string{"a", "b"})
+			So(request.TopN, ShouldEqual, 1)
+		})
+
+		Convey("rejects invalid requests", func() {
+			cases := []struct {
+				name string
+				body string
+				err  string
+			}{
+				{"missing model", `{"query":"q","documents":["a"]}`, "model is required"},
+				{"missing query", `{"model":"m","documents":["a"]}`, "query is required"},
+				{"missing documents", `{"model":"m","query":"q"}`, "documents is required"},
+				{"empty documents", `{"model":"m","query":"q","documents":[]}`, "documents is required"},
+				{"number document", `{"model":"m","query":"q","documents":["a",1]}`, "documents must be strings or objects with a text field"},
+				{"object without text", `{"model":"m","query":"q","documents":[{"title":"a"}]}`, "documents must be strings or objects with a text field"},
+				{"negative top_n", `{"model":"m","query":"q","documents":["a"],"top_n":-1}`, "top_n must not be negative"},
+			}
+			for _, tc := range cases {
+				_, err := getAndValidateRerankRequest(newRerankContext(tc.body))
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, tc.err)
+			}
+		})
+
+		Convey("rejects malformed json", func() {
+			_, err := getAndValidateRerankRequest(newRerankContext(`{"model":`))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGetRerankQuota(t *testing.T) {
+	Convey("getRerankSearchUnits", t, func() {
+		cases := []struct {
+			documents   int
+			searchUnits int
+		}{
+			{1, 1},
+			{100, 1},
+			{101, 2},
+			{250, 3},
+		}
+		for _, tc := range cases {
+			request := &relaymodel.RerankRequest{Documents: make([]any, tc.documents)}
+			So(getRerankSearchUnits(request), ShouldEqual, tc.searchUnits)
+		}
+	})
+
+	Convey("getRerankQuota", t, func() {
+		cases := []struct {
+			name         string
+			billBySearch bool
+			searchUnits  int
+			promptTokens int
+			ratio        float64
+			quota        int64
+		}{
+			{"one search", true, 1, 5000, 1000, 1000},
+			{"three searches with group ratio", true, 3, 5000, 1000 * 0.5, 1500},
+			{"fractional search ratio rounds up", true, 3, 0, 0.5, 2},
+			{"tokens ignore search units", false, 3, 200, 0.05, 10},
+			{"tokens round up", false, 1, 3, 0.5, 2},
+			{"at least one quota for a priced model", false, 1, 1, 0.0001, 1},
+			{"free model", false, 1, 100, 0, 0},
+		}
+		for _, tc := range cases {
+			Convey(tc.name, func() {
+				So(getRerankQuota(tc.billBySearch, tc.searchUnits, tc.promptTokens, tc.ratio), ShouldEqual, tc.quota)
+			})
+		}
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/relay"
 	"github.com/songquanpeng/one-api/relay/adaptor"
@@ -20,6 +21,7 @@ import (
 	"github.com/songquanpeng/one-api/relay/channeltype"
 	"github.com/songquanpeng/one-api/relay/meta"
 	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/pii"
 )
 
 func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
@@ -37,8 +39,17 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 	meta.OriginModelName = textRequest.Model
 	textRequest.Model, _ = getMappedModelName(textRequest.Model, meta.ModelMapping)
 	meta.ActualModelName = textRequest.Model
+	// redact pii before the request leaves the gateway
+	redactor := pii.NewRedactor(pii.RulesFor(meta.Group, c.GetString(ctxkey.TokenPiiRules)))
+	if redactor.RedactRequest(textRequest) {
+		meta.RequestRewritten = true
+		logger.Infof(ctx, "pii redacted: %s", redactor.Summary())
+	}
 	// set system prompt if not empty
-	systemPromptReset := setSystemPrompt(ctx, textRequest, meta.ForcedSystemPrompt)
+	systemPromptReset, bizErr := applySystemPrompt(c, meta, textRequest)
+	if bizErr != nil {
+		return bizErr
+	}
 	// get model ratio & group ratio
 	modelRatio := billingratio.GetModelRatio(textRequest.Model, meta.ChannelType)
 	groupRatio := billingratio.GetGroupRatio(meta.Group)
@@ -76,19 +87,31 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 	}
 
 	// do response
+	var restoreWriter *pii.RestoreWriter
+	if config.PiiRedactionRestoreEnabled && redactor.Count() > 0 {
+		restoreWriter = pii.NewRestoreWriter(c.Writer, redactor, meta.IsStream)
+		c.Writer = restoreWriter
+	}
 	usage, respErr := adaptor.DoResponse(c, resp, meta)
+	if restoreWriter != nil {
+		c.Writer = restoreWriter.ResponseWriter
+		if err := restoreWriter.Close(); err != nil {
+			logger.Errorf(ctx, "restore pii in response failed: %s", err.Error())
+		}
+	}
 	if respErr != nil {
 		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
 		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
 		return respErr
 	}
 	// post-consume quota
-	go postConsumeQuota(ctx, usage, meta, textRequest, ratio, preConsumedQuota, modelRatio, groupRatio, systemPromptReset)
+	go postConsumeQuota(ctx, usage, meta, textRequest, ratio, preConsumedQuota, modelRatio, groupRatio, systemPromptReset, redactor)
 	return nil
 }
 
 func getRequestBody(c *gin.Context, meta *meta.Meta, textRequest *model.GeneralOpenAIRequest, adaptor adaptor.Adaptor) (io.Reader, error) {
 	if !config.EnforceIncludeUsage &&
+		!meta.RequestRewritten &&
 		meta.APIType == apitype.OpenAI &&
 		meta.OriginModelName == meta.ActualModelName &&
 		meta.ChannelType != channeltype.Baichuan &&
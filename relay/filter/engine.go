@@ -0,0 +1,275 @@
+package filter
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/king133134/sensfilter"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/pii"
+)
+
+const maskText = "***"
+
+// snippetContext is the number of characters kept on each side of a match in a snippet
+const snippetContext = 20
+
+// snippetMargin is the number of characters around the context that are redacted with it,
+// so that pii reaching into the context is matched as a whole
+const snippetMargin = 64
+
+// Hit is a match of a rule, Start and End are byte offsets in the original text
+type Hit struct {
+	Rule    *Rule
+	Matched string
+	Start   int
+	End     int
+	Snippet string // the match in its context, redacted for the hit log
+}
+
+// Result is the outcome of checking a text
+type Result struct {
+	Hits []*Hit
+	// Action is the strongest action of the hits, and Rule the most severe rule with that action
+	Action string
+	Rule   *Rule
+}
+
+func (r *Result) Hit() bool {
+	return r != nil && len(r.Hits) > 0
+}
+
+// Engine matches text against a set of rules, it is immutable once built
+type Engine struct {
+	rules    []*Rule
+	literals *sensfilter.Search
+	words    map[string][]*Rule // compact literal -> rules
+	pinyin   map[string][]*Rule // the pinyin of chinese literals -> rules
+	patterns []*Rule
+	// maxLiteralLength is the number of characters the longest literal may take in the text
+	maxLiteralLength int
+}
+
+// NewEngine builds an engine, invalid rules are skipped
+func NewEngine(rules []*Rule) *Engine {
+	e := &Engine{words: make(map[string][]*Rule), pinyin: make(map[string][]*Rule)}
+	var words []string
+	addWord := func(word string) {
+		if _, ok := e.words[word]; ok {
+			return
+		}
+		if _, ok := e.pinyin[word]; ok {
+			return
+		}
+		words = append(words, word)
+		// a separator may be inserted after each character
+		e.maxLiteralLength = max(e.maxLiteralLength, 2*utf8.RuneCountInString(word))
+	}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			logger.SysErrorf("invalid filter rule #%d: %s", rule.Id, err.Error())
+			continue
+		}
+		e.rules = append(e.rules, rule)
+		if rule.Type != TypeLiteral {
+			e.patterns = append(e.patterns, rule)
+			continue
+		}
+		word := normalize(rule.Pattern, levelCompact).text
+		addWord(word)
+		e.words[word] = append(e.words[word], rule)
+		if reading := pinyinOf(word); reading != "" {
+			addWord(reading)
+			e.pinyin[reading] = append(e.pinyin[reading], rule)
+		}
+	}
+	if len(words) > 0 {
+		e.literals = sensfilter.Strings(words)
+	}
+	return e
+}
+
+func (e *Engine) Rules() []*Rule {
+	return e.rules
+}
+
+// Check matches the text against all rules
+func (e *Engine) Check(text string) *Result {
+	return e.CheckCategories(text, nil)
+}
+
+// CheckCategories matches the text against the rules of the categories, empty categories means all rules
+func (e *Engine) CheckCategories(text string, categories []string) *Result {
+	result := &Result{}
+	if e == nil || text == "" || len(e.rules) == 0 {
+		return result
+	}
+	var redactor *pii.Redactor
+	addHit := func(rule *Rule, start int, end int) {
+		if redactor == nil {
+			redactor = pii.NewRedactor(pii.AllRules())
+		}
+		hit := &Hit{Rule: rule, Start: start, End: end}
+		hit.Matched = text[hit.Start:hit.End]
+		hit.Snippet = snippet(redactor, text, hit.Start, hit.End)
+		result.Hits = append(result.Hits, hit)
+	}
+	applies := func(rule *Rule) bool {
+		return len(categories) == 0 || slices.Contains(categories, rule.Category)
+	}
+	// literals are matched against the compact text, wildcards against the folded text,
+	// and regular expressions against the base text so that they keep their meaning
+	if e.literals != nil {
+		compact := normalize(text, levelCompact)
+		for _, found := range e.literals.Find([]byte(compact.text)) {
+			start, end := compact.span(found.Start, found.End+1)
+			for _, rule := range e.words[found.Word] {
+				if applies(rule) {
+					addHit(rule, start, end)
+				}
+			}
+			if !onWordBoundary(text, start, end) {
+				continue
+			}
+			for _, rule := range e.pinyin[found.Word] {
+				if applies(rule) {
+					addHit(rule, start, end)
+				}
+			}
+		}
+	}
+	levels := make(map[int]*normalized)
+	for _, rule := range e.patterns {
+		if !applies(rule) {
+			continue
+		}
+		level := levelBase
+		if rule.Type == TypeWildcard {
+			level = levelFold
+		}
+		if levels[level] == nil {
+			levels[level] = normalize(text, level)
+		}
+		n := levels[level]
+		for _, loc := range rule.regexp.FindAllStringIndex(n.text, -1) {
+			if loc[1] > loc[0] {
+				start, end := n.span(loc[0], loc[1])
+				addHit(rule, start, end)
+			}
+		}
+	}
+	for _, hit := range result.Hits {
+		result.take(hit.Rule)
+	}
+	return result
+}
+
+// Merge adds the hits of another result, e.g. of another message of the same request
+func (r *Result) Merge(other *Result) {
+	for _, hit := range other.Hits {
+		r.Hits = append(r.Hits, hit)
+		r.take(hit.Rule)
+	}
+}
+
+func (r *Result) take(rule *Rule) {
+	if r.Rule == nil || actionPriority[rule.Action] > actionPriority[r.Action] ||
+		(rule.Action == r.Action && rule.Severity > r.Rule.Severity) {
+		r.Action = rule.Action
+		r.Rule = rule
+	}
+}
+
+// Mask replaces the text matched by mask rules with ***
+func (r *Result) Mask(text string) string {
+	var ranges [][2]int
+	for _, hit := range r.Hits {
+		if hit.Rule.Action == ActionMask {
+			ranges = append(ranges, [2]int{hit.Start, hit.End})
+		}
+	}
+	if len(ranges) == 0 {
+		return text
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i][0] < ranges[j][0]
+	})
+	var b strings.Builder
+	last := 0
+	for _, rng := range ranges {
+		if rng[1] <= last {
+			continue
+		}
+		if rng[0] >= last {
+			b.WriteString(text[last:rng[0]])
+			b.WriteString(maskText)
+		}
+		last = rng[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// snippet returns the match with the characters around it, the match is masked except its first
+// and last characters, and the pii in the context is redacted
+func snippet(redactor *pii.Redactor, text string, start int, end int) string {
+	// redact a window wider than the context before truncating, so that pii cut by the context
+	// is not left unredacted, and the rest of the text is not scanned for each hit
+	before, cutWindowBefore := lastRunes(text[:start], snippetContext+snippetMargin)
+	after, cutWindowAfter := firstRunes(text[end:], snippetContext+snippetMargin)
+	before, cutBefore := lastRunes(redactor.Redact(before), snippetContext)
+	after, cutAfter := firstRunes(redactor.Redact(after), snippetContext)
+	prefix, suffix := "", ""
+	if cutBefore || cutWindowBefore {
+		prefix = "…"
+	}
+	if cutAfter || cutWindowAfter {
+		suffix = "…"
+	}
+	matched := []rune(text[start:end])
+	for i := range matched {
+		if len(matched) <= 2 || (i > 0 && i < len(matched)-1) {
+			matched[i] = '*'
+		}
+	}
+	return prefix + string(before) + "[" + string(matched) + "]" + string(after) + suffix
+}
+
+// lastRunes returns the last n characters of s, and whether s is longer
+func lastRunes(s string, n int) (string, bool) {
+	i := len(s)
+	for ; n > 0 && i > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(s[:i])
+		i -= size
+	}
+	return s[i:], i > 0
+}
+
+// firstRunes returns the first n characters of s, and whether s is longer
+func firstRunes(s string, n int) (string, bool) {
+	i := 0
+	for ; n > 0 && i < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return s[:i], i < len(s)
+}
+
+var current atomic.Pointer[Engine]
+
+// Load replaces the engine used by the relay
+func Load(rules []*Rule) {
+	current.Store(NewEngine(rules))
+}
+
+// Current returns the engine used by the relay, it has no rules before Load
+func Current() *Engine {
+	if e := current.Load(); e != nil {
+		return e
+	}
+	return &Engine{}
+}
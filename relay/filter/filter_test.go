@@ -0,0 +1,324 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+func TestEngine(t *testing.T) {
+	Convey("Engine", t, func() {
+		engine := NewEngine([]*Rule{
+			{Id: 1, Type: TypeLiteral, Pattern: "BadWord", Category: CategoryDefault, Severity: 1, Action: ActionBlock},
+			{Id: 2, Type: TypeRegex, Pattern: `acme\s*corp`, Category: CategoryBrand, Severity: 1, Action: ActionMask},
+			{Id: 3, Type: TypeWildcard, Pattern: "k?ll*", Category: CategoryViolence, Severity: 2, Action: ActionRoute, RouteModel: "safe-model"},
+			{Id: 4, Type: TypeLiteral, Pattern: "rumor", Category: CategoryPolitics, Severity: 1, Action: ActionLog},
+			{Id: 5, Type: TypeRegex, Pattern: `(`, Category: CategoryDefault, Action: ActionBlock},
+		})
+
+		Convey("skips invalid rules", func() {
+			So(len(engine.Rules()), ShouldEqual, 4)
+		})
+
+		Convey("matches literals case-insensitively and across full-width forms", func() {
+			result := engine.Check("这是 ＢＡＤword 吗")
+			So(result.Hit(), ShouldBeTrue)
+			So(result.Action, ShouldEqual, ActionBlock)
+			So(result.Hits[0].Matched, ShouldEqual, "ＢＡＤword")
+		})
+
+		Convey("masks the matches of mask rules in the original text", func() {
+			text := "Ask ACME  Corp and acmecorp"
+			result := engine.Check(text)
+			So(result.Action, ShouldEqual, ActionMask)
+			So(result.Mask(text), ShouldEqual, "Ask *** and ***")
+		})
+
+		Convey("masks the whole word of a wildcard at the end of a pattern", func() {
+			engine := NewEngine([]*Rule{{Id: 1, Type: TypeWildcard, Pattern: "bad*", Category: CategoryDefault, Action: ActionMask}})
+			text := "a badword here"
+			result := engine.Check(text)
+			So(result.Hits[0].Matched, ShouldEqual, "badword")
+			So(result.Mask(text), ShouldEqual, "a *** here")
+		})
+
+		Convey("takes the strongest action", func() {
+			result := engine.Check("kill the rumor about acme corp")
+			So(result.Action, ShouldEqual, ActionRoute)
+			So(result.Rule.RouteModel, ShouldEqual, "safe-model")
+			So(len(result.Hits), ShouldEqual, 3)
+
+			merged := &Result{}
+			merged.Merge(engine.Check("a rumor"))
+			So(merged.Action, ShouldEqual, ActionLog)
+			merged.Merge(result)
+			So(merged.Action, ShouldEqual, ActionRoute)
+		})
+
+		Convey("keeps a redacted snippet of each hit", func() {
+			result := engine.Check("mail me at foo@example.com about the badword and the rest of this long sentence")
+			So(result.Hits[0].Snippet, ShouldEqual, "…[EMAIL_1] about the [b*****d] and the rest of thi…")
+			So(engine.Check("ok").Hits, ShouldBeEmpty)
+
+			// only the window around the hit is redacted, pii cut by the context is still redacted
+			text := strings.Repeat("中文", 200) + " mail a.very.long.address@example.com badword " + strings.Repeat("x", 200)
+			So(engine.Check(text).Hits[0].Snippet, ShouldEqual, "…中文中文 mail [EMAIL_1] [b*****d] xxxxxxxxxxxxxxxxxxx…")
+		})
+
+		Convey("ignores text without matches", func() {
+			So(engine.Check("hello world").Hit(), ShouldBeFalse)
+			So(engine.Check("").Hit(), ShouldBeFalse)
+		})
+	})
+}
+
+func TestRuleValidate(t *testing.T) {
+	Convey("Rule.Validate", t, func() {
+		So((&Rule{Type: TypeLiteral, Pattern: "a", Category: "default", Action: ActionBlock}).Validate(), ShouldBeNil)
+		So((&Rule{Type: "glob", Pattern: "a", Category: "default", Action: ActionBlock}).Validate(), ShouldNotBeNil)
+		So((&Rule{Type: TypeLiteral, Pattern: " ", Category: "default", Action: ActionBlock}).Validate(), ShouldNotBeNil)
+		So((&Rule{Type: TypeLiteral, Pattern: "a", Category: "Bad Category", Action: ActionBlock}).Validate(), ShouldNotBeNil)
+		So((&Rule{Type: TypeLiteral, Pattern: "a", Category: "default", Action: ActionRoute}).Validate(), ShouldNotBeNil)
+	})
+}
+
+func TestPolicy(t *testing.T) {
+	Convey("Policy", t, func() {
+		So(UpdatePoliciesByJSONString(`{"strict": {"check_request": true, "check_response": true, "response": "blocked"},
+			"brand": {"categories": ["brand"], "check_request": true}}`), ShouldBeNil)
+		So(UpdateGroupPoliciesByJSONString(`{"public": "strict", "redteam": "none"}`), ShouldBeNil)
+		enabled := config.SensitiveFilterEnabled
+		defer func() { config.SensitiveFilterEnabled = enabled }()
+
+		Convey("resolves the token policy over the group policy", func() {
+			config.SensitiveFilterEnabled = false
+			So(PolicyFor("public", ""), ShouldEqual, Policies["strict"])
+			So(PolicyFor("public", "brand"), ShouldEqual, Policies["brand"])
+			So(PolicyFor("public", PolicyNone), ShouldBeNil)
+			So(PolicyFor("redteam", ""), ShouldBeNil)
+			So(PolicyFor("default", ""), ShouldBeNil)
+			config.SensitiveFilterEnabled = true
+			So(PolicyFor("default", ""), ShouldEqual, defaultPolicy)
+			So(PolicyFor("default", "missing"), ShouldEqual, defaultPolicy)
+		})
+
+		Convey("applies only the rules of its categories", func() {
+			engine := NewEngine([]*Rule{
+				{Id: 1, Type: TypeLiteral, Pattern: "acme", Category: CategoryBrand, Action: ActionMask},
+				{Id: 2, Type: TypeLiteral, Pattern: "rumor", Category: CategoryPolitics, Action: ActionBlock},
+			})
+			result := Policies["brand"].Check(engine, "acme rumor")
+			So(len(result.Hits), ShouldEqual, 1)
+			So(result.Action, ShouldEqual, ActionMask)
+			So(Policies["strict"].Check(engine, "acme rumor").Action, ShouldEqual, ActionBlock)
+			So(Policies["strict"].ResponseFor(CategoryPolitics), ShouldEqual, "blocked")
+		})
+
+		Convey("rejects invalid policies", func() {
+			So(UpdatePoliciesByJSONString(`{"none": {}}`), ShouldNotBeNil)
+			So(UpdatePoliciesByJSONString(`{"bad": {"categories": ["Bad Category"]}}`), ShouldNotBeNil)
+			So(ValidatePolicyName("strict"), ShouldBeNil)
+			So(ValidatePolicyName("missing"), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRewriteTextRequest(t *testing.T) {
+	Convey("RewriteTextRequest", t, func() {
+		request := &relaymodel.GeneralOpenAIRequest{
+			Messages: []relaymodel.Message{
+				{Role: "user", Content: []any{map[string]any{"type": "text", "text": "a bad b"}, map[string]any{"type": "image_url"}}},
+				{Role: "assistant", ToolCalls: []relaymodel.Tool{{Function: relaymodel.Function{Arguments: `{"q":"bad"}`}}}},
+				{Role: "tool", Content: "bad result"},
+			},
+			Prompt:      []any{"bad", 1},
+			Input:       "bad",
+			Instruction: "fine",
+		}
+		So(RewriteTextRequest(request, func(text string) string { return strings.ReplaceAll(text, "bad", "***") }), ShouldBeTrue)
+		So(request.Messages[0].Content.([]any)[0].(map[string]any)["text"], ShouldEqual, "a *** b")
+		So(request.Messages[1].ToolCalls[0].Function.Arguments, ShouldEqual, `{"q":"***"}`)
+		So(request.Messages[2].Content, ShouldEqual, "*** result")
+		So(request.Prompt, ShouldResemble, []any{"***", 1})
+		So(request.Input, ShouldEqual, "***")
+		So(RewriteTextRequest(request, func(text string) string { return text }), ShouldBeFalse)
+	})
+}
+
+func TestRewriteJSON(t *testing.T) {
+	Convey("RewriteJSON", t, func() {
+		var document any
+		So(json.Unmarshal([]byte(`{"bad": "a bad b", "list": [1, "bad", {"q": "bad"}], "ok": true}`), &document), ShouldBeNil)
+		So(RewriteJSON(&document, func(text string) string { return strings.ReplaceAll(text, "bad", "***") }), ShouldBeTrue)
+		body, _ := json.Marshal(document)
+		So(string(body), ShouldEqual, `{"bad":"a *** b","list":[1,"***",{"q":"***"}],"ok":true}`)
+		So(RewriteJSON(&document, func(text string) string { return text }), ShouldBeFalse)
+	})
+}
+
+func TestStreamWriter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := NewEngine([]*Rule{
+		{Id: 1, Type: TypeLiteral, Pattern: "acme corp", Category: CategoryBrand, Action: ActionMask},
+		{Id: 2, Type: TypeLiteral, Pattern: "forbidden", Category: CategoryDefault, Action: ActionBlock},
+	})
+	policy := &Policy{CheckResponse: true, Response: "blocked"}
+	stream := func(w *StreamWriter, contents ...string) {
+		for _, content := range contents {
+			_, _ = w.WriteString(`data: {"choices":[{"index":0,"delta":{"content":"` + content + `"}}]}` + "\n\n")
+		}
+		_, _ = w.WriteString(`data: {"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}` + "\n\ndata: [DONE]\n\n")
+	}
+	contentOf := func(body string) string {
+		var text strings.Builder
+		for _, line := range strings.Split(body, "\n") {
+			if i := strings.Index(line, `"content":"`); i >= 0 {
+				rest := line[i+len(`"content":"`):]
+				text.WriteString(rest[:strings.Index(rest, `"`)])
+			}
+		}
+		return text.String()
+	}
+
+	Convey("StreamWriter", t, func() {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+
+		Convey("masks matches split across chunks", func() {
+			w := NewStreamWriter(c.Writer, engine, policy)
+			stream(w, "ask ac", "me co", "rp about the weather, it is sunny and warm today")
+			So(w.Close(), ShouldBeNil)
+			So(contentOf(recorder.Body.String()), ShouldEqual, "ask *** about the weather, it is sunny and warm today")
+			So(w.Result().Action, ShouldEqual, ActionMask)
+			So(len(w.Result().Hits), ShouldEqual, 1)
+			So(recorder.Body.String(), ShouldEndWith, "data: [DONE]\n\n")
+		})
+
+		Convey("ends the stream on a block hit", func() {
+			w := NewStreamWriter(c.Writer, engine, policy)
+			stream(w, "this is forb", "idden", " and more")
+			So(w.Close(), ShouldBeNil)
+			body := recorder.Body.String()
+			So(contentOf(body), ShouldEqual, "blocked")
+			So(body, ShouldContainSubstring, `"finish_reason":"content_filter"`)
+			So(strings.Count(body, "[DONE]"), ShouldEqual, 1)
+			So(w.Result().Action, ShouldEqual, ActionBlock)
+		})
+	})
+}
+
+func TestBypassCorpus(t *testing.T) {
+	data, err := os.ReadFile("testdata/bypass.tsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unquote := func(s string) string {
+		text, err := strconv.Unquote(`"` + s + `"`)
+		if err != nil {
+			t.Fatalf("invalid text %q: %s", s, err.Error())
+		}
+		return text
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			t.Fatalf("line %d: expected 4 fields, got %d", i+1, len(fields))
+		}
+		text, want := unquote(fields[2]), unquote(fields[3])
+		Convey(fmt.Sprintf("Bypass corpus line %d: %s", i+1, fields[2]), t, func() {
+			engine := NewEngine([]*Rule{{Id: 1, Type: fields[0], Pattern: fields[1], Category: CategoryDefault, Action: ActionMask}})
+			So(len(engine.Rules()), ShouldEqual, 1)
+			So(engine.Check(text).Mask(text), ShouldEqual, want)
+		})
+	}
+}
+
+func TestModeration(t *testing.T) {
+	Convey("Moderation", t, func() {
+		config, err := ParseModeration(`{"model": "omni-moderation-latest", "thresholds": {"violence": 0.3}, "user_id": 1}`)
+		So(err, ShouldBeNil)
+		So(config.Type, ShouldEqual, ModerationTypeModeration)
+		So(config.Action, ShouldEqual, ActionBlock)
+
+		Convey("flags by the thresholds and the flags of the api", func() {
+			scores, flagged, err := config.ParseResponse([]byte(`{"results": [{"flagged": true,
+				"categories": {"violence": false, "hate": true, "sexual": false},
+				"category_scores": {"violence": 0.4, "hate": 0.9, "sexual": 0.1}}]}`))
+			So(err, ShouldBeNil)
+			result := config.Evaluate("some text", scores, flagged)
+			So(len(result.Hits), ShouldEqual, 2)
+			So(result.Hits[0].Rule.Category, ShouldEqual, "hate")
+			So(result.Hits[1].Rule.Category, ShouldEqual, "violence")
+			So(result.Hits[1].Snippet, ShouldEqual, "[violence 0.40] some text")
+			So(result.Action, ShouldEqual, ActionBlock)
+		})
+
+		Convey("reads the classification of chat models", func() {
+			chat := &ModerationConfig{Model: "gpt-4o-mini", Type: ModerationTypeChat, UserId: 1}
+			So(chat.Validate(), ShouldBeNil)
+			So(chat.Prompt(), ShouldContainSubstring, "self-harm")
+			scores, flagged, err := chat.ParseResponse([]byte("```json\n{\"violence\": 0.8, \"hate\": 0.2}\n```"))
+			So(err, ShouldBeNil)
+			So(chat.Evaluate("x", scores, flagged).Hits[0].Rule.Category, ShouldEqual, "violence")
+			_, _, err = chat.ParseResponse([]byte("I can not help with that"))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("rejects invalid configs", func() {
+			_, err := ParseModeration(`{"model": "m"}`)
+			So(err, ShouldNotBeNil)
+			_, err = ParseModeration(`{"model": "m", "user_id": 1, "thresholds": {"hate": 2}}`)
+			So(err, ShouldNotBeNil)
+			_, err = ParseModeration(`{"model": "m", "user_id": 1, "action": "mask"}`)
+			So(err, ShouldNotBeNil)
+			config, err := ParseModeration("")
+			So(config, ShouldBeNil)
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+func TestStreamWriterModeration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy := &Policy{CheckResponse: true, Response: "blocked"}
+	Convey("StreamWriter with moderation", t, func() {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		var checked []string
+		w := NewStreamWriter(c.Writer, NewEngine(nil), policy)
+		w.SetModerator(func(text string) *Result {
+			checked = append(checked, text)
+			if strings.Contains(text, "harmful") {
+				rule := &Rule{Type: TypeModeration, Category: "violence", Action: ActionBlock}
+				result := &Result{Hits: []*Hit{{Rule: rule}}}
+				result.take(rule)
+				return result
+			}
+			return &Result{}
+		}, 20)
+		for _, content := range []string{"a harmless beginning ", "of a story, then something ", "harmful happens and the story goes on for a while"} {
+			_, _ = w.WriteString(`data: {"choices":[{"index":0,"delta":{"content":"` + content + `"}}]}` + "\n\n")
+		}
+		_, _ = w.WriteString("data: [DONE]\n\n")
+		So(w.Close(), ShouldBeNil)
+		body := recorder.Body.String()
+		So(body, ShouldNotContainSubstring, "harmful happens")
+		So(body, ShouldContainSubstring, `"finish_reason":"content_filter"`)
+		So(strings.Count(body, "[DONE]"), ShouldEqual, 1)
+		So(len(checked), ShouldBeGreaterThan, 1)
+		So(w.Result().Action, ShouldEqual, ActionBlock)
+	})
+}
@@ -0,0 +1,233 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/pii"
+)
+
+const (
+	ModerationTypeModeration = "moderation" // the /v1/moderations api
+	ModerationTypeChat       = "chat"       // a chat model with a classification prompt
+)
+
+// TypeModeration is the type of the rules of the hits of the moderation model
+const TypeModeration = "moderation"
+
+// defaultModerationThreshold flags the categories of a chat classification without a threshold
+const defaultModerationThreshold = 0.5
+
+// defaultModerationChunkSize is the number of characters of a streamed response checked at once
+const defaultModerationChunkSize = 200
+
+// defaultModerationCategories are classified by chat models when no thresholds are set
+var defaultModerationCategories = []string{"harassment", "hate", "self-harm", "sexual", "violence", "illicit"}
+
+const moderationPrompt = "You are a content moderation classifier. Rate the text of the user in each of these categories: %s. " +
+	"Reply only with a JSON object that maps each category to a score between 0 and 1, the likelihood that the text belongs to it."
+
+// Moderator checks a text with the moderation model, failures are logged and pass the text
+type Moderator func(text string) *Result
+
+// ModerationConfig configures the moderation model, it applies to the requests of the policies that enable it
+type ModerationConfig struct {
+	Model string `json:"model"`
+	Type  string `json:"type"`
+	// Thresholds are the scores from which the categories are flagged, the other categories are flagged
+	// as the moderation api decides, or from 0.5 for chat models
+	Thresholds map[string]float64 `json:"thresholds,omitempty"`
+	Action     string             `json:"action"` // block, route or log
+	RouteModel string             `json:"route_model,omitempty"`
+	ChunkSize  int                `json:"chunk_size,omitempty"` // streamed responses are checked every ChunkSize characters
+	UserId     int                `json:"user_id"`              // the system account the calls are billed to
+}
+
+func (m *ModerationConfig) Validate() error {
+	if m.Model == "" {
+		return errors.New("请指定审核模型")
+	}
+	if m.Type == "" {
+		m.Type = ModerationTypeModeration
+	}
+	if m.Type != ModerationTypeModeration && m.Type != ModerationTypeChat {
+		return fmt.Errorf("未知的审核类型：%s", m.Type)
+	}
+	if m.Action == "" {
+		m.Action = ActionBlock
+	}
+	switch m.Action {
+	case ActionBlock, ActionLog:
+	case ActionRoute:
+		if m.RouteModel == "" {
+			return errors.New("转发需要指定模型")
+		}
+	default:
+		return fmt.Errorf("审核不支持的处理方式：%s", m.Action)
+	}
+	for category, threshold := range m.Thresholds {
+		if threshold < 0 || threshold > 1 {
+			return fmt.Errorf("分类 %s 的阈值必须在 0 到 1 之间", category)
+		}
+	}
+	if m.ChunkSize < 0 {
+		return errors.New("分段长度不能为负数")
+	}
+	if m.ChunkSize == 0 {
+		m.ChunkSize = defaultModerationChunkSize
+	}
+	if m.UserId <= 0 {
+		return errors.New("请指定计费的系统账户")
+	}
+	return nil
+}
+
+// Prompt returns the classification prompt of chat models
+func (m *ModerationConfig) Prompt() string {
+	categories := defaultModerationCategories
+	if len(m.Thresholds) > 0 {
+		categories = make([]string, 0, len(m.Thresholds))
+		for category := range m.Thresholds {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+	}
+	return fmt.Sprintf(moderationPrompt, strings.Join(categories, ", "))
+}
+
+type moderationResponse struct {
+	Results []struct {
+		Categories     map[string]bool    `json:"categories"`
+		CategoryScores map[string]float64 `json:"category_scores"`
+	} `json:"results"`
+}
+
+// ParseResponse reads the scores and the flagged categories from a moderation response,
+// or from the reply of a chat model
+func (m *ModerationConfig) ParseResponse(body []byte) (scores map[string]float64, flagged map[string]bool, err error) {
+	scores, flagged = make(map[string]float64), make(map[string]bool)
+	if m.Type == ModerationTypeChat {
+		// the reply may be wrapped in a code block
+		start, end := strings.Index(string(body), "{"), strings.LastIndex(string(body), "}")
+		if start < 0 || end < start {
+			return nil, nil, fmt.Errorf("invalid classification: %s", body)
+		}
+		if err := json.Unmarshal(body[start:end+1], &scores); err != nil {
+			return nil, nil, fmt.Errorf("invalid classification: %s", body)
+		}
+		for category, score := range scores {
+			flagged[category] = score >= defaultModerationThreshold
+		}
+		return scores, flagged, nil
+	}
+	var response moderationResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, nil, err
+	}
+	// an input of several texts has a result for each
+	for _, result := range response.Results {
+		for category, score := range result.CategoryScores {
+			scores[category] = max(scores[category], score)
+		}
+		for category, isFlagged := range result.Categories {
+			flagged[category] = flagged[category] || isFlagged
+		}
+	}
+	return scores, flagged, nil
+}
+
+// Evaluate turns the flagged categories of a moderation of the text into hits
+func (m *ModerationConfig) Evaluate(text string, scores map[string]float64, flagged map[string]bool) *Result {
+	categories := make([]string, 0, len(scores))
+	for category := range scores {
+		categories = append(categories, category)
+	}
+	for category := range flagged {
+		if _, ok := scores[category]; !ok {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	result := &Result{}
+	for _, category := range categories {
+		hit := flagged[category]
+		if threshold, ok := m.Thresholds[category]; ok {
+			hit = scores[category] >= threshold
+		}
+		if !hit {
+			continue
+		}
+		rule := &Rule{Type: TypeModeration, Category: category, Severity: 1, Action: m.Action, RouteModel: m.RouteModel}
+		result.Hits = append(result.Hits, &Hit{
+			Rule:    rule,
+			Snippet: fmt.Sprintf("[%s %.2f] %s", category, scores[category], moderationSnippet(text)),
+		})
+		result.take(rule)
+	}
+	return result
+}
+
+// moderationSnippet returns the beginning of the text, with the pii redacted
+func moderationSnippet(text string) string {
+	runes := []rune(pii.NewRedactor(pii.AllRules()).Redact(text))
+	if len(runes) > 2*snippetContext {
+		return string(runes[:2*snippetContext]) + "…"
+	}
+	return string(runes)
+}
+
+var moderationLock sync.RWMutex
+
+// moderation is nil when no moderation model is configured
+var moderation *ModerationConfig
+
+// GetModeration returns the moderation config, nil if no moderation model is configured
+func GetModeration() *ModerationConfig {
+	moderationLock.RLock()
+	defer moderationLock.RUnlock()
+	return moderation
+}
+
+func Moderation2JSONString() string {
+	moderationLock.RLock()
+	defer moderationLock.RUnlock()
+	if moderation == nil {
+		return ""
+	}
+	jsonBytes, err := json.Marshal(moderation)
+	if err != nil {
+		logger.SysError("error marshalling moderation config: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+// ParseModeration parses and validates a moderation config, an empty string disables moderation
+func ParseModeration(jsonStr string) (*ModerationConfig, error) {
+	if strings.TrimSpace(jsonStr) == "" {
+		return nil, nil
+	}
+	config := &ModerationConfig{}
+	if err := json.Unmarshal([]byte(jsonStr), config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func UpdateModerationByJSONString(jsonStr string) error {
+	config, err := ParseModeration(jsonStr)
+	if err != nil {
+		return err
+	}
+	moderationLock.Lock()
+	defer moderationLock.Unlock()
+	moderation = config
+	return nil
+}
@@ -0,0 +1,192 @@
+package filter
+
+import (
+	_ "embed"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// the normalization levels, each one does what the previous one does
+const (
+	// levelBase lower-cases the text, converts full-width characters to half-width ones and traditional
+	// chinese to simplified chinese, and removes invisible characters such as zero-width spaces
+	levelBase = iota
+	// levelFold also removes diacritics and maps homoglyphs and leetspeak to latin letters
+	levelFold
+	// levelCompact also removes spaces, punctuation and symbols, so that "b.a d" is "bad"
+	levelCompact
+)
+
+// normalized is a normalized text, each of its bytes keeps the span of the character of the original
+// text it comes from, so that matches can be mapped back to the original text
+type normalized struct {
+	text   string
+	starts []int
+	ends   []int
+}
+
+// span returns the span in the original text of the bytes [start, end) of the normalized text
+func (n *normalized) span(start int, end int) (int, int) {
+	return n.starts[start], n.ends[end-1]
+}
+
+func normalize(text string, level int) *normalized {
+	n := &normalized{
+		starts: make([]int, 0, len(text)),
+		ends:   make([]int, 0, len(text)),
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	for start := 0; start < len(text); {
+		ch, size := utf8.DecodeRuneInString(text[start:])
+		end := start + size
+		for _, r := range expandRune(ch, level) {
+			r, ok := normalizeRune(r, level)
+			if !ok {
+				continue
+			}
+			for i := utf8.RuneLen(r); i > 0; i-- {
+				n.starts = append(n.starts, start)
+				n.ends = append(n.ends, end)
+			}
+			b.WriteRune(r)
+		}
+		start = end
+	}
+	n.text = b.String()
+	return n
+}
+
+// expandRune converts a full-width character to its half-width form, and from levelFold on decomposes
+// the character, e.g. "é" to "e" and a combining acute accent, "ﬁ" to "fi" and "𝐛" to "b"
+func expandRune(ch rune, level int) []rune {
+	switch {
+	case ch == '　':
+		ch = ' '
+	case ch >= '！' && ch <= '～':
+		ch -= 0xfee0
+	}
+	if level < levelFold || ch < utf8.RuneSelf {
+		return []rune{ch}
+	}
+	if norm.NFKD.PropertiesString(string(ch)).Decomposition() == nil {
+		return []rune{ch}
+	}
+	return []rune(norm.NFKD.String(string(ch)))
+}
+
+// normalizeRune returns the normalized form of a character, or false if the character is removed
+func normalizeRune(ch rune, level int) (rune, bool) {
+	ch = unicode.ToLower(ch)
+	if unicode.Is(unicode.Cf, ch) || unicode.Is(unicode.Variation_Selector, ch) {
+		return 0, false
+	}
+	if simplified, ok := traditionalToSimplified[ch]; ok {
+		ch = simplified
+	}
+	if level < levelFold {
+		return ch, true
+	}
+	if unicode.In(ch, unicode.Mn, unicode.Me) {
+		return 0, false
+	}
+	if confusable, ok := confusables[ch]; ok {
+		ch = confusable
+	}
+	if level < levelCompact {
+		return ch, true
+	}
+	if unicode.IsSpace(ch) || unicode.IsPunct(ch) || unicode.IsSymbol(ch) || unicode.IsControl(ch) {
+		return 0, false
+	}
+	return ch, true
+}
+
+// confusables maps the characters that look alike or are swapped for each other to one of them,
+// 1, l, | and ! are all i
+var confusables = map[rune]rune{
+	// leetspeak
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't',
+	'@': 'a', '$': 's', '!': 'i', '|': 'i', 'l': 'i',
+	// cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'і': 'i', 'ј': 'j', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'һ': 'h', 'ԁ': 'd', 'ԛ': 'q',
+	'ԝ': 'w', 'ӏ': 'i',
+	// greek
+	'α': 'a', 'β': 'b', 'γ': 'y', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	// latin
+	'ı': 'i', 'ȷ': 'j', 'ɑ': 'a', 'ɡ': 'g',
+}
+
+// t2s.txt has a line for each simplified character followed by its traditional forms,
+// generated with the Traditional-Simplified transform of ICU
+//
+//go:embed dict/t2s.txt
+var t2sDict string
+
+// pinyin.txt has a line for each pinyin followed by its characters, the characters of GB 2312
+// with their most common reading, generated with the Han-Latin transform of ICU
+//
+//go:embed dict/pinyin.txt
+var pinyinDict string
+
+var traditionalToSimplified = make(map[rune]rune)
+
+var pinyin = make(map[rune]string)
+
+func init() {
+	for _, line := range strings.Split(t2sDict, "\n") {
+		simplified, traditional, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		ch, _ := utf8.DecodeRuneInString(simplified)
+		for _, t := range traditional {
+			traditionalToSimplified[t] = ch
+		}
+	}
+	for _, line := range strings.Split(pinyinDict, "\n") {
+		reading, chars, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		for _, ch := range chars {
+			pinyin[ch] = reading
+		}
+	}
+}
+
+// pinyinOf returns the pinyin of a compact literal with at least two chinese characters, in which
+// the other characters are kept, or "" if the literal has fewer chinese characters
+func pinyinOf(word string) string {
+	var b strings.Builder
+	count := 0
+	for _, ch := range word {
+		if reading, ok := pinyin[ch]; ok {
+			b.WriteString(reading)
+			count++
+		} else {
+			b.WriteRune(ch)
+		}
+	}
+	if count < 2 {
+		return ""
+	}
+	return normalize(b.String(), levelCompact).text
+}
+
+// onWordBoundary reports whether the span is not within a latin word of the text,
+// so that the pinyin of a literal is not found in english words
+func onWordBoundary(text string, start int, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:start])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	return !isWordRune(before) && !isWordRune(after)
+}
+
+func isWordRune(ch rune) bool {
+	return ch != utf8.RuneError && unicode.IsLetter(ch) && !unicode.Is(unicode.Han, ch)
+}
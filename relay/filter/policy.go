@@ -0,0 +1,148 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// PolicyNone disables filtering when bound to a group or a token
+const PolicyNone = "none"
+
+// Policy decides how the rules are applied to the requests of a group or a token
+type Policy struct {
+	Categories    []string `json:"categories,omitempty"` // the categories whose rules apply, empty applies all
+	CheckRequest  bool     `json:"check_request"`
+	CheckResponse bool     `json:"check_response"`
+	Response      string   `json:"response,omitempty"`   // returned when blocked, overrides the category responses
+	Moderation    bool     `json:"moderation,omitempty"` // check with the moderation model as well
+}
+
+// defaultPolicy applies all rules to requests and responses, it follows SensitiveFilterEnabled
+var defaultPolicy = &Policy{CheckRequest: true, CheckResponse: true}
+
+func (p *Policy) Validate() error {
+	for _, category := range p.Categories {
+		if !categoryPattern.MatchString(category) {
+			return fmt.Errorf("无效的分类：%s", category)
+		}
+	}
+	return nil
+}
+
+// Check matches the text against the rules of the policy's categories
+func (p *Policy) Check(engine *Engine, text string) *Result {
+	return engine.CheckCategories(text, p.Categories)
+}
+
+// ResponseFor returns the text returned when a request is blocked by a rule of the category
+func (p *Policy) ResponseFor(category string) string {
+	if p.Response != "" {
+		return p.Response
+	}
+	return ResponseFor(category)
+}
+
+var policiesLock sync.RWMutex
+
+// Policies are the named policies, e.g. {"strict": {"check_request": true, "check_response": true}}
+var Policies = map[string]*Policy{}
+
+// GroupPolicies binds policies to groups, e.g. {"redteam": "none", "default": "strict"}
+var GroupPolicies = map[string]string{}
+
+func Policies2JSONString() string {
+	policiesLock.RLock()
+	defer policiesLock.RUnlock()
+	jsonBytes, err := json.Marshal(Policies)
+	if err != nil {
+		logger.SysError("error marshalling filter policies: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdatePoliciesByJSONString(jsonStr string) error {
+	policies := make(map[string]*Policy)
+	if err := json.Unmarshal([]byte(jsonStr), &policies); err != nil {
+		return err
+	}
+	for name, policy := range policies {
+		if name == "" || name == PolicyNone {
+			return fmt.Errorf("无效的策略名称：%s", name)
+		}
+		if policy == nil {
+			return fmt.Errorf("策略 %s 不能为空", name)
+		}
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+	}
+	policiesLock.Lock()
+	defer policiesLock.Unlock()
+	Policies = policies
+	return nil
+}
+
+func GroupPolicies2JSONString() string {
+	policiesLock.RLock()
+	defer policiesLock.RUnlock()
+	jsonBytes, err := json.Marshal(GroupPolicies)
+	if err != nil {
+		logger.SysError("error marshalling filter group policies: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+// UpdateGroupPoliciesByJSONString does not check the policy names,
+// as the options may be loaded before the policies, use ValidatePolicyName for that
+func UpdateGroupPoliciesByJSONString(jsonStr string) error {
+	groupPolicies := make(map[string]string)
+	if err := json.Unmarshal([]byte(jsonStr), &groupPolicies); err != nil {
+		return err
+	}
+	policiesLock.Lock()
+	defer policiesLock.Unlock()
+	GroupPolicies = groupPolicies
+	return nil
+}
+
+// ValidatePolicyName checks that the policy exists, an empty name follows the group
+func ValidatePolicyName(name string) error {
+	if name == "" || name == PolicyNone {
+		return nil
+	}
+	policiesLock.RLock()
+	defer policiesLock.RUnlock()
+	if _, ok := Policies[name]; !ok {
+		return fmt.Errorf("过滤策略 %s 不存在", name)
+	}
+	return nil
+}
+
+// PolicyFor resolves the policy of a request, the policy of the token overrides that of the group,
+// which overrides the default policy, nil means no filtering
+func PolicyFor(group string, tokenPolicy string) *Policy {
+	policiesLock.RLock()
+	defer policiesLock.RUnlock()
+	name := GroupPolicies[group]
+	if tokenPolicy != "" {
+		name = tokenPolicy
+	}
+	switch name {
+	case "":
+		if !config.SensitiveFilterEnabled {
+			return nil
+		}
+		return defaultPolicy
+	case PolicyNone:
+		return nil
+	}
+	if policy, ok := Policies[name]; ok {
+		return policy
+	}
+	logger.SysErrorf("filter policy %s not found, using the default policy", name)
+	return defaultPolicy
+}
@@ -0,0 +1,109 @@
+package filter
+
+import (
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// RewriteTextRequest calls rewrite with each text of a chat, completion, embedding, moderation or edit request,
+// which are the message contents and text parts (including tool results), tool call arguments, prompt,
+// input and instruction, and puts back what it returns. It reports whether any text was changed.
+func RewriteTextRequest(request *relaymodel.GeneralOpenAIRequest, rewrite func(string) string) bool {
+	changed := false
+	apply := func(text string) string {
+		newText := rewrite(text)
+		if newText != text {
+			changed = true
+		}
+		return newText
+	}
+	for i := range request.Messages {
+		message := &request.Messages[i]
+		message.Content = rewriteContent(message.Content, apply)
+		for j := range message.ToolCalls {
+			if arguments, ok := message.ToolCalls[j].Function.Arguments.(string); ok {
+				message.ToolCalls[j].Function.Arguments = apply(arguments)
+			}
+		}
+	}
+	request.Prompt = rewriteContent(request.Prompt, apply)
+	request.Input = rewriteContent(request.Input, apply)
+	if request.Instruction != "" {
+		request.Instruction = apply(request.Instruction)
+	}
+	return changed
+}
+
+// RewriteRerankRequest rewrites the query and the documents of a rerank request
+func RewriteRerankRequest(request *relaymodel.RerankRequest, rewrite func(string) string) bool {
+	changed := false
+	apply := func(text string) string {
+		newText := rewrite(text)
+		if newText != text {
+			changed = true
+		}
+		return newText
+	}
+	request.Query = apply(request.Query)
+	for i, document := range request.Documents {
+		switch v := document.(type) {
+		case string:
+			request.Documents[i] = apply(v)
+		case map[string]any:
+			if text, ok := v["text"].(string); ok {
+				v["text"] = apply(text)
+			}
+		}
+	}
+	return changed
+}
+
+// rewriteContent rewrites a string, a list of strings or a list of content parts, other values are kept
+func rewriteContent(content any, apply func(string) string) any {
+	switch value := content.(type) {
+	case string:
+		return apply(value)
+	case []any:
+		for i, item := range value {
+			switch part := item.(type) {
+			case string:
+				value[i] = apply(part)
+			case map[string]any:
+				if part["type"] != relaymodel.ContentTypeText {
+					continue
+				}
+				if text, ok := part["text"].(string); ok {
+					part["text"] = apply(text)
+				}
+			}
+		}
+	}
+	return content
+}
+
+// RewriteJSON rewrites every string value of a decoded json document of an unknown api, e.g. of the proxy
+// relay mode, object keys are kept
+func RewriteJSON(document *any, rewrite func(string) string) bool {
+	changed := false
+	var walk func(value any) any
+	walk = func(value any) any {
+		switch v := value.(type) {
+		case string:
+			newText := rewrite(v)
+			if newText != v {
+				changed = true
+			}
+			return newText
+		case map[string]any:
+			for key, item := range v {
+				v[key] = walk(item)
+			}
+		case []any:
+			for i, item := range v {
+				v[i] = walk(item)
+			}
+		}
+		return value
+	}
+	*document = walk(*document)
+	return changed
+}
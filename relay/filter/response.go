@@ -0,0 +1,46 @@
+package filter
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+var categoryResponsesLock sync.RWMutex
+
+// CategoryResponses is the text returned when a request is blocked by a rule of the category,
+// e.g. {"politics": "该问题暂不支持讨论"}, categories without a response use SensitiveFilterResponse
+var CategoryResponses = map[string]string{}
+
+func CategoryResponses2JSONString() string {
+	categoryResponsesLock.RLock()
+	defer categoryResponsesLock.RUnlock()
+	jsonBytes, err := json.Marshal(CategoryResponses)
+	if err != nil {
+		logger.SysError("error marshalling filter category responses: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateCategoryResponsesByJSONString(jsonStr string) error {
+	categoryResponses := make(map[string]string)
+	if err := json.Unmarshal([]byte(jsonStr), &categoryResponses); err != nil {
+		return err
+	}
+	categoryResponsesLock.Lock()
+	defer categoryResponsesLock.Unlock()
+	CategoryResponses = categoryResponses
+	return nil
+}
+
+// ResponseFor returns the response of the category
+func ResponseFor(category string) string {
+	categoryResponsesLock.RLock()
+	defer categoryResponsesLock.RUnlock()
+	if response, ok := CategoryResponses[category]; ok && response != "" {
+		return response
+	}
+	return config.SensitiveFilterResponse
+}
@@ -0,0 +1,108 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	TypeLiteral  = "literal"
+	TypeRegex    = "regex"
+	TypeWildcard = "wildcard" // * matches characters within a word, ? matches one character
+)
+
+const (
+	ActionBlock = "block" // reject with the response of the category
+	ActionRoute = "route" // relay the request to RouteModel instead
+	ActionMask  = "mask"  // replace the matched text with ***
+	ActionLog   = "log"   // only record the hit
+)
+
+// the built-in categories, other names can be used as well
+const (
+	CategoryPolitics = "politics"
+	CategoryViolence = "violence"
+	CategoryPii      = "pii"
+	CategoryBrand    = "brand"
+	CategoryDefault  = "default"
+)
+
+// Rule is a compiled filter rule, the patterns are matched against normalized text, so they are
+// case-insensitive, full-width characters match their half-width forms and traditional chinese
+// matches simplified chinese. Literals and wildcards also match through diacritics, homoglyphs
+// and leetspeak, and literals through separators and the pinyin of their chinese characters.
+type Rule struct {
+	Id         int
+	Type       string
+	Pattern    string
+	Category   string
+	Severity   int
+	Action     string
+	RouteModel string
+	regexp     *regexp.Regexp
+}
+
+var categoryPattern = regexp.MustCompile(`^[a-z0-9_\-]{1,32}$`)
+
+// Validate checks the rule and compiles its pattern
+func (r *Rule) Validate() error {
+	if strings.TrimSpace(r.Pattern) == "" {
+		return errors.New("规则内容不能为空")
+	}
+	if !categoryPattern.MatchString(r.Category) {
+		return errors.New("分类只能包含小写字母、数字、下划线和短横线，长度不超过 32")
+	}
+	switch r.Action {
+	case ActionBlock, ActionMask, ActionLog:
+	case ActionRoute:
+		if r.RouteModel == "" {
+			return errors.New("转发规则需要指定模型")
+		}
+	default:
+		return fmt.Errorf("未知的处理方式：%s", r.Action)
+	}
+	switch r.Type {
+	case TypeLiteral:
+		if normalize(r.Pattern, levelCompact).text == "" {
+			return errors.New("关键词不能只包含空白、标点和符号")
+		}
+		r.regexp = nil
+		return nil
+	case TypeRegex:
+		re, err := regexp.Compile("(?i)" + r.Pattern)
+		if err != nil {
+			return fmt.Errorf("正则表达式无效：%s", err.Error())
+		}
+		r.regexp = re
+	case TypeWildcard:
+		r.regexp = wildcardToRegexp(normalize(r.Pattern, levelFold).text)
+	default:
+		return fmt.Errorf("未知的规则类型：%s", r.Type)
+	}
+	return nil
+}
+
+func wildcardToRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	for _, ch := range pattern {
+		switch ch {
+		case '*':
+			b.WriteString(`\S*`)
+		case '?':
+			b.WriteString(`.`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	return regexp.MustCompile(b.String())
+}
+
+// actionPriority orders the actions by strength, the strongest action of the hits is taken
+var actionPriority = map[string]int{
+	ActionLog:   1,
+	ActionMask:  2,
+	ActionRoute: 3,
+	ActionBlock: 4,
+}
@@ -0,0 +1,283 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamHoldback is the least number of characters held back at the end of a streamed text,
+// longer literals raise it so that a match split across chunks is still found
+const streamHoldback = 16
+
+// StreamWriter filters a chat or completion stream before it reaches the client. The end of each delta
+// is held back until it can no longer be the beginning of a match, the matches of mask rules are masked,
+// and a block or route hit ends the stream with the response of the policy.
+type StreamWriter struct {
+	gin.ResponseWriter
+	engine   *Engine
+	policy   *Policy
+	buffer   bytes.Buffer
+	pending  map[float64]string // held back text per choice index
+	textKey  string             // the key of the streamed text, content of delta or text of the choice
+	holdback int
+	result   *Result // the hits of the text that has been written
+	blocked  bool
+	// the moderation of the text that passed the rules, per choice index
+	moderate    Moderator
+	chunkSize   int
+	unmoderated map[float64]string
+}
+
+func NewStreamWriter(w gin.ResponseWriter, engine *Engine, policy *Policy) *StreamWriter {
+	return &StreamWriter{
+		ResponseWriter: w,
+		engine:         engine,
+		policy:         policy,
+		pending:        make(map[float64]string),
+		holdback:       max(streamHoldback, engine.maxLiteralLength),
+		result:         &Result{},
+	}
+}
+
+// SetModerator checks the text with the moderation model as well, in chunks of chunkSize characters,
+// each chunk is held back until it has been checked
+func (w *StreamWriter) SetModerator(moderate Moderator, chunkSize int) {
+	w.moderate = moderate
+	w.chunkSize = chunkSize
+	w.unmoderated = make(map[float64]string)
+}
+
+// Result returns the hits of the stream, the stream has been blocked if the action is block or route
+func (w *StreamWriter) Result() *Result {
+	return w.result
+}
+
+func (w *StreamWriter) Write(data []byte) (int, error) {
+	if w.blocked {
+		return len(data), nil
+	}
+	w.buffer.Write(data)
+	if err := w.writeLines(); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (w *StreamWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Close writes what is left, it must be called once the response is complete
+func (w *StreamWriter) Close() error {
+	if w.blocked {
+		return nil
+	}
+	if w.buffer.Len() > 0 {
+		w.buffer.WriteByte('\n')
+		if err := w.writeLines(); err != nil {
+			return err
+		}
+	}
+	if held := w.flushPending(); len(held) > 0 {
+		if _, err := w.ResponseWriter.Write(held); err != nil {
+			return err
+		}
+	}
+	w.ResponseWriter.Flush()
+	return nil
+}
+
+func (w *StreamWriter) writeLines() error {
+	for !w.blocked {
+		data := w.buffer.Bytes()
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return nil
+		}
+		line := w.filterLine(string(data[:i+1]))
+		w.buffer.Next(i + 1)
+		if _, err := w.ResponseWriter.WriteString(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *StreamWriter) filterLine(line string) string {
+	payload := strings.TrimSpace(line)
+	if !strings.HasPrefix(payload, "data:") {
+		return line
+	}
+	payload = strings.TrimSpace(strings.TrimPrefix(payload, "data:"))
+	if payload == "[DONE]" {
+		held := w.flushPending()
+		if w.blocked {
+			return string(held)
+		}
+		return string(held) + line
+	}
+	var chunk map[string]any
+	if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+		return line
+	}
+	choices, _ := chunk["choices"].([]any)
+	changed := false
+	for _, item := range choices {
+		choice, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		index, _ := choice["index"].(float64)
+		finished := choice["finish_reason"] != nil
+		object, key := choice, "text"
+		if delta, ok := choice["delta"].(map[string]any); ok {
+			object, key = delta, "content"
+		} else if _, ok := choice["text"]; !ok {
+			continue
+		}
+		w.textKey = key
+		text, _ := object[key].(string)
+		filtered, rule := w.filterText(index, text, finished)
+		if rule != nil {
+			w.blocked = true
+			return w.blockChunk(chunk, index, rule)
+		}
+		if filtered != text {
+			object[key] = filtered
+			changed = true
+		}
+	}
+	if !changed {
+		return line
+	}
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return line
+	}
+	return "data: " + string(data) + "\n"
+}
+
+// filterText returns the part of the pending and streamed text that can be written, with the matches of
+// mask rules masked, or the rule that blocks the stream
+func (w *StreamWriter) filterText(index float64, text string, finished bool) (string, *Rule) {
+	text = w.pending[index] + text
+	if text == "" {
+		return w.moderateText(index, "", finished)
+	}
+	result := w.policy.Check(w.engine, text)
+	if result.Action == ActionBlock || result.Action == ActionRoute {
+		w.result.Merge(result)
+		return "", result.Rule
+	}
+	split := len(text)
+	if !finished {
+		split = holdbackOffset(text, w.holdback)
+		// a match must not be split, hold it back as a whole
+		for _, hit := range result.Hits {
+			if hit.Start < split && hit.End > split {
+				split = hit.Start
+			}
+		}
+	}
+	written := &Result{}
+	for _, hit := range result.Hits {
+		if hit.End <= split {
+			written.Hits = append(written.Hits, hit)
+		}
+	}
+	w.result.Merge(written)
+	w.pending[index] = text[split:]
+	return w.moderateText(index, written.Mask(text[:split]), finished)
+}
+
+// moderateText returns the text once a chunk of it has been checked by the moderation model,
+// or the rule that blocks the stream
+func (w *StreamWriter) moderateText(index float64, text string, finished bool) (string, *Rule) {
+	if w.moderate == nil {
+		return text, nil
+	}
+	text = w.unmoderated[index] + text
+	if text == "" || (!finished && utf8.RuneCountInString(text) < w.chunkSize) {
+		w.unmoderated[index] = text
+		return "", nil
+	}
+	delete(w.unmoderated, index)
+	result := w.moderate(text)
+	w.result.Merge(result)
+	if result.Action == ActionBlock || result.Action == ActionRoute {
+		return "", result.Rule
+	}
+	return text, nil
+}
+
+// holdbackOffset returns the byte offset of the last n characters of the text
+func holdbackOffset(text string, n int) int {
+	offset := len(text)
+	for ; n > 0 && offset > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(text[:offset])
+		offset -= size
+	}
+	return offset
+}
+
+// blockChunk ends the stream with a chunk of the response of the policy
+func (w *StreamWriter) blockChunk(chunk map[string]any, index float64, rule *Rule) string {
+	choice := map[string]any{"index": index, "finish_reason": "content_filter"}
+	response := w.policy.ResponseFor(rule.Category)
+	if w.textKey == "text" {
+		choice["text"] = response
+	} else {
+		choice["delta"] = map[string]any{"content": response}
+	}
+	chunk["choices"] = []any{choice}
+	delete(chunk, "usage")
+	data, _ := json.Marshal(chunk)
+	return "data: " + string(data) + "\n\ndata: [DONE]\n\n"
+}
+
+// flushPending returns an extra chunk with the held back text, for streams that end without a finish reason
+func (w *StreamWriter) flushPending() []byte {
+	var buf bytes.Buffer
+	for _, index := range w.heldIndexes() {
+		held := w.pending[index]
+		delete(w.pending, index)
+		text, rule := w.filterText(index, held, true)
+		if rule != nil {
+			w.blocked = true
+			buf.WriteString(w.blockChunk(map[string]any{}, index, rule))
+			return buf.Bytes()
+		}
+		if text == "" {
+			continue
+		}
+		choice := map[string]any{"index": index, "delta": map[string]any{"content": text}}
+		if w.textKey == "text" {
+			choice = map[string]any{"index": index, "text": text}
+		}
+		data, _ := json.Marshal(map[string]any{"choices": []any{choice}})
+		buf.WriteString("data: " + string(data) + "\n\n")
+	}
+	return buf.Bytes()
+}
+
+// heldIndexes returns the indexes of the choices with text held back
+func (w *StreamWriter) heldIndexes() []float64 {
+	var indexes []float64
+	for index, held := range w.pending {
+		if held != "" {
+			indexes = append(indexes, index)
+		}
+	}
+	for index, held := range w.unmoderated {
+		if held != "" && w.pending[index] == "" {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Float64s(indexes)
+	return indexes
+}
@@ -35,6 +35,8 @@ type Meta struct {
 	PromptTokens       int // only for DoResponse
 	ForcedSystemPrompt string
 	StartTime          time.Time
+	// RequestRewritten is set when the request has been modified, e.g. redacted, and can not be passed through
+	RequestRewritten bool
 }
 
 func GetByContext(c *gin.Context) *Meta {
@@ -1,5 +1,7 @@
 package model
 
+import "mime/multipart"
+
 type ImageRequest struct {
 	Model          string `json:"model"`
 	Prompt         string `json:"prompt" binding:"required"`
@@ -10,3 +12,27 @@ type ImageRequest struct {
 	Style          string `json:"style,omitempty"`
 	User           string `json:"user,omitempty"`
 }
+
+// ImageEditRequest is the multipart form of /v1/images/edits and /v1/images/variations
+type ImageEditRequest struct {
+	Image          *multipart.FileHeader `form:"image" binding:"required"`
+	Mask           *multipart.FileHeader `form:"mask"`
+	Model          string                `form:"model"`
+	Prompt         string                `form:"prompt"`
+	N              int                   `form:"n"`
+	Size           string                `form:"size"`
+	ResponseFormat string                `form:"response_format"`
+	User           string                `form:"user"`
+}
+
+// ToImageRequest returns the fields shared with ImageRequest, used for validation and billing
+func (r *ImageEditRequest) ToImageRequest() *ImageRequest {
+	return &ImageRequest{
+		Model:          r.Model,
+		Prompt:         r.Prompt,
+		N:              r.N,
+		Size:           r.Size,
+		ResponseFormat: r.ResponseFormat,
+		User:           r.User,
+	}
+}
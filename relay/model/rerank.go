@@ -0,0 +1,55 @@
+package model
+
+type RerankRequest struct {
+	Model           string `json:"model"`
+	Query           string `json:"query"`
+	Documents       []any  `json:"documents"`
+	TopN            int    `json:"top_n,omitempty"`
+	ReturnDocuments *bool  `json:"return_documents,omitempty"`
+}
+
+// DocumentTexts returns the text of each document, which is either a string or an object with a text field
+func (r RerankRequest) DocumentTexts() []string {
+	texts := make([]string, 0, len(r.Documents))
+	for _, document := range r.Documents {
+		switch v := document.(type) {
+		case string:
+			texts = append(texts, v)
+		case map[string]any:
+			if text, ok := v["text"].(string); ok {
+				texts = append(texts, text)
+			}
+		}
+	}
+	return texts
+}
+
+type RerankDocument struct {
+	Text string `json:"text"`
+}
+
+type RerankResult struct {
+	Index          int             `json:"index"`
+	RelevanceScore float64         `json:"relevance_score"`
+	Document       *RerankDocument `json:"document,omitempty"`
+}
+
+type RerankBilledUnits struct {
+	SearchUnits  int `json:"search_units,omitempty"`
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
+}
+
+type RerankMeta struct {
+	BilledUnits *RerankBilledUnits `json:"billed_units,omitempty"`
+	Tokens      *RerankBilledUnits `json:"tokens,omitempty"`
+}
+
+// RerankResponse covers the response of cohere, jina and siliconflow
+type RerankResponse struct {
+	Id      string         `json:"id,omitempty"`
+	Model   string         `json:"model,omitempty"`
+	Results []RerankResult `json:"results"`
+	Meta    *RerankMeta    `json:"meta,omitempty"`
+	Usage   *Usage         `json:"usage,omitempty"`
+}
@@ -0,0 +1,58 @@
+package pii
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+var groupRulesLock sync.RWMutex
+
+// GroupRules overrides the default rules for a group, e.g. {"vip": "email,phone", "internal": "none"}
+var GroupRules = map[string]string{}
+
+func GroupRules2JSONString() string {
+	groupRulesLock.RLock()
+	defer groupRulesLock.RUnlock()
+	jsonBytes, err := json.Marshal(GroupRules)
+	if err != nil {
+		logger.SysError("error marshalling pii group rules: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupRulesByJSONString(jsonStr string) error {
+	groupRules := make(map[string]string)
+	if err := json.Unmarshal([]byte(jsonStr), &groupRules); err != nil {
+		return err
+	}
+	for _, list := range groupRules {
+		if err := ValidateRules(list); err != nil {
+			return err
+		}
+	}
+	groupRulesLock.Lock()
+	defer groupRulesLock.Unlock()
+	GroupRules = groupRules
+	return nil
+}
+
+// RulesFor resolves the rules of a request, the rules of the token override those of the group,
+// which override the default rules
+func RulesFor(group string, tokenRules string) []string {
+	list := config.PiiRedactionRules
+	groupRulesLock.RLock()
+	if groupList, ok := GroupRules[group]; ok {
+		list = groupList
+	}
+	groupRulesLock.RUnlock()
+	if tokenRules != "" {
+		list = tokenRules
+	}
+	if list == RuleNone {
+		return nil
+	}
+	return ParseRules(list)
+}
@@ -0,0 +1,301 @@
+package pii
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+const (
+	RuleEmail      = "email"
+	RulePhone      = "phone"
+	RuleIdNumber   = "id_number"
+	RuleCreditCard = "credit_card"
+	RuleApiKey     = "api_key"
+)
+
+// RuleNone disables redaction when used as the rules of a group or token
+const RuleNone = "none"
+
+type rule struct {
+	name     string
+	label    string
+	pattern  *regexp.Regexp
+	validate func(match string) bool
+}
+
+// rules are applied in this order, more specific patterns go first so that
+// e.g. the digits of an api key are not taken as a phone number
+var rules = []*rule{
+	{
+		name:    RuleApiKey,
+		label:   "API_KEY",
+		pattern: regexp.MustCompile(`\b(?:sk-(?:proj-|ant-)?[A-Za-z0-9_\-]{20,}|AKIA[0-9A-Z]{16}|AIza[0-9A-Za-z_\-]{35}|gh[pousr]_[A-Za-z0-9]{36,}|glpat-[A-Za-z0-9_\-]{20,}|xox[abprs]-[A-Za-z0-9\-]{10,})`),
+	},
+	{
+		name:    RuleEmail,
+		label:   "EMAIL",
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+	},
+	{
+		name:     RuleIdNumber,
+		label:    "ID_NUMBER",
+		pattern:  regexp.MustCompile(`\b(?:[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]|\d{3}-\d{2}-\d{4})\b`),
+		validate: isValidIdNumber,
+	},
+	{
+		name:     RuleCreditCard,
+		label:    "CREDIT_CARD",
+		pattern:  regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		validate: isValidCardNumber,
+	},
+	{
+		name:     RulePhone,
+		label:    "PHONE",
+		pattern:  regexp.MustCompile(`(?:\+86[ \-]?|\b)1[3-9]\d{9}\b|(?:\+\d{1,3}[ \-.]?)?(?:\(\d{2,4}\)|\b\d{2,4})[ \-.]\d{3,4}[ \-.]\d{3,4}\b`),
+		validate: isValidPhoneNumber,
+	},
+}
+
+// AllRules returns the names of the supported rules
+func AllRules() []string {
+	names := make([]string, 0, len(rules))
+	for _, r := range rules {
+		names = append(names, r.name)
+	}
+	return names
+}
+
+func IsValidRule(name string) bool {
+	for _, r := range rules {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRules splits a comma separated rule list, unknown names are ignored
+func ParseRules(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if IsValidRule(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ValidateRules checks a rule list set by an admin, an empty list or "none" are valid
+func ValidateRules(list string) error {
+	if list == "" || list == RuleNone {
+		return nil
+	}
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); !IsValidRule(name) {
+			return fmt.Errorf("未知的脱敏规则：%s", name)
+		}
+	}
+	return nil
+}
+
+func digitsOf(s string) string {
+	var b strings.Builder
+	for _, ch := range s {
+		if ch >= '0' && ch <= '9' {
+			b.WriteRune(ch)
+		}
+	}
+	return b.String()
+}
+
+var idNumberWeights = []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+
+// isValidIdNumber checks the checksum of a chinese resident id number, or the ranges of an us ssn
+func isValidIdNumber(s string) bool {
+	if strings.Contains(s, "-") {
+		area := s[:3]
+		return area != "000" && area != "666" && area[0] != '9' && s[4:6] != "00" && s[7:] != "0000"
+	}
+	sum := 0
+	for i, weight := range idNumberWeights {
+		sum += int(s[i]-'0') * weight
+	}
+	check := "10X98765432"[sum%11]
+	return strings.ToUpper(s[17:]) == string(check)
+}
+
+// isValidCardNumber checks the length and the luhn checksum
+func isValidCardNumber(s string) bool {
+	digits := digitsOf(s)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func isValidPhoneNumber(s string) bool {
+	digits := digitsOf(s)
+	return len(digits) >= 10 && len(digits) <= 15
+}
+
+// Redactor replaces sensitive values with placeholders like [EMAIL_1] and remembers them,
+// so that they can be put back into the response. One redactor is used per request.
+type Redactor struct {
+	rules        []*rule
+	placeholders map[string]string // original value -> placeholder
+	originals    map[string]string // placeholder -> original value
+	counts       map[string]int
+}
+
+func NewRedactor(names []string) *Redactor {
+	r := &Redactor{
+		placeholders: make(map[string]string),
+		originals:    make(map[string]string),
+		counts:       make(map[string]int),
+	}
+	for _, candidate := range rules {
+		for _, name := range names {
+			if candidate.name == name {
+				r.rules = append(r.rules, candidate)
+				break
+			}
+		}
+	}
+	return r
+}
+
+func (r *Redactor) Enabled() bool {
+	return r != nil && len(r.rules) > 0
+}
+
+// Redact replaces all matches in text
+func (r *Redactor) Redact(text string) string {
+	for _, rule := range r.rules {
+		text = rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.validate != nil && !rule.validate(match) {
+				return match
+			}
+			r.counts[rule.name]++
+			if placeholder, ok := r.placeholders[match]; ok {
+				return placeholder
+			}
+			placeholder := fmt.Sprintf("[%s_%d]", rule.label, len(r.originals)+1)
+			r.placeholders[match] = placeholder
+			r.originals[placeholder] = match
+			return placeholder
+		})
+	}
+	return text
+}
+
+// RedactRequest redacts the text of all messages, including text parts, tool results and tool call arguments.
+// It reports whether anything has been replaced.
+func (r *Redactor) RedactRequest(request *relaymodel.GeneralOpenAIRequest) bool {
+	if !r.Enabled() {
+		return false
+	}
+	before := r.Count()
+	for i := range request.Messages {
+		message := &request.Messages[i]
+		switch content := message.Content.(type) {
+		case string:
+			message.Content = r.Redact(content)
+		case []any:
+			for _, item := range content {
+				part, ok := item.(map[string]any)
+				if !ok || part["type"] != relaymodel.ContentTypeText {
+					continue
+				}
+				if text, ok := part["text"].(string); ok {
+					part["text"] = r.Redact(text)
+				}
+			}
+		}
+		for j := range message.ToolCalls {
+			if arguments, ok := message.ToolCalls[j].Function.Arguments.(string); ok {
+				message.ToolCalls[j].Function.Arguments = r.Redact(arguments)
+			}
+		}
+	}
+	return r.Count() > before
+}
+
+// Count returns the number of replaced values
+func (r *Redactor) Count() int {
+	if r == nil {
+		return 0
+	}
+	count := 0
+	for _, n := range r.counts {
+		count += n
+	}
+	return count
+}
+
+// Summary describes the replaced values per rule, e.g. "email×2, phone×1"
+func (r *Redactor) Summary() string {
+	if r.Count() == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(r.counts))
+	for name := range r.counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s×%d", name, r.counts[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+var placeholderPattern = regexp.MustCompile(`\[[A-Z_]+_\d+\]`)
+
+// Restore puts the original values back in place of the placeholders
+func (r *Redactor) Restore(text string) string {
+	return r.restore(text, func(s string) string { return s })
+}
+
+func (r *Redactor) restore(text string, escape func(string) string) string {
+	if len(r.originals) == 0 {
+		return text
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if original, ok := r.originals[placeholder]; ok {
+			return escape(original)
+		}
+		return placeholder
+	})
+}
+
+// partialPlaceholderPattern matches what may be the beginning of a placeholder at the end of a chunk
+var partialPlaceholderPattern = regexp.MustCompile(`\[[A-Z_]*\d*$`)
+
+const maxPlaceholderLength = 32
+
+// restorePartial restores text that is streamed in pieces, a trailing part that may be
+// an incomplete placeholder is held back and returned as rest
+func (r *Redactor) restorePartial(text string) (restored string, rest string) {
+	if loc := partialPlaceholderPattern.FindStringIndex(text); loc != nil && loc[1]-loc[0] < maxPlaceholderLength {
+		text, rest = text[:loc[0]], text[loc[0]:]
+	}
+	return r.Restore(text), rest
+}
@@ -0,0 +1,191 @@
+package pii
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RestoreWriter puts the original values back into the response before it reaches the client.
+// A non-stream response is buffered until Close, a stream is restored line by line,
+// holding back the end of a delta that may be the beginning of a split placeholder.
+type RestoreWriter struct {
+	gin.ResponseWriter
+	redactor *Redactor
+	stream   bool
+	buffer   bytes.Buffer
+	status   int
+	pending  map[float64]string // held back text per choice index
+}
+
+func NewRestoreWriter(w gin.ResponseWriter, redactor *Redactor, stream bool) *RestoreWriter {
+	return &RestoreWriter{
+		ResponseWriter: w,
+		redactor:       redactor,
+		stream:         stream,
+		pending:        make(map[float64]string),
+	}
+}
+
+func (w *RestoreWriter) WriteHeader(code int) {
+	if w.stream {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *RestoreWriter) WriteHeaderNow() {
+	if w.stream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *RestoreWriter) Write(data []byte) (int, error) {
+	w.buffer.Write(data)
+	if w.stream {
+		if err := w.writeLines(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *RestoreWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *RestoreWriter) Flush() {
+	if w.stream {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// Close writes what is left, it must be called once the response is complete
+func (w *RestoreWriter) Close() error {
+	if w.stream {
+		if w.buffer.Len() > 0 {
+			w.buffer.WriteByte('\n')
+			if err := w.writeLines(); err != nil {
+				return err
+			}
+		}
+		if held := w.flushPending(); len(held) > 0 {
+			if _, err := w.ResponseWriter.Write(held); err != nil {
+				return err
+			}
+		}
+		w.ResponseWriter.Flush()
+		return nil
+	}
+	if w.buffer.Len() == 0 && w.status == 0 {
+		return nil
+	}
+	body := w.redactor.restore(w.buffer.String(), escapeJSON)
+	w.ResponseWriter.Header().Del("Content-Length")
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	_, err := w.ResponseWriter.WriteString(body)
+	return err
+}
+
+// escapeJSON escapes an original value to be put into a json string
+func escapeJSON(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data[1 : len(data)-1])
+}
+
+func (w *RestoreWriter) writeLines() error {
+	for {
+		data := w.buffer.Bytes()
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return nil
+		}
+		line := w.restoreLine(string(data[:i+1]))
+		w.buffer.Next(i + 1)
+		if _, err := w.ResponseWriter.WriteString(line); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *RestoreWriter) restoreLine(line string) string {
+	payload := strings.TrimSpace(line)
+	if !strings.HasPrefix(payload, "data:") {
+		return line
+	}
+	payload = strings.TrimSpace(strings.TrimPrefix(payload, "data:"))
+	if payload == "[DONE]" {
+		return string(w.flushPending()) + line
+	}
+	var chunk map[string]any
+	if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+		return w.redactor.restore(line, escapeJSON)
+	}
+	choices, _ := chunk["choices"].([]any)
+	changed := false
+	for _, item := range choices {
+		choice, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		index, _ := choice["index"].(float64)
+		finished := choice["finish_reason"] != nil
+		if delta, ok := choice["delta"].(map[string]any); ok {
+			changed = w.restoreField(delta, "content", index, finished) || changed
+		} else if _, ok := choice["text"]; ok {
+			changed = w.restoreField(choice, "text", index, finished) || changed
+		}
+	}
+	if !changed {
+		return line
+	}
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return line
+	}
+	return "data: " + string(data) + "\n"
+}
+
+// restoreField restores the streamed text of object[key], it reports whether the text changed
+func (w *RestoreWriter) restoreField(object map[string]any, key string, index float64, finished bool) bool {
+	text, _ := object[key].(string)
+	held := w.pending[index]
+	if text == "" && held == "" {
+		return false
+	}
+	restored, rest := w.redactor.restorePartial(held + text)
+	if finished {
+		restored += w.redactor.Restore(rest)
+		rest = ""
+	}
+	w.pending[index] = rest
+	if restored == text {
+		return false
+	}
+	object[key] = restored
+	return true
+}
+
+// flushPending returns an extra chunk with the held back text, for streams that end without a finish reason
+func (w *RestoreWriter) flushPending() []byte {
+	var buf bytes.Buffer
+	for index, held := range w.pending {
+		if held == "" {
+			continue
+		}
+		data, _ := json.Marshal(map[string]any{
+			"choices": []any{map[string]any{
+				"index": index,
+				"delta": map[string]any{"content": w.redactor.Restore(held)},
+			}},
+		})
+		buf.WriteString("data: " + string(data) + "\n\n")
+		delete(w.pending, index)
+	}
+	return buf.Bytes()
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/songquanpeng/one-api/controller"
 	"github.com/songquanpeng/one-api/controller/auth"
 	"github.com/songquanpeng/one-api/middleware"
+	"github.com/songquanpeng/one-api/model"
 
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
@@ -27,62 +28,79 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/oauth/lark", middleware.CriticalRateLimit(), auth.LarkOAuth)
 		apiRouter.GET("/oauth/state", middleware.CriticalRateLimit(), auth.GenerateOAuthCode)
 		apiRouter.GET("/oauth/wechat", middleware.CriticalRateLimit(), auth.WeChatAuth)
-		apiRouter.GET("/oauth/wechat/bind", middleware.CriticalRateLimit(), middleware.UserAuth(), auth.WeChatBind)
-		apiRouter.GET("/oauth/email/bind", middleware.CriticalRateLimit(), middleware.UserAuth(), controller.EmailBind)
-		apiRouter.POST("/topup", middleware.AdminAuth(), controller.AdminTopUp)
+		apiRouter.GET("/oauth/wechat/bind", middleware.CriticalRateLimit(), middleware.SessionAuth(), auth.WeChatBind)
+		apiRouter.GET("/oauth/email/bind", middleware.CriticalRateLimit(), middleware.SessionAuth(), controller.EmailBind)
+		apiRouter.POST("/topup", middleware.PermissionAuth(model.PermissionUserQuota), controller.AdminTopUp)
 
 		userRoute := apiRouter.Group("/user")
 		{
 			userRoute.POST("/register", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Register)
 			userRoute.POST("/login", middleware.CriticalRateLimit(), controller.Login)
 			userRoute.GET("/logout", controller.Logout)
+			userRoute.POST("/login/2fa", middleware.CriticalRateLimit(), controller.LoginTwoFactor)
+			userRoute.POST("/2fa/setup", middleware.CriticalRateLimit(), controller.SetupTwoFactor)
+			userRoute.POST("/2fa/enable", middleware.CriticalRateLimit(), controller.EnableTwoFactor)
 
 			selfRoute := userRoute.Group("/")
 			selfRoute.Use(middleware.UserAuth())
 			{
 				selfRoute.GET("/dashboard", controller.GetUserDashboard)
 				selfRoute.GET("/self", controller.GetSelf)
-				selfRoute.PUT("/self", controller.UpdateSelf)
-				selfRoute.DELETE("/self", controller.DeleteSelf)
-				selfRoute.GET("/token", controller.GenerateAccessToken)
+				selfRoute.GET("/access_token", controller.GetAccessTokens)
+				selfRoute.GET("/access_token/scopes", controller.GetAccessTokenScopes)
+				selfRoute.GET("/session", controller.GetSelfSessions)
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.POST("/topup", controller.TopUp)
 				selfRoute.GET("/available_models", controller.GetUserAvailableModels)
 			}
 
+			// account changes that access tokens must not make
+			sessionRoute := userRoute.Group("/")
+			sessionRoute.Use(middleware.SessionAuth())
+			{
+				sessionRoute.PUT("/self", controller.UpdateSelf)
+				sessionRoute.DELETE("/self", controller.DeleteSelf)
+				sessionRoute.POST("/access_token", controller.AddAccessToken)
+				sessionRoute.DELETE("/access_token/:id", controller.DeleteAccessToken)
+				sessionRoute.DELETE("/session", controller.RevokeOtherSelfSessions)
+				sessionRoute.DELETE("/session/:id", controller.DeleteSelfSession)
+				sessionRoute.POST("/2fa/disable", middleware.CriticalRateLimit(), controller.DisableTwoFactor)
+			}
+
 			adminRoute := userRoute.Group("/")
-			adminRoute.Use(middleware.AdminAuth())
 			{
-				adminRoute.GET("/", controller.GetAllUsers)
-				adminRoute.GET("/search", controller.SearchUsers)
-				adminRoute.GET("/:id", controller.GetUser)
-				adminRoute.POST("/", controller.CreateUser)
-				adminRoute.POST("/manage", controller.ManageUser)
-				adminRoute.PUT("/", controller.UpdateUser)
-				adminRoute.DELETE("/:id", controller.DeleteUser)
+				adminRoute.GET("/", middleware.PermissionAuth(model.PermissionUserRead), controller.GetAllUsers)
+				adminRoute.GET("/search", middleware.PermissionAuth(model.PermissionUserRead), controller.SearchUsers)
+				adminRoute.GET("/:id", middleware.PermissionAuth(model.PermissionUserRead), controller.GetUser)
+				adminRoute.GET("/:id/session", middleware.PermissionAuth(model.PermissionUserRead), controller.GetUserSessions)
+				adminRoute.DELETE("/:id/session", middleware.PermissionAuth(model.PermissionUserWrite), controller.RevokeUserSessions)
+				adminRoute.POST("/", middleware.PermissionAuth(model.PermissionUserWrite), controller.CreateUser)
+				adminRoute.POST("/manage", middleware.PermissionAuth(model.PermissionUserWrite), controller.ManageUser)
+				adminRoute.PUT("/", middleware.PermissionAuth(model.PermissionUserWrite), controller.UpdateUser)
+				adminRoute.DELETE("/:id", middleware.PermissionAuth(model.PermissionUserWrite), controller.DeleteUser)
 			}
 		}
 		optionRoute := apiRouter.Group("/option")
-		optionRoute.Use(middleware.RootAuth())
 		{
-			optionRoute.GET("/", controller.GetOptions)
-			optionRoute.PUT("/", controller.UpdateOption)
+			optionRoute.GET("/", middleware.PermissionAuth(model.PermissionOptionRead), controller.GetOptions)
+			optionRoute.PUT("/", middleware.PermissionAuth(model.PermissionOptionWrite), controller.UpdateOption)
 		}
 		channelRoute := apiRouter.Group("/channel")
-		channelRoute.Use(middleware.AdminAuth())
 		{
-			channelRoute.GET("/", controller.GetAllChannels)
-			channelRoute.GET("/search", controller.SearchChannels)
-			channelRoute.GET("/models", controller.ListAllModels)
-			channelRoute.GET("/:id", controller.GetChannel)
-			channelRoute.GET("/test", controller.TestChannels)
-			channelRoute.GET("/test/:id", controller.TestChannel)
-			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
-			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
-			channelRoute.POST("/", controller.AddChannel)
-			channelRoute.PUT("/", controller.UpdateChannel)
-			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
-			channelRoute.DELETE("/:id", controller.DeleteChannel)
+			channelRoute.GET("/", middleware.PermissionAuth(model.PermissionChannelRead), controller.GetAllChannels)
+			channelRoute.GET("/search", middleware.PermissionAuth(model.PermissionChannelRead), controller.SearchChannels)
+			channelRoute.GET("/models", middleware.PermissionAuth(model.PermissionChannelRead), controller.ListAllModels)
+			channelRoute.GET("/:id", middleware.PermissionAuth(model.PermissionChannelRead), controller.GetChannel)
+			channelRoute.GET("/test", middleware.PermissionAuth(model.PermissionChannelTest), controller.TestChannels)
+			channelRoute.GET("/test/:id", middleware.PermissionAuth(model.PermissionChannelTest), controller.TestChannel)
+			channelRoute.GET("/update_balance", middleware.PermissionAuth(model.PermissionChannelTest), controller.UpdateAllChannelsBalance)
+			channelRoute.GET("/update_balance/:id", middleware.PermissionAuth(model.PermissionChannelTest), controller.UpdateChannelBalance)
+			channelRoute.POST("/", middleware.PermissionAuth(model.PermissionChannelWrite), controller.AddChannel)
+			channelRoute.PUT("/", middleware.PermissionAuth(model.PermissionChannelWrite), controller.UpdateChannel)
+			channelRoute.DELETE("/disabled", middleware.PermissionAuth(model.PermissionChannelWrite), controller.DeleteDisabledChannel)
+			channelRoute.DELETE("/:id", middleware.PermissionAuth(model.PermissionChannelWrite), controller.DeleteChannel)
+			channelRoute.POST("/:id/reveal", middleware.PermissionAuth(model.PermissionChannelSecret), controller.RevealChannelKey)
+			channelRoute.POST("/rotate_key", middleware.PermissionAuth(model.PermissionChannelSecret), controller.RotateChannelKeys)
 		}
 		tokenRoute := apiRouter.Group("/token")
 		tokenRoute.Use(middleware.UserAuth())
@@ -95,29 +113,64 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
 		}
 		redemptionRoute := apiRouter.Group("/redemption")
-		redemptionRoute.Use(middleware.AdminAuth())
 		{
-			redemptionRoute.GET("/", controller.GetAllRedemptions)
-			redemptionRoute.GET("/search", controller.SearchRedemptions)
-			redemptionRoute.GET("/:id", controller.GetRedemption)
-			redemptionRoute.POST("/", controller.AddRedemption)
-			redemptionRoute.PUT("/", controller.UpdateRedemption)
-			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
+			redemptionRoute.GET("/", middleware.PermissionAuth(model.PermissionRedemptionRead), controller.GetAllRedemptions)
+			redemptionRoute.GET("/search", middleware.PermissionAuth(model.PermissionRedemptionRead), controller.SearchRedemptions)
+			redemptionRoute.GET("/:id", middleware.PermissionAuth(model.PermissionRedemptionRead), controller.GetRedemption)
+			redemptionRoute.POST("/", middleware.PermissionAuth(model.PermissionRedemptionCreate), controller.AddRedemption)
+			redemptionRoute.PUT("/", middleware.PermissionAuth(model.PermissionRedemptionWrite), controller.UpdateRedemption)
+			redemptionRoute.DELETE("/:id", middleware.PermissionAuth(model.PermissionRedemptionWrite), controller.DeleteRedemption)
 		}
 		logRoute := apiRouter.Group("/log")
-		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
-		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
-		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRoute.GET("/", middleware.PermissionAuth(model.PermissionLogReadAll), controller.GetAllLogs)
+		logRoute.DELETE("/", middleware.PermissionAuth(model.PermissionLogDelete), controller.DeleteHistoryLogs)
+		logRoute.GET("/archive", middleware.PermissionAuth(model.PermissionLogReadAll), controller.GetLogArchives)
+		logRoute.GET("/archive/:name", middleware.PermissionAuth(model.PermissionLogReadAll), controller.DownloadLogArchive)
+		logRoute.GET("/stat", middleware.PermissionAuth(model.PermissionLogReadAll), controller.GetLogsStat)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
-		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
+		logRoute.GET("/search", middleware.PermissionAuth(model.PermissionLogReadAll), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
+		auditRoute := apiRouter.Group("/audit")
+		auditRoute.Use(middleware.PermissionAuth(model.PermissionAuditRead))
+		{
+			auditRoute.GET("/", controller.GetAuditLogs)
+		}
+		banRoute := apiRouter.Group("/ban")
+		{
+			banRoute.GET("/", middleware.PermissionAuth(model.PermissionBanRead), controller.GetBans)
+			banRoute.DELETE("/", middleware.PermissionAuth(model.PermissionBanWrite), controller.LiftBan)
+		}
 		groupRoute := apiRouter.Group("/group")
-		groupRoute.Use(middleware.AdminAuth())
+		groupRoute.Use(middleware.PermissionAuth(model.PermissionGroupRead))
 		{
 			groupRoute.GET("/", controller.GetGroups)
 		}
 		// 敏感词过滤设置
-		apiRouter.PUT("/setting/sensitive-filter", middleware.AdminAuth(), controller.UpdateSensitiveFilterSetting)
+		apiRouter.PUT("/setting/sensitive-filter", middleware.PermissionAuth(model.PermissionFilterWrite), controller.UpdateSensitiveFilterSetting)
+		filterRuleRoute := apiRouter.Group("/filter/rule")
+		{
+			filterRuleRoute.GET("/", middleware.PermissionAuth(model.PermissionFilterRead), controller.GetFilterRules)
+			filterRuleRoute.GET("/:id", middleware.PermissionAuth(model.PermissionFilterRead), controller.GetFilterRule)
+			filterRuleRoute.POST("/", middleware.PermissionAuth(model.PermissionFilterWrite), controller.AddFilterRule)
+			filterRuleRoute.PUT("/", middleware.PermissionAuth(model.PermissionFilterWrite), controller.UpdateFilterRule)
+			filterRuleRoute.DELETE("/:id", middleware.PermissionAuth(model.PermissionFilterWrite), controller.DeleteFilterRule)
+		}
+		filterHitRoute := apiRouter.Group("/filter/hit")
+		filterHitRoute.Use(middleware.PermissionAuth(model.PermissionFilterRead))
+		{
+			filterHitRoute.GET("/", controller.GetFilterHits)
+			filterHitRoute.GET("/stat", controller.GetFilterHitStats)
+		}
+		apiRouter.GET("/capture/:request_id", middleware.PermissionAuth(model.PermissionCaptureRead), controller.GetRequestCapture)
+		roleRoute := apiRouter.Group("/role")
+		roleRoute.Use(middleware.PermissionAuth(model.PermissionRoleWrite))
+		{
+			roleRoute.GET("/", controller.GetAllRoles)
+			roleRoute.GET("/permissions", controller.GetAllPermissions)
+			roleRoute.POST("/", controller.AddRole)
+			roleRoute.PUT("/", controller.UpdateRole)
+			roleRoute.DELETE("/:id", controller.DeleteRole)
+		}
 	}
 }
@@ -1,5 +1,5 @@
 import React, { useEffect, useState } from 'react';
-import { API, copy, showError, showSuccess, timestamp2string } from '../helpers';
+import { API, copy, showError, showSuccess, showWarning, timestamp2string } from '../helpers';
 
 import { ITEMS_PER_PAGE } from '../constants';
 import { renderQuota } from '../helpers/render';
@@ -148,7 +148,9 @@ const TokensTable = () => {
           </Popover>
           <Button theme="light" type="secondary" style={{ marginRight: 1 }}
                   onClick={async (text) => {
-                    await copyText('sk-' + record.key);
+                    if (!isMaskedKey(record.key)) {
+                      await copyText('sk-' + record.key);
+                    }
                   }}
           >复制</Button>
           <SplitButtonGroup style={{ marginRight: 1 }} aria-label="项目操作按钮组">
@@ -313,7 +315,19 @@ const TokensTable = () => {
     await loadTokens(activePage - 1);
   };
 
+  // the list only has the masked keys, the full key is shown once when the token is created
+  const isMaskedKey = (key) => {
+    if (key.includes('*')) {
+      showWarning('完整令牌仅在创建时显示一次，如已遗失请重新创建令牌。');
+      return true;
+    }
+    return false;
+  };
+
   const onCopy = async (type, key) => {
+    if (isMaskedKey(key)) {
+      return;
+    }
     let status = localStorage.getItem('status');
     let serverAddress = '';
     if (status) {
@@ -366,6 +380,9 @@ const TokensTable = () => {
   };
 
   const onOpenLink = async (type, key) => {
+    if (isMaskedKey(key)) {
+      return;
+    }
     let status = localStorage.getItem('status');
     let serverAddress = '';
     if (status) {
@@ -57,6 +57,11 @@ export function showNotice(message, isHTML = false) {
     }
 }
 
+// showPersistent keeps the message until it is closed, e.g. for a secret that is only shown once
+export function showPersistent(message) {
+    enqueueSnackbar(<SnackbarHTMLContent htmlContent={message}/>, getSnackbarOptions('COPY'));
+}
+
 export function showWarning(message) {
     enqueueSnackbar(message, getSnackbarOptions('WARNING'));
 }
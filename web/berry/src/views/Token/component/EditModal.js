@@ -26,7 +26,7 @@ import {
 import { AdapterDayjs } from '@mui/x-date-pickers/AdapterDayjs';
 import { LocalizationProvider } from '@mui/x-date-pickers/LocalizationProvider';
 import { DateTimePicker } from '@mui/x-date-pickers/DateTimePicker';
-import { renderQuotaWithPrompt, showSuccess, showError } from 'utils/common';
+import { renderQuotaWithPrompt, showSuccess, showError, showPersistent, copy } from 'utils/common';
 import { API } from 'utils/api';
 import CheckBoxOutlineBlankIcon from '@mui/icons-material/CheckBoxOutlineBlank';
 import CheckBoxIcon from '@mui/icons-material/CheckBox';
@@ -70,12 +70,15 @@ const EditModal = ({ open, tokenId, onCancel, onOk }) => {
     } else {
       res = await API.post(`/api/token/`, { ...values, models: models });
     }
-    const { success, message } = res.data;
+    const { success, message, data } = res.data;
     if (success) {
       if (values.is_edit) {
         showSuccess('令牌更新成功！');
       } else {
-        showSuccess('令牌创建成功，请在列表页面点击复制获取令牌！');
+        // the full key is only returned once
+        const key = `sk-${data.key}`;
+        copy(key, '令牌');
+        showPersistent(`令牌创建成功，该令牌不会再次显示，请妥善保存：<br /><br />${key}`);
       }
       setSubmitting(false);
       setStatus({ success: true });
@@ -20,7 +20,7 @@ import {
 } from '@mui/material';
 
 import TableSwitch from 'ui-component/Switch';
-import { renderQuota, timestamp2string, copy } from 'utils/common';
+import { renderQuota, timestamp2string, copy, showWarning } from 'utils/common';
 
 import { IconDotsVertical, IconEdit, IconTrash, IconCaretDownFilled } from '@tabler/icons-react';
 
@@ -36,6 +36,15 @@ const COPY_OPTIONS = [
   { key: 'lobechat', text: 'LobeChat', url: 'https://lobehub.com/?settings={"keyVaults":{"openai":{"apiKey":"sk-{key}","baseURL":"{serverAddress}"}}}', encode: true }
 ];
 
+// the list only has the masked keys, the full key is shown once when the token is created
+function isMaskedKey(key) {
+  if (key.includes('*')) {
+    showWarning('完整令牌仅在创建时显示一次，如已遗失请重新创建令牌。');
+    return true;
+  }
+  return false;
+}
+
 function replacePlaceholders(text, key, serverAddress) {
   return text.replace('{key}', key).replace('{serverAddress}', serverAddress);
 }
@@ -138,6 +147,10 @@ export default function TokensTableRow({ item, manageToken, handleOpenModal, set
     }
 
     const key = item.key;
+    if (isMaskedKey(key)) {
+      handleCloseMenu();
+      return;
+    }
     const text = replacePlaceholders(url, key, serverAddress);
     if (type === 'link') {
       window.open(text);
@@ -211,7 +224,9 @@ export default function TokensTableRow({ item, manageToken, handleOpenModal, set
               <Button
                 color="primary"
                 onClick={() => {
-                  copy(`sk-${item.key}`);
+                  if (!isMaskedKey(item.key)) {
+                    copy(`sk-${item.key}`);
+                  }
                 }}
               >
                 复制
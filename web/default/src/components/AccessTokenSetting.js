@@ -0,0 +1,223 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Button, Form, Message, Modal, Table } from 'semantic-ui-react';
+import {
+  API,
+  copy,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../helpers';
+
+const emptyToken = {
+  name: '',
+  scopes: [],
+  subnet: '',
+  expired_time: '',
+};
+
+const AccessTokenSetting = () => {
+  const { t } = useTranslation();
+  const [tokens, setTokens] = useState([]);
+  const [scopeOptions, setScopeOptions] = useState([]);
+  const [inputs, setInputs] = useState(emptyToken);
+  const [showModal, setShowModal] = useState(false);
+  const [createdKey, setCreatedKey] = useState('');
+  const [loading, setLoading] = useState(false);
+
+  const loadTokens = async () => {
+    const res = await API.get('/api/user/access_token');
+    const { success, message, data } = res.data;
+    if (success) {
+      setTokens(data || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const loadScopes = async () => {
+    const res = await API.get('/api/user/access_token/scopes');
+    const { success, message, data } = res.data;
+    if (success) {
+      setScopeOptions(
+        data.map((scope) => ({ key: scope, text: scope, value: scope }))
+      );
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadTokens().then();
+    loadScopes().then();
+  }, []);
+
+  const handleInputChange = (e, { name, value }) => {
+    setInputs((inputs) => ({ ...inputs, [name]: value }));
+  };
+
+  const submit = async () => {
+    let expired_time = -1;
+    if (inputs.expired_time !== '') {
+      let time = Date.parse(inputs.expired_time);
+      if (isNaN(time)) {
+        showError(t('access_token.messages.expire_time_invalid'));
+        return;
+      }
+      expired_time = Math.ceil(time / 1000);
+    }
+    setLoading(true);
+    const res = await API.post('/api/user/access_token', {
+      name: inputs.name,
+      scopes: inputs.scopes.join(','),
+      subnet: inputs.subnet,
+      expired_time,
+    });
+    const { success, message, data } = res.data;
+    if (success) {
+      setCreatedKey(data.key);
+      setInputs(emptyToken);
+      await copy(data.key);
+      showSuccess(t('access_token.messages.created'));
+      await loadTokens();
+    } else {
+      showError(message);
+    }
+    setLoading(false);
+  };
+
+  const revoke = async (id) => {
+    const res = await API.delete(`/api/user/access_token/${id}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('access_token.messages.revoked'));
+      await loadTokens();
+    } else {
+      showError(message);
+    }
+  };
+
+  const closeModal = () => {
+    setShowModal(false);
+    setCreatedKey('');
+  };
+
+  return (
+    <>
+      <Message>{t('access_token.notice')}</Message>
+      {tokens.length > 0 && (
+        <Table basic compact size='small'>
+          <Table.Header>
+            <Table.Row>
+              <Table.HeaderCell>{t('access_token.name')}</Table.HeaderCell>
+              <Table.HeaderCell>{t('access_token.key')}</Table.HeaderCell>
+              <Table.HeaderCell>{t('access_token.scopes')}</Table.HeaderCell>
+              <Table.HeaderCell>
+                {t('access_token.expired_time')}
+              </Table.HeaderCell>
+              <Table.HeaderCell>{t('access_token.last_used')}</Table.HeaderCell>
+              <Table.HeaderCell>{t('access_token.actions')}</Table.HeaderCell>
+            </Table.Row>
+          </Table.Header>
+          <Table.Body>
+            {tokens.map((token) => (
+              <Table.Row key={token.id}>
+                <Table.Cell>{token.name}</Table.Cell>
+                <Table.Cell>{token.key_prefix}…</Table.Cell>
+                <Table.Cell>
+                  {token.scopes.split(',').join(', ')}
+                  {token.subnet && ` (${token.subnet})`}
+                </Table.Cell>
+                <Table.Cell>
+                  {token.expired_time === -1
+                    ? t('access_token.never_expire')
+                    : timestamp2string(token.expired_time)}
+                </Table.Cell>
+                <Table.Cell>
+                  {token.last_used_time
+                    ? `${timestamp2string(token.last_used_time)} ${
+                        token.last_used_ip
+                      }`
+                    : t('access_token.never_used')}
+                </Table.Cell>
+                <Table.Cell>
+                  <Button
+                    size='tiny'
+                    negative
+                    onClick={() => revoke(token.id)}
+                  >
+                    {t('access_token.buttons.revoke')}
+                  </Button>
+                </Table.Cell>
+              </Table.Row>
+            ))}
+          </Table.Body>
+        </Table>
+      )}
+      <Button onClick={() => setShowModal(true)}>
+        {t('access_token.buttons.add')}
+      </Button>
+      <Modal onClose={closeModal} open={showModal} size={'tiny'}>
+        <Modal.Header>{t('access_token.add_title')}</Modal.Header>
+        <Modal.Content>
+          {createdKey ? (
+            <>
+              <Message>{t('access_token.created_notice')}</Message>
+              <Form.Input
+                fluid
+                readOnly
+                value={createdKey}
+                onClick={async (e) => {
+                  e.target.select();
+                  await copy(createdKey);
+                }}
+              />
+              <Button fluid style={{ marginTop: '10px' }} onClick={closeModal}>
+                {t('access_token.buttons.close')}
+              </Button>
+            </>
+          ) : (
+            <Form loading={loading}>
+              <Form.Input
+                label={t('access_token.name')}
+                name='name'
+                value={inputs.name}
+                onChange={handleInputChange}
+              />
+              <Form.Dropdown
+                label={t('access_token.scopes')}
+                name='scopes'
+                placeholder={t('access_token.scopes_placeholder')}
+                fluid
+                multiple
+                selection
+                options={scopeOptions}
+                value={inputs.scopes}
+                onChange={handleInputChange}
+              />
+              <Form.Input
+                label={t('access_token.subnet')}
+                name='subnet'
+                placeholder={t('access_token.subnet_placeholder')}
+                value={inputs.subnet}
+                onChange={handleInputChange}
+              />
+              <Form.Input
+                label={t('access_token.expired_time')}
+                name='expired_time'
+                type='datetime-local'
+                value={inputs.expired_time}
+                onChange={handleInputChange}
+              />
+              <Button fluid onClick={submit}>
+                {t('access_token.buttons.submit')}
+              </Button>
+            </Form>
+          )}
+        </Modal.Content>
+      </Modal>
+    </>
+  );
+};
+
+export default AccessTokenSetting;
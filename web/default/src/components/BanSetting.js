@@ -0,0 +1,83 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Button, Label, Table } from 'semantic-ui-react';
+import {
+  API,
+  hasPermission,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../helpers';
+
+const BanSetting = () => {
+  const { t } = useTranslation();
+  const [bans, setBans] = useState([]);
+  const canLift = hasPermission('ban:write');
+
+  const loadBans = async () => {
+    const res = await API.get('/api/ban/');
+    const { success, message, data } = res.data;
+    if (success) {
+      setBans(data || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadBans().then();
+  }, []);
+
+  const lift = async (ban) => {
+    const res = await API.delete('/api/ban/', {
+      params: { type: ban.type, value: ban.value },
+    });
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('ban.messages.lifted'));
+      await loadBans();
+    } else {
+      showError(message);
+    }
+  };
+
+  return (
+    <>
+      <Table basic compact size='small'>
+        <Table.Header>
+          <Table.Row>
+            <Table.HeaderCell>{t('ban.type')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('ban.value')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('ban.reason')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('ban.created_time')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('ban.expired_time')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('ban.actions')}</Table.HeaderCell>
+          </Table.Row>
+        </Table.Header>
+        <Table.Body>
+          {bans.map((ban) => (
+            <Table.Row key={`${ban.type}:${ban.value}`}>
+              <Table.Cell>
+                <Label size='tiny'>{t(`ban.types.${ban.type}`)}</Label>
+              </Table.Cell>
+              <Table.Cell>{ban.value}</Table.Cell>
+              <Table.Cell>{ban.reason}</Table.Cell>
+              <Table.Cell>{timestamp2string(ban.created_time)}</Table.Cell>
+              <Table.Cell>{timestamp2string(ban.expired_time)}</Table.Cell>
+              <Table.Cell>
+                {canLift && (
+                  <Button size='tiny' negative onClick={() => lift(ban)}>
+                    {t('ban.buttons.lift')}
+                  </Button>
+                )}
+              </Table.Cell>
+            </Table.Row>
+          ))}
+        </Table.Body>
+      </Table>
+      <Button onClick={loadBans}>{t('ban.buttons.refresh')}</Button>
+    </>
+  );
+};
+
+export default BanSetting;
@@ -15,7 +15,7 @@ import {
   API,
   getLogo,
   getSystemName,
-  isAdmin,
+  hasPermission,
   isMobile,
   showSuccess,
 } from '../helpers';
@@ -27,7 +27,7 @@ let headerButtons = [
     name: 'header.channel',
     to: '/channel',
     icon: 'sitemap',
-    admin: true,
+    permission: 'channel:read',
   },
   {
     name: 'header.token',
@@ -38,7 +38,7 @@ let headerButtons = [
     name: 'header.redemption',
     to: '/redemption',
     icon: 'dollar sign',
-    admin: true,
+    permission: 'redemption:read',
   },
   {
     name: 'header.topup',
@@ -49,7 +49,7 @@ let headerButtons = [
     name: 'header.user',
     to: '/user',
     icon: 'user',
-    admin: true,
+    permission: 'user:read',
   },
   {
     name: 'header.dashboard',
@@ -105,7 +105,8 @@ const Header = () => {
 
   const renderButtons = (isMobile) => {
     return headerButtons.map((button) => {
-      if (button.admin && !isAdmin()) return <></>;
+      if (button.permission && !hasPermission(button.permission))
+        return <></>;
       if (isMobile) {
         return (
           <Menu.Item
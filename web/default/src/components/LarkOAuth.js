@@ -20,6 +20,8 @@ const LarkOAuth = () => {
       if (message === 'bind') {
         showSuccess('绑定成功！');
         navigate('/setting');
+      } else if (data.require_2fa || data.require_2fa_setup) {
+        navigate(data.require_2fa ? '/login?2fa=verify' : '/login?2fa=setup');
       } else {
         userDispatch({ type: 'login', payload: data });
         localStorage.setItem('user', JSON.stringify(data));
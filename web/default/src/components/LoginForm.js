@@ -17,6 +17,7 @@ import { UserContext } from '../context/User';
 import { API, getLogo, showError, showSuccess, showWarning } from '../helpers';
 import { onGitHubOAuthClicked, onLarkOAuthClicked } from './utils';
 import larkIcon from '../images/lark.svg';
+import TwoFactorSetup from './TwoFactorSetup';
 
 const LoginForm = () => {
   const { t } = useTranslation();
@@ -31,6 +32,9 @@ const LoginForm = () => {
   const [userState, userDispatch] = useContext(UserContext);
   let navigate = useNavigate();
   const [status, setStatus] = useState({});
+  // '' for the password step, 'verify' or 'setup' for the 2FA step
+  const [twoFactor, setTwoFactor] = useState(searchParams.get('2fa') || '');
+  const [twoFactorCode, setTwoFactorCode] = useState('');
   const logo = getLogo();
 
   useEffect(() => {
@@ -56,11 +60,15 @@ const LoginForm = () => {
     );
     const { success, message, data } = res.data;
     if (success) {
+      setShowWeChatLoginModal(false);
+      if (data.require_2fa || data.require_2fa_setup) {
+        setTwoFactor(data.require_2fa ? 'verify' : 'setup');
+        return;
+      }
       userDispatch({ type: 'login', payload: data });
       localStorage.setItem('user', JSON.stringify(data));
       navigate('/');
       showSuccess(t('messages.success.login'));
-      setShowWeChatLoginModal(false);
     } else {
       showError(message);
     }
@@ -80,6 +88,10 @@ const LoginForm = () => {
       });
       const { success, message, data } = res.data;
       if (success) {
+        if (data.require_2fa || data.require_2fa_setup) {
+          setTwoFactor(data.require_2fa ? 'verify' : 'setup');
+          return;
+        }
         userDispatch({ type: 'login', payload: data });
         localStorage.setItem('user', JSON.stringify(data));
         if (username === 'root' && password === '123456') {
@@ -96,6 +108,81 @@ const LoginForm = () => {
     }
   }
 
+  const completeTwoFactorLogin = (data) => {
+    userDispatch({ type: 'login', payload: data });
+    localStorage.setItem('user', JSON.stringify(data));
+    navigate('/token');
+    showSuccess(t('messages.success.login'));
+  };
+
+  async function handleTwoFactorSubmit() {
+    if (twoFactorCode === '') return;
+    const res = await API.post(`/api/user/login/2fa`, {
+      code: twoFactorCode,
+    });
+    const { success, message, data } = res.data;
+    if (success) {
+      completeTwoFactorLogin(data);
+    } else {
+      showError(message);
+    }
+  }
+
+  if (twoFactor) {
+    return (
+      <Grid textAlign='center' style={{ marginTop: '48px' }}>
+        <Grid.Column style={{ maxWidth: 450 }}>
+          <Card fluid className='chart-card'>
+            <Card.Content>
+              <Header as='h2' textAlign='center'>
+                {t('two_factor.title')}
+              </Header>
+              {twoFactor === 'setup' ? (
+                <>
+                  <Message>{t('two_factor.setup_required')}</Message>
+                  <TwoFactorSetup
+                    onDone={(data) => completeTwoFactorLogin(data.user)}
+                  />
+                </>
+              ) : (
+                <Form size='large'>
+                  <Form.Input
+                    fluid
+                    icon='shield'
+                    iconPosition='left'
+                    placeholder={t('two_factor.login_code_placeholder')}
+                    value={twoFactorCode}
+                    autoComplete='one-time-code'
+                    onChange={(e, { value }) => setTwoFactorCode(value)}
+                  />
+                  <Button
+                    fluid
+                    size='large'
+                    style={{ background: '#2F73FF', color: 'white' }}
+                    onClick={handleTwoFactorSubmit}
+                  >
+                    {t('auth.login.button')}
+                  </Button>
+                </Form>
+              )}
+              <Divider />
+              <Button
+                basic
+                fluid
+                onClick={() => {
+                  setTwoFactor('');
+                  setSearchParams({});
+                }}
+              >
+                {t('two_factor.buttons.back')}
+              </Button>
+            </Card.Content>
+          </Card>
+        </Grid.Column>
+      </Grid>
+    );
+  }
+
   return (
     <Grid textAlign='center' style={{ marginTop: '48px' }}>
       <Grid.Column style={{ maxWidth: 450 }}>
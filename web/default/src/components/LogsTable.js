@@ -13,7 +13,7 @@ import {
 import {
   API,
   copy,
-  isAdmin,
+  hasPermission,
   showError,
   showSuccess,
   showWarning,
@@ -125,6 +125,11 @@ function renderDetail(log) {
           </Label>
         </>
       )}
+      {log.pii_redactions > 0 && (
+        <Label basic size={'mini'} color='teal'>
+          PII × {log.pii_redactions}
+        </Label>
+      )}
     </>
   );
 }
@@ -138,7 +143,7 @@ const LogsTable = () => {
   const [searchKeyword, setSearchKeyword] = useState('');
   const [searching, setSearching] = useState(false);
   const [logType, setLogType] = useState(0);
-  const isAdminUser = isAdmin();
+  const isAdminUser = hasPermission('log:read:all');
   let now = new Date();
   const [inputs, setInputs] = useState({
     username: '',
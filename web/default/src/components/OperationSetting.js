@@ -32,6 +32,17 @@ const OperationSetting = () => {
     DisplayTokenStatEnabled: '',
     ApproximateTokenEnabled: '',
     RetryTimes: 0,
+    PiiRedactionRules: '',
+    PiiRedactionGroupRules: '',
+    PiiRedactionRestoreEnabled: '',
+    TokenLeakDetectionEnabled: '',
+    TokenLeakWindow: 0,
+    TokenLeakIpThreshold: 0,
+    TokenLeakUserAgentThreshold: 0,
+    TokenSpendAnomalyRatio: 0,
+    TokenSpendAnomalyMinQuota: 0,
+    TokenSpendBaselineHours: 0,
+    TokenLeakAction: 'notify',
   });
   const [originInputs, setOriginInputs] = useState({});
   let [loading, setLoading] = useState(false);
@@ -48,7 +59,8 @@ const OperationSetting = () => {
         if (
           item.key === 'ModelRatio' ||
           item.key === 'GroupRatio' ||
-          item.key === 'CompletionRatio'
+          item.key === 'CompletionRatio' ||
+          item.key === 'PiiRedactionGroupRules'
         ) {
           item.value = JSON.stringify(JSON.parse(item.value), null, 2);
         }
@@ -138,6 +150,37 @@ const OperationSetting = () => {
           await updateOption('CompletionRatio', inputs.CompletionRatio);
         }
         break;
+      case 'pii':
+        if (originInputs['PiiRedactionRules'] !== inputs.PiiRedactionRules) {
+          await updateOption('PiiRedactionRules', inputs.PiiRedactionRules);
+        }
+        if (
+          originInputs['PiiRedactionGroupRules'] !==
+          inputs.PiiRedactionGroupRules
+        ) {
+          const groupRules = inputs.PiiRedactionGroupRules || '{}';
+          if (!verifyJSON(groupRules)) {
+            showError(t('setting.operation.pii.group_rules_invalid'));
+            return;
+          }
+          await updateOption('PiiRedactionGroupRules', groupRules);
+        }
+        break;
+      case 'leak':
+        for (const key of [
+          'TokenLeakWindow',
+          'TokenLeakIpThreshold',
+          'TokenLeakUserAgentThreshold',
+          'TokenSpendAnomalyRatio',
+          'TokenSpendAnomalyMinQuota',
+          'TokenSpendBaselineHours',
+          'TokenLeakAction',
+        ]) {
+          if (originInputs[key] !== inputs[key]) {
+            await updateOption(key, inputs[key]);
+          }
+        }
+        break;
       case 'quota':
         if (originInputs['QuotaForNewUser'] !== inputs.QuotaForNewUser) {
           await updateOption('QuotaForNewUser', inputs.QuotaForNewUser);
@@ -361,6 +404,132 @@ const OperationSetting = () => {
             {t('setting.operation.monitor.buttons.save')}
           </Form.Button>
 
+          <Divider />
+          <Header as='h3'>{t('setting.operation.pii.title')}</Header>
+          <Form.Group widths={2}>
+            <Form.Input
+              label={t('setting.operation.pii.rules')}
+              name='PiiRedactionRules'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.PiiRedactionRules}
+              placeholder={t('setting.operation.pii.rules_placeholder')}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.pii.group_rules')}
+              name='PiiRedactionGroupRules'
+              onChange={handleInputChange}
+              style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.PiiRedactionGroupRules}
+              placeholder={t('setting.operation.pii.group_rules_placeholder')}
+            />
+          </Form.Group>
+          <Form.Group inline>
+            <Form.Checkbox
+              checked={inputs.PiiRedactionRestoreEnabled === 'true'}
+              label={t('setting.operation.pii.restore')}
+              name='PiiRedactionRestoreEnabled'
+              onChange={handleInputChange}
+            />
+          </Form.Group>
+          <Form.Button
+            onClick={() => {
+              submitConfig('pii').then();
+            }}
+          >
+            {t('setting.operation.pii.buttons.save')}
+          </Form.Button>
+
+          <Divider />
+          <Header as='h3'>{t('setting.operation.token_leak.title')}</Header>
+          <Form.Group widths={3}>
+            <Form.Input
+              label={t('setting.operation.token_leak.window')}
+              name='TokenLeakWindow'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.TokenLeakWindow}
+              type='number'
+              min='0'
+            />
+            <Form.Input
+              label={t('setting.operation.token_leak.ip_threshold')}
+              name='TokenLeakIpThreshold'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.TokenLeakIpThreshold}
+              type='number'
+              min='0'
+            />
+            <Form.Input
+              label={t('setting.operation.token_leak.user_agent_threshold')}
+              name='TokenLeakUserAgentThreshold'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.TokenLeakUserAgentThreshold}
+              type='number'
+              min='0'
+            />
+          </Form.Group>
+          <Form.Group widths={4}>
+            <Form.Input
+              label={t('setting.operation.token_leak.spend_ratio')}
+              name='TokenSpendAnomalyRatio'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.TokenSpendAnomalyRatio}
+              type='number'
+              min='0'
+            />
+            <Form.Input
+              label={t('setting.operation.token_leak.spend_min_quota')}
+              name='TokenSpendAnomalyMinQuota'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.TokenSpendAnomalyMinQuota}
+              type='number'
+              min='0'
+            />
+            <Form.Input
+              label={t('setting.operation.token_leak.spend_baseline_hours')}
+              name='TokenSpendBaselineHours'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.TokenSpendBaselineHours}
+              type='number'
+              min='0'
+            />
+            <Form.Select
+              label={t('setting.operation.token_leak.action')}
+              name='TokenLeakAction'
+              onChange={handleInputChange}
+              value={inputs.TokenLeakAction}
+              options={['notify', 'ban', 'disable'].map((action) => ({
+                key: action,
+                text: t(`setting.operation.token_leak.actions.${action}`),
+                value: action,
+              }))}
+            />
+          </Form.Group>
+          <Form.Group inline>
+            <Form.Checkbox
+              checked={inputs.TokenLeakDetectionEnabled === 'true'}
+              label={t('setting.operation.token_leak.enabled')}
+              name='TokenLeakDetectionEnabled'
+              onChange={handleInputChange}
+            />
+          </Form.Group>
+          <Form.Button
+            onClick={() => {
+              submitConfig('leak').then();
+            }}
+          >
+            {t('setting.operation.token_leak.buttons.save')}
+          </Form.Button>
+
           <Divider />
           <Header as='h3'>{t('setting.operation.general.title')}</Header>
           <Form.Group widths={4}>
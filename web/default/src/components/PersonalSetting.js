@@ -21,6 +21,9 @@ import {
 import Turnstile from 'react-turnstile';
 import { UserContext } from '../context/User';
 import { onGitHubOAuthClicked, onLarkOAuthClicked } from './utils';
+import TwoFactorSetup from './TwoFactorSetup';
+import AccessTokenSetting from './AccessTokenSetting';
+import SessionSetting from './SessionSetting';
 
 const PersonalSetting = () => {
   const { t } = useTranslation();
@@ -32,6 +35,7 @@ const PersonalSetting = () => {
     email_verification_code: '',
     email: '',
     self_account_deletion_confirmation: '',
+    two_factor_code: '',
   });
   const [status, setStatus] = useState({});
   const [showWeChatBindModal, setShowWeChatBindModal] = useState(false);
@@ -44,7 +48,18 @@ const PersonalSetting = () => {
   const [disableButton, setDisableButton] = useState(false);
   const [countdown, setCountdown] = useState(30);
   const [affLink, setAffLink] = useState('');
-  const [systemToken, setSystemToken] = useState('');
+  const [totpEnabled, setTotpEnabled] = useState(false);
+  const [showTwoFactorModal, setShowTwoFactorModal] = useState(false);
+
+  useEffect(() => {
+    (async () => {
+      const res = await API.get('/api/user/self');
+      const { success, data } = res.data;
+      if (success) {
+        setTotpEnabled(data.totp_enabled);
+      }
+    })();
+  }, []);
 
   useEffect(() => {
     let status = localStorage.getItem('status');
@@ -75,26 +90,12 @@ const PersonalSetting = () => {
     setInputs((inputs) => ({ ...inputs, [name]: value }));
   };
 
-  const generateAccessToken = async () => {
-    const res = await API.get('/api/user/token');
-    const { success, message, data } = res.data;
-    if (success) {
-      setSystemToken(data);
-      setAffLink('');
-      await copy(data);
-      showSuccess(`令牌已重置并已复制到剪贴板`);
-    } else {
-      showError(message);
-    }
-  };
-
   const getAffLink = async () => {
     const res = await API.get('/api/user/aff');
     const { success, message, data } = res.data;
     if (success) {
       let link = `${window.location.origin}/register?aff=${data}`;
       setAffLink(link);
-      setSystemToken('');
       await copy(link);
       showSuccess(`邀请链接已复制到剪切板`);
     } else {
@@ -108,12 +109,6 @@ const PersonalSetting = () => {
     showSuccess(`邀请链接已复制到剪切板`);
   };
 
-  const handleSystemTokenClick = async (e) => {
-    e.target.select();
-    await copy(e.target.value);
-    showSuccess(`系统令牌已复制到剪切板`);
-  };
-
   const deleteAccount = async () => {
     if (inputs.self_account_deletion_confirmation !== userState.user.username) {
       showError('请输入你的账户名以确认删除！');
@@ -134,6 +129,22 @@ const PersonalSetting = () => {
     }
   };
 
+  const disableTwoFactor = async () => {
+    if (inputs.two_factor_code === '') return;
+    const res = await API.post('/api/user/2fa/disable', {
+      code: inputs.two_factor_code,
+    });
+    const { success, message } = res.data;
+    if (success) {
+      setTotpEnabled(false);
+      setShowTwoFactorModal(false);
+      setInputs((inputs) => ({ ...inputs, two_factor_code: '' }));
+      showSuccess(t('two_factor.messages.disabled'));
+    } else {
+      showError(message);
+    }
+  };
+
   const bindWeChat = async () => {
     if (inputs.wechat_verification_code === '') return;
     const res = await API.get(
@@ -187,13 +198,9 @@ const PersonalSetting = () => {
   return (
     <div style={{ lineHeight: '40px' }}>
       <Header as='h3'>{t('setting.personal.general.title')}</Header>
-      <Message>{t('setting.personal.general.system_token_notice')}</Message>
       <Button as={Link} to={`/user/edit/`}>
         {t('setting.personal.general.buttons.update_profile')}
       </Button>
-      <Button onClick={generateAccessToken}>
-        {t('setting.personal.general.buttons.generate_token')}
-      </Button>
       <Button onClick={getAffLink}>
         {t('setting.personal.general.buttons.copy_invite')}
       </Button>
@@ -205,15 +212,6 @@ const PersonalSetting = () => {
         {t('setting.personal.general.buttons.delete_account')}
       </Button>
 
-      {systemToken && (
-        <Form.Input
-          fluid
-          readOnly
-          value={systemToken}
-          onClick={handleSystemTokenClick}
-          style={{ marginTop: '10px' }}
-        />
-      )}
       {affLink && (
         <Form.Input
           fluid
@@ -224,6 +222,58 @@ const PersonalSetting = () => {
         />
       )}
       <Divider />
+      <Header as='h3'>{t('access_token.title')}</Header>
+      <AccessTokenSetting />
+      <Divider />
+      <Header as='h3'>{t('session.title')}</Header>
+      <SessionSetting />
+      <Divider />
+      <Header as='h3'>{t('two_factor.title')}</Header>
+      <Message>
+        {totpEnabled
+          ? t('two_factor.status_enabled')
+          : t('two_factor.status_disabled')}
+      </Message>
+      <Button onClick={() => setShowTwoFactorModal(true)}>
+        {totpEnabled
+          ? t('two_factor.buttons.disable')
+          : t('two_factor.buttons.enable')}
+      </Button>
+      <Modal
+        onClose={() => setShowTwoFactorModal(false)}
+        open={showTwoFactorModal}
+        size={'tiny'}
+        style={{ maxWidth: '450px' }}
+      >
+        <Modal.Header>{t('two_factor.title')}</Modal.Header>
+        <Modal.Content>
+          {totpEnabled ? (
+            <Form size='large'>
+              <Form.Input
+                fluid
+                placeholder={t('two_factor.login_code_placeholder')}
+                name='two_factor_code'
+                value={inputs.two_factor_code}
+                autoComplete='one-time-code'
+                onChange={handleInputChange}
+              />
+              <Button color='red' fluid onClick={disableTwoFactor}>
+                {t('two_factor.buttons.disable')}
+              </Button>
+            </Form>
+          ) : (
+            showTwoFactorModal && (
+              <TwoFactorSetup
+                onDone={() => {
+                  setTotpEnabled(true);
+                  setShowTwoFactorModal(false);
+                }}
+              />
+            )
+          )}
+        </Modal.Content>
+      </Modal>
+      <Divider />
       <Header as='h3'>{t('setting.personal.binding.title')}</Header>
       {status.wechat_login && (
         <Button onClick={() => setShowWeChatBindModal(true)}>
@@ -0,0 +1,194 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Button, Form, Grid, Header, Table } from 'semantic-ui-react';
+import { API, showError, showSuccess, timestamp2string } from '../helpers';
+
+const emptyRole = {
+  id: 0,
+  name: '',
+  description: '',
+  permissions: [],
+};
+
+const RoleSetting = () => {
+  const { t } = useTranslation();
+  const [roles, setRoles] = useState([]);
+  const [permissions, setPermissions] = useState([]);
+  const [inputs, setInputs] = useState(emptyRole);
+  const [loading, setLoading] = useState(false);
+
+  const loadRoles = async () => {
+    const res = await API.get('/api/role/');
+    const { success, message, data } = res.data;
+    if (success) {
+      setRoles(data || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const loadPermissions = async () => {
+    const res = await API.get('/api/role/permissions');
+    const { success, message, data } = res.data;
+    if (success) {
+      setPermissions(data || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadRoles().then();
+    loadPermissions().then();
+  }, []);
+
+  const handleInputChange = (e, { name, value }) => {
+    setInputs((inputs) => ({ ...inputs, [name]: value }));
+  };
+
+  const togglePermission = (permission) => {
+    setInputs((inputs) => {
+      const list = inputs.permissions.includes(permission)
+        ? inputs.permissions.filter((p) => p !== permission)
+        : [...inputs.permissions, permission];
+      return { ...inputs, permissions: list };
+    });
+  };
+
+  const editRole = (role) => {
+    setInputs({
+      id: role.id,
+      name: role.name,
+      description: role.description,
+      permissions: role.permissions ? role.permissions.split(',') : [],
+    });
+  };
+
+  const submit = async () => {
+    if (inputs.name.trim() === '') {
+      showError(t('setting.role.name_required'));
+      return;
+    }
+    setLoading(true);
+    const payload = { ...inputs, permissions: inputs.permissions.join(',') };
+    const res =
+      inputs.id === 0
+        ? await API.post('/api/role/', payload)
+        : await API.put('/api/role/', payload);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('setting.role.saved'));
+      setInputs(emptyRole);
+      await loadRoles();
+    } else {
+      showError(message);
+    }
+    setLoading(false);
+  };
+
+  const deleteRole = async (id) => {
+    const res = await API.delete(`/api/role/${id}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('setting.role.deleted'));
+      if (inputs.id === id) {
+        setInputs(emptyRole);
+      }
+      await loadRoles();
+    } else {
+      showError(message);
+    }
+  };
+
+  return (
+    <Grid columns={1}>
+      <Grid.Column>
+        <Header as='h3'>{t('setting.role.title')}</Header>
+        <Table basic compact size='small'>
+          <Table.Header>
+            <Table.Row>
+              <Table.HeaderCell>{t('setting.role.name')}</Table.HeaderCell>
+              <Table.HeaderCell>
+                {t('setting.role.description')}
+              </Table.HeaderCell>
+              <Table.HeaderCell>
+                {t('setting.role.permissions')}
+              </Table.HeaderCell>
+              <Table.HeaderCell>
+                {t('setting.role.created_time')}
+              </Table.HeaderCell>
+              <Table.HeaderCell>{t('setting.role.actions')}</Table.HeaderCell>
+            </Table.Row>
+          </Table.Header>
+          <Table.Body>
+            {roles.map((role) => (
+              <Table.Row key={role.id}>
+                <Table.Cell>{role.name}</Table.Cell>
+                <Table.Cell>{role.description}</Table.Cell>
+                <Table.Cell>
+                  {role.permissions
+                    ? role.permissions.split(',').join(', ')
+                    : '-'}
+                </Table.Cell>
+                <Table.Cell>{timestamp2string(role.created_time)}</Table.Cell>
+                <Table.Cell>
+                  <Button size='tiny' onClick={() => editRole(role)}>
+                    {t('setting.role.buttons.edit')}
+                  </Button>
+                  <Button
+                    size='tiny'
+                    negative
+                    onClick={() => deleteRole(role.id)}
+                  >
+                    {t('setting.role.buttons.delete')}
+                  </Button>
+                </Table.Cell>
+              </Table.Row>
+            ))}
+          </Table.Body>
+        </Table>
+
+        <Header as='h3'>
+          {inputs.id === 0
+            ? t('setting.role.add_title')
+            : t('setting.role.edit_title')}
+        </Header>
+        <Form loading={loading}>
+          <Form.Group widths='equal'>
+            <Form.Input
+              label={t('setting.role.name')}
+              name='name'
+              value={inputs.name}
+              onChange={handleInputChange}
+              placeholder={t('setting.role.name_placeholder')}
+            />
+            <Form.Input
+              label={t('setting.role.description')}
+              name='description'
+              value={inputs.description}
+              onChange={handleInputChange}
+            />
+          </Form.Group>
+          <Form.Group inline style={{ flexWrap: 'wrap' }}>
+            {permissions.map((permission) => (
+              <Form.Checkbox
+                key={permission}
+                label={permission}
+                checked={inputs.permissions.includes(permission)}
+                onChange={() => togglePermission(permission)}
+              />
+            ))}
+          </Form.Group>
+          <Button onClick={submit}>{t('setting.role.buttons.save')}</Button>
+          {inputs.id !== 0 && (
+            <Button onClick={() => setInputs(emptyRole)}>
+              {t('setting.role.buttons.cancel')}
+            </Button>
+          )}
+        </Form>
+      </Grid.Column>
+    </Grid>
+  );
+};
+
+export default RoleSetting;
@@ -0,0 +1,96 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Button, Label, Table } from 'semantic-ui-react';
+import { API, showError, showSuccess, timestamp2string } from '../helpers';
+
+const SessionSetting = () => {
+  const { t } = useTranslation();
+  const [sessions, setSessions] = useState([]);
+
+  const loadSessions = async () => {
+    const res = await API.get('/api/user/session');
+    const { success, message, data } = res.data;
+    if (success) {
+      setSessions(data || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadSessions().then();
+  }, []);
+
+  const revoke = async (id) => {
+    const res = await API.delete(`/api/user/session/${id}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('session.messages.revoked'));
+      await loadSessions();
+    } else {
+      showError(message);
+    }
+  };
+
+  const revokeOthers = async () => {
+    const res = await API.delete('/api/user/session');
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('session.messages.others_revoked'));
+      await loadSessions();
+    } else {
+      showError(message);
+    }
+  };
+
+  return (
+    <>
+      <Table basic compact size='small'>
+        <Table.Header>
+          <Table.Row>
+            <Table.HeaderCell>{t('session.device')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('session.ip')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('session.created_time')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('session.last_seen')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('session.actions')}</Table.HeaderCell>
+          </Table.Row>
+        </Table.Header>
+        <Table.Body>
+          {sessions.map((session) => (
+            <Table.Row key={session.id}>
+              <Table.Cell>
+                {session.user_agent || '-'}{' '}
+                {session.current && (
+                  <Label size='tiny' color='green'>
+                    {t('session.current')}
+                  </Label>
+                )}
+              </Table.Cell>
+              <Table.Cell>{session.ip}</Table.Cell>
+              <Table.Cell>{timestamp2string(session.created_time)}</Table.Cell>
+              <Table.Cell>
+                {timestamp2string(session.last_seen_time)}
+              </Table.Cell>
+              <Table.Cell>
+                {!session.current && (
+                  <Button
+                    size='tiny'
+                    negative
+                    onClick={() => revoke(session.id)}
+                  >
+                    {t('session.buttons.revoke')}
+                  </Button>
+                )}
+              </Table.Cell>
+            </Table.Row>
+          ))}
+        </Table.Body>
+      </Table>
+      <Button onClick={revokeOthers}>
+        {t('session.buttons.revoke_others')}
+      </Button>
+    </>
+  );
+};
+
+export default SessionSetting;
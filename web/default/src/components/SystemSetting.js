@@ -40,8 +40,11 @@ const SystemSetting = () => {
     TurnstileSiteKey: '',
     TurnstileSecretKey: '',
     RegisterEnabled: '',
+    AdminTwoFactorEnabled: '',
     EmailDomainRestrictionEnabled: '',
     EmailDomainWhitelist: '',
+    IpAllowList: '',
+    IpDenyList: '',
   });
   const [originInputs, setOriginInputs] = useState({});
   let [loading, setLoading] = useState(false);
@@ -89,6 +92,7 @@ const SystemSetting = () => {
       case 'TurnstileCheckEnabled':
       case 'EmailDomainRestrictionEnabled':
       case 'RegisterEnabled':
+      case 'AdminTwoFactorEnabled':
         value = inputs[key] === 'true' ? 'false' : 'true';
         break;
       default:
@@ -132,7 +136,9 @@ const SystemSetting = () => {
       name === 'WeChatAccountQRCodeImageURL' ||
       name === 'TurnstileSiteKey' ||
       name === 'TurnstileSecretKey' ||
-      name === 'EmailDomainWhitelist'
+      name === 'EmailDomainWhitelist' ||
+      name === 'IpAllowList' ||
+      name === 'IpDenyList'
     ) {
       setInputs((inputs) => ({ ...inputs, [name]: value }));
     } else {
@@ -182,6 +188,15 @@ const SystemSetting = () => {
     }
   };
 
+  const submitIpAccess = async () => {
+    if (originInputs['IpAllowList'] !== inputs.IpAllowList) {
+      await updateOption('IpAllowList', inputs.IpAllowList);
+    }
+    if (originInputs['IpDenyList'] !== inputs.IpDenyList) {
+      await updateOption('IpDenyList', inputs.IpDenyList);
+    }
+  };
+
   const submitWeChat = async () => {
     if (originInputs['WeChatServerAddress'] !== inputs.WeChatServerAddress) {
       await updateOption(
@@ -374,6 +389,12 @@ const SystemSetting = () => {
               name='TurnstileCheckEnabled'
               onChange={handleInputChange}
             />
+            <Form.Checkbox
+              checked={inputs.AdminTwoFactorEnabled === 'true'}
+              label={t('setting.system.login.admin_two_factor')}
+              name='AdminTwoFactorEnabled'
+              onChange={handleInputChange}
+            />
           </Form.Group>
           <Divider />
           <Header as='h3'>{t('setting.system.email_restriction.title')}</Header>
@@ -452,6 +473,31 @@ const SystemSetting = () => {
             {t('setting.system.email_restriction.buttons.save')}
           </Form.Button>
 
+          <Divider />
+          <Header as='h3'>{t('setting.system.ip_access.title')}</Header>
+          <Message>{t('setting.system.ip_access.subtitle')}</Message>
+          <Form.Group widths={2}>
+            <Form.Input
+              label={t('setting.system.ip_access.allow_list')}
+              placeholder={t('setting.system.ip_access.list_placeholder')}
+              name='IpAllowList'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.IpAllowList}
+            />
+            <Form.Input
+              label={t('setting.system.ip_access.deny_list')}
+              placeholder={t('setting.system.ip_access.list_placeholder')}
+              name='IpDenyList'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.IpDenyList}
+            />
+          </Form.Group>
+          <Form.Button onClick={submitIpAccess}>
+            {t('setting.system.ip_access.buttons.save')}
+          </Form.Button>
+
           <Divider />
           <Header as='h3'>{t('setting.system.smtp.title')}</Header>
           <Message>{t('setting.system.smtp.subtitle')}</Message>
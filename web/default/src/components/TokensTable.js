@@ -121,6 +121,10 @@ const TokensTable = () => {
   };
 
   const onCopy = async (type, key) => {
+    if (key.includes('*')) {
+      showWarning(t('token.messages.key_masked'));
+      return;
+    }
     let status = localStorage.getItem('status');
     let serverAddress = '';
     if (status) {
@@ -169,6 +173,10 @@ const TokensTable = () => {
   };
 
   const onOpenLink = async (type, key) => {
+    if (key.includes('*')) {
+      showWarning(t('token.messages.key_masked'));
+      return;
+    }
     let status = localStorage.getItem('status');
     let serverAddress = '';
     if (status) {
@@ -0,0 +1,86 @@
+import React, { useEffect, useState } from 'react';
+import { Button, Form, Image, Message, Segment } from 'semantic-ui-react';
+import { useTranslation } from 'react-i18next';
+import { API, copy, showError, showSuccess } from '../helpers';
+
+// TwoFactorSetup shows the QR code of a new secret, confirms it with a code and then shows the recovery codes
+const TwoFactorSetup = ({ onDone }) => {
+  const { t } = useTranslation();
+  const [setup, setSetup] = useState(null);
+  const [code, setCode] = useState('');
+  const [result, setResult] = useState(null);
+  const [loading, setLoading] = useState(false);
+
+  useEffect(() => {
+    (async () => {
+      const res = await API.post('/api/user/2fa/setup');
+      const { success, message, data } = res.data;
+      if (success) {
+        setSetup(data);
+      } else {
+        showError(message);
+      }
+    })();
+  }, []);
+
+  const enable = async () => {
+    if (code === '') return;
+    setLoading(true);
+    const res = await API.post('/api/user/2fa/enable', { code });
+    const { success, message, data } = res.data;
+    if (success) {
+      setResult(data);
+      showSuccess(t('two_factor.messages.enabled'));
+    } else {
+      showError(message);
+    }
+    setLoading(false);
+  };
+
+  if (result) {
+    return (
+      <>
+        <Message warning>{t('two_factor.recovery_codes_notice')}</Message>
+        <Segment style={{ fontFamily: 'monospace', lineHeight: '1.8em' }}>
+          {result.recovery_codes.map((recoveryCode) => (
+            <div key={recoveryCode}>{recoveryCode}</div>
+          ))}
+        </Segment>
+        <Button onClick={() => copy(result.recovery_codes.join('\n'))}>
+          {t('two_factor.buttons.copy_recovery_codes')}
+        </Button>
+        <Button primary onClick={() => onDone(result)}>
+          {t('two_factor.buttons.done')}
+        </Button>
+      </>
+    );
+  }
+
+  if (!setup) {
+    return <Segment loading style={{ minHeight: '200px' }} />;
+  }
+
+  return (
+    <Form>
+      <p>{t('two_factor.scan_tip')}</p>
+      {setup.qr_code && (
+        <Image src={setup.qr_code} centered style={{ width: '200px' }} />
+      )}
+      <p style={{ textAlign: 'center', wordBreak: 'break-all' }}>
+        <code>{setup.secret}</code>
+      </p>
+      <Form.Input
+        fluid
+        placeholder={t('two_factor.code_placeholder')}
+        value={code}
+        autoComplete='one-time-code'
+        onChange={(e, { value }) => setCode(value)}
+      />
+      <Button primary fluid loading={loading} onClick={enable}>
+        {t('two_factor.buttons.enable')}
+      </Button>
+    </Form>
+  );
+};
+
+export default TwoFactorSetup;
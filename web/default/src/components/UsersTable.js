@@ -96,6 +96,7 @@ const UsersTable = () => {
         } else {
           newUsers[realIdx].status = user.status;
           newUsers[realIdx].role = user.role;
+          newUsers[realIdx].totp_enabled = user.totp_enabled;
         }
         setUsers(newUsers);
       } else {
@@ -104,6 +105,16 @@ const UsersTable = () => {
     })();
   };
 
+  const revokeSessions = async (id) => {
+    const res = await API.delete(`/api/user/${id}/session`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('user.messages.sessions_revoked'));
+    } else {
+      showError(message);
+    }
+  };
+
   const renderStatus = (status) => {
     switch (status) {
       case 1:
@@ -350,6 +361,24 @@ const UsersTable = () => {
                           ? t('user.buttons.disable')
                           : t('user.buttons.enable')}
                       </Button>
+                      {user.totp_enabled && (
+                        <Button
+                          size={'tiny'}
+                          onClick={() => {
+                            manageUser(user.username, 'reset_2fa', idx);
+                          }}
+                        >
+                          {t('user.buttons.reset_2fa')}
+                        </Button>
+                      )}
+                      <Button
+                        size={'tiny'}
+                        onClick={() => {
+                          revokeSessions(user.id).then();
+                        }}
+                      >
+                        {t('user.buttons.revoke_sessions')}
+                      </Button>
                       <Button
                         size={'tiny'}
                         as={Link}
@@ -22,6 +22,17 @@ export function isRoot() {
   return user.role >= 100;
 }
 
+// hasPermission falls back to the role for users saved before permissions were returned on login
+export function hasPermission(permission) {
+  let user = localStorage.getItem('user');
+  if (!user) return false;
+  user = JSON.parse(user);
+  if (Array.isArray(user.permissions)) {
+    return user.permissions.includes(permission);
+  }
+  return user.role >= 10;
+}
+
 export function getSystemName() {
   let system_name = localStorage.getItem('system_name');
   if (!system_name) return 'One API';
@@ -80,6 +80,46 @@ const EditChannel = () => {
     setConfig((inputs) => ({ ...inputs, [name]: value }));
   };
 
+  // redacted secret config fields left empty keep the stored secret, cleared ones are removed
+  const [clearedSecrets, setClearedSecrets] = useState([]);
+  const toggleClearSecret = (name) => {
+    setClearedSecrets((names) =>
+      names.includes(name) ? names.filter((n) => n !== name) : [...names, name]
+    );
+    setConfig((config) => ({ ...config, [name]: '' }));
+  };
+  const secretConfigProps = (name, placeholder) => {
+    if (!isEdit) {
+      return { required: true, placeholder };
+    }
+    const cleared = clearedSecrets.includes(name);
+    return {
+      disabled: cleared,
+      placeholder: cleared
+        ? t('channel.edit.secret_cleared_placeholder')
+        : t('channel.edit.secret_unchanged_placeholder'),
+      action: {
+        content: cleared
+          ? t('channel.edit.buttons.keep_secret')
+          : t('channel.edit.buttons.clear_secret'),
+        type: 'button',
+        onClick: () => toggleClearSecret(name),
+      },
+    };
+  };
+
+  // secrets are redacted when loading, revealing them is recorded in the logs
+  const revealKey = async () => {
+    const res = await API.post(`/api/channel/${channelId}/reveal`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setInputs((inputs) => ({ ...inputs, key: data.key }));
+      setConfig((config) => ({ ...config, ...data.config }));
+    } else {
+      showError(message);
+    }
+  };
+
   const loadChannel = async () => {
     let res = await API.get(`/api/channel/${channelId}`);
     const { success, message, data } = res.data;
@@ -103,7 +143,8 @@ const EditChannel = () => {
       }
       setInputs(data);
       if (data.config !== '') {
-        setConfig(JSON.parse(data.config));
+        // redacted secrets are omitted, keep them as empty strings
+        setConfig((config) => ({ ...config, ...JSON.parse(data.config) }));
       }
       setBasicModels(getChannelModels(data.type));
     } else {
@@ -207,7 +248,15 @@ const EditChannel = () => {
     let res;
     localInputs.models = localInputs.models.join(',');
     localInputs.group = localInputs.groups.join(',');
-    localInputs.config = JSON.stringify(config);
+    const clearedConfig = {};
+    clearedSecrets.forEach((name) => {
+      clearedConfig[name] = '[clear]';
+    });
+    localInputs.config = JSON.stringify({
+      ...config,
+      ...clearedConfig,
+      proxy_quota_per_call: parseInt(config.proxy_quota_per_call) || 0,
+    });
     if (isEdit) {
       res = await API.put(`/api/channel/`, {
         ...localInputs,
@@ -535,8 +584,10 @@ const EditChannel = () => {
                 <Form.Input
                   label='AK'
                   name='ak'
-                  required
-                  placeholder={t('channel.edit.aws_ak_placeholder')}
+                  {...secretConfigProps(
+                    'ak',
+                    t('channel.edit.aws_ak_placeholder')
+                  )}
                   onChange={handleConfigChange}
                   value={config.ak}
                   autoComplete=''
@@ -544,8 +595,10 @@ const EditChannel = () => {
                 <Form.Input
                   label='SK'
                   name='sk'
-                  required
-                  placeholder={t('channel.edit.aws_sk_placeholder')}
+                  {...secretConfigProps(
+                    'sk',
+                    t('channel.edit.aws_sk_placeholder')
+                  )}
                   onChange={handleConfigChange}
                   value={config.sk}
                   autoComplete=''
@@ -575,8 +628,10 @@ const EditChannel = () => {
                 <Form.Input
                   label={t('channel.edit.vertex_credentials')}
                   name='vertex_ai_adc'
-                  required
-                  placeholder={t('channel.edit.vertex_credentials_placeholder')}
+                  {...secretConfigProps(
+                    'vertex_ai_adc',
+                    t('channel.edit.vertex_credentials_placeholder')
+                  )}
                   onChange={handleConfigChange}
                   value={config.vertex_ai_adc}
                   autoComplete=''
@@ -617,11 +672,24 @@ const EditChannel = () => {
                   <Form.Input
                     label={t('channel.edit.key')}
                     name='key'
-                    required
-                    placeholder={type2secretPrompt(inputs.type, t)}
+                    required={!isEdit}
+                    placeholder={
+                      isEdit
+                        ? t('channel.edit.key_unchanged_placeholder')
+                        : type2secretPrompt(inputs.type, t)
+                    }
                     onChange={handleInputChange}
                     value={inputs.key}
                     autoComplete='new-password'
+                    action={
+                      isEdit
+                        ? {
+                            content: t('channel.edit.buttons.reveal_key'),
+                            type: 'button',
+                            onClick: revealKey,
+                          }
+                        : undefined
+                    }
                   />
                 </Form.Field>
               ))}
@@ -640,6 +708,43 @@ const EditChannel = () => {
                 />
               </Form.Field>
             )}
+            {inputs.type === 43 && (
+              <>
+                <Form.Input
+                  label='计费模型'
+                  name='proxy_model_name'
+                  placeholder='按用量计费时使用该模型的倍率，留空则使用请求中的模型'
+                  onChange={handleConfigChange}
+                  value={config.proxy_model_name || ''}
+                  autoComplete=''
+                />
+                <Form.Input
+                  label='提示 tokens 的 JSONPath'
+                  name='proxy_prompt_tokens_path'
+                  placeholder='例如：$.usage.prompt_tokens，流式响应会从每个 data 行中提取'
+                  onChange={handleConfigChange}
+                  value={config.proxy_prompt_tokens_path || ''}
+                  autoComplete=''
+                />
+                <Form.Input
+                  label='补全 tokens 的 JSONPath'
+                  name='proxy_completion_tokens_path'
+                  placeholder='例如：$.usage.completion_tokens'
+                  onChange={handleConfigChange}
+                  value={config.proxy_completion_tokens_path || ''}
+                  autoComplete=''
+                />
+                <Form.Input
+                  label='按次计费额度'
+                  name='proxy_quota_per_call'
+                  type='number'
+                  placeholder='设置后每次调用扣除该额度，优先于按用量计费；未配置计费规则时仅管理员可使用该渠道'
+                  onChange={handleConfigChange}
+                  value={config.proxy_quota_per_call || ''}
+                  autoComplete=''
+                />
+              </>
+            )}
             {inputs.type !== 33 && !isEdit && (
               <Form.Checkbox
                 checked={batch}
@@ -2,10 +2,12 @@ import React from 'react';
 import { useTranslation } from 'react-i18next';
 import { Card, Tab } from 'semantic-ui-react';
 import SystemSetting from '../../components/SystemSetting';
-import { isRoot } from '../../helpers';
+import { hasPermission } from '../../helpers';
 import OtherSetting from '../../components/OtherSetting';
 import PersonalSetting from '../../components/PersonalSetting';
 import OperationSetting from '../../components/OperationSetting';
+import RoleSetting from '../../components/RoleSetting';
+import BanSetting from '../../components/BanSetting';
 
 const Setting = () => {
   const { t } = useTranslation();
@@ -21,7 +23,7 @@ const Setting = () => {
     },
   ];
 
-  if (isRoot()) {
+  if (hasPermission('option:write')) {
     panes.push({
       menuItem: t('setting.tabs.operation'),
       render: () => (
@@ -48,6 +50,28 @@ const Setting = () => {
     });
   }
 
+  if (hasPermission('role:write')) {
+    panes.push({
+      menuItem: t('setting.tabs.role'),
+      render: () => (
+        <Tab.Pane attached={false}>
+          <RoleSetting />
+        </Tab.Pane>
+      ),
+    });
+  }
+
+  if (hasPermission('ban:read')) {
+    panes.push({
+      menuItem: t('setting.tabs.ban'),
+      render: () => (
+        <Tab.Pane attached={false}>
+          <BanSetting />
+        </Tab.Pane>
+      ),
+    });
+  }
+
   return (
     <div className='dashboard-container'>
       <Card fluid className='chart-card'>
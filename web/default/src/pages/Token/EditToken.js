@@ -32,6 +32,8 @@ const EditToken = () => {
     unlimited_quota: false,
     models: [],
     subnet: '',
+    pii_rules: '',
+    filter_policy: '',
   };
   const [inputs, setInputs] = useState(originInputs);
   const { name, remain_quota, expired_time, unlimited_quota } = inputs;
@@ -139,12 +141,15 @@ const EditToken = () => {
     } else {
       res = await API.post(`/api/token/`, localInputs);
     }
-    const { success, message } = res.data;
+    const { success, message, data } = res.data;
     if (success) {
       if (isEdit) {
         showSuccess(t('token.edit.messages.update_success'));
       } else {
-        showSuccess(t('token.edit.messages.create_success'));
+        // the full key is only returned once
+        const key = `sk-${data.key}`;
+        await copy(key);
+        showSuccess(t('token.edit.messages.create_success', { key }));
         setInputs(originInputs);
       }
     } else {
@@ -199,6 +204,26 @@ const EditToken = () => {
                 autoComplete='new-password'
               />
             </Form.Field>
+            <Form.Field>
+              <Form.Input
+                label={t('token.edit.pii_rules')}
+                name='pii_rules'
+                placeholder={t('token.edit.pii_rules_placeholder')}
+                onChange={handleInputChange}
+                value={inputs.pii_rules}
+                autoComplete='new-password'
+              />
+            </Form.Field>
+            <Form.Field>
+              <Form.Input
+                label={t('token.edit.filter_policy')}
+                name='filter_policy'
+                placeholder={t('token.edit.filter_policy_placeholder')}
+                onChange={handleInputChange}
+                value={inputs.filter_policy}
+                autoComplete='new-password'
+              />
+            </Form.Field>
             <Form.Field>
               <Form.Input
                 label={t('token.edit.expire_time')}
@@ -2,7 +2,12 @@ import React, { useEffect, useState } from 'react';
 import { useTranslation } from 'react-i18next';
 import { Button, Form, Card } from 'semantic-ui-react';
 import { useParams, useNavigate } from 'react-router-dom';
-import { API, showError, showSuccess } from '../../helpers';
+import {
+  API,
+  hasPermission,
+  showError,
+  showSuccess,
+} from '../../helpers';
 import { renderQuota, renderQuotaWithPrompt } from '../../helpers/render';
 
 const EditUser = () => {
@@ -21,6 +26,8 @@ const EditUser = () => {
     group: 'default',
   });
   const [groupOptions, setGroupOptions] = useState([]);
+  const [roleOptions, setRoleOptions] = useState([]);
+  const canAssignRole = userId && hasPermission('role:write');
   const {
     username,
     display_name,
@@ -48,6 +55,22 @@ const EditUser = () => {
       showError(error.message);
     }
   };
+  const fetchRoles = async () => {
+    let res = await API.get(`/api/role/`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setRoleOptions([
+        { key: 0, text: t('user.edit.no_role'), value: 0 },
+        ...data.map((role) => ({
+          key: role.id,
+          text: role.name,
+          value: role.id,
+        })),
+      ]);
+    } else {
+      showError(message);
+    }
+  };
   const navigate = useNavigate();
   const handleCancel = () => {
     navigate('/setting');
@@ -73,6 +96,9 @@ const EditUser = () => {
     if (userId) {
       fetchGroups().then();
     }
+    if (canAssignRole) {
+      fetchRoles().then();
+    }
   }, []);
 
   const submit = async () => {
@@ -163,6 +189,39 @@ const EditUser = () => {
                     autoComplete='new-password'
                   />
                 </Form.Field>
+                {canAssignRole && (
+                  <Form.Field>
+                    <Form.Dropdown
+                      label={t('user.edit.role')}
+                      name='role_id'
+                      fluid
+                      selection
+                      onChange={handleInputChange}
+                      value={inputs.role_id || 0}
+                      options={roleOptions}
+                    />
+                  </Form.Field>
+                )}
+                <Form.Field>
+                  <Form.Input
+                    label={t('user.edit.ip_allow_list')}
+                    name='ip_allow_list'
+                    placeholder={t('user.edit.ip_list_placeholder')}
+                    onChange={handleInputChange}
+                    value={inputs.ip_allow_list || ''}
+                    autoComplete='new-password'
+                  />
+                </Form.Field>
+                <Form.Field>
+                  <Form.Input
+                    label={t('user.edit.ip_deny_list')}
+                    name='ip_deny_list'
+                    placeholder={t('user.edit.ip_list_placeholder')}
+                    onChange={handleInputChange}
+                    value={inputs.ip_deny_list || ''}
+                    autoComplete='new-password'
+                  />
+                </Form.Field>
               </>
             )}
             <Form.Field>